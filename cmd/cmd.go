@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of goployer with its own flags
+type command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Flags   func(fs *flag.FlagSet, c *builder.Config)
+	Run     func(config builder.Config, args []string) error
+}
+
+// commands are the list of subcommands in the order of help message
+var commands = []command{
+	deployCommand,
+	resumeCommand,
+	validateCommand,
+	statusCommand,
+	historyCommand,
+	rollbackCommand,
+	deleteCommand,
+	unlockCommand,
+	initCommand,
+	schemaCommand,
+	versionCommand,
+}
+
+// Execute runs the subcommand in args
+// If no subcommand is specified, then arguments are passed to deploy command for backward compatibility.
+func Execute(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return deployCommand.execute(args)
+	}
+
+	name := args[0]
+	if name == "help" {
+		if len(args) > 1 {
+			if c, ok := findCommand(args[1]); ok {
+				return c.execute([]string{"--help"})
+			}
+		}
+		usage()
+		return nil
+	}
+
+	c, ok := findCommand(name)
+	if !ok {
+		usage()
+		return fmt.Errorf("unknown command : %s", name)
+	}
+
+	return c.execute(args[1:])
+}
+
+// execute parses flags of the command and runs it
+func (c command) execute(args []string) error {
+	config := builder.NewConfig()
+
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "%s\n\nUsage:\n  goployer %s\n\nOptions:\n", c.Summary, c.Usage)
+		fs.PrintDefaults()
+	}
+
+	if c.Flags != nil {
+		c.Flags(fs, &config)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	return c.Run(config, fs.Args())
+}
+
+// findCommand returns the command with the name
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// usage prints the list of commands
+func usage() {
+	out := os.Stderr
+	fmt.Fprintln(out, "goployer deploys EC2 applications with autoscaling groups.")
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  goployer <command> [options]")
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", c.Name, c.Summary)
+	}
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Options without a command are passed to deploy.")
+	fmt.Fprintln(out, "Use \"goployer <command> --help\" for more information about a command.")
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/goployer/version"
+	"github.com/DevopsArtFactory/goployer/pkg/runner"
+)
+
+var deployCommand = command{
+	Name:    "deploy",
+	Usage:   "deploy --manifest=<path> --stack=<stack> [options]",
+	Summary: "Deploy a new version of the stack",
+	Flags: func(fs *flag.FlagSet, c *builder.Config) {
+		builder.AddStackFlags(fs, c)
+		builder.AddExecutionFlags(fs, c)
+		builder.AddDeploymentFlags(fs, c)
+		fs.BoolVar(&c.DryRun, "dry-run", false, "Print every change of AWS resources without making it")
+		fs.BoolVar(&c.SkipPermissionCheck, "skip-permission-check", false, "Deploy without checking permissions of the assume role with IAM policy simulation")
+		fs.BoolVar(&c.ParallelStacks, "parallel-stacks", false, "Deploy stacks which do not depend on each other at the same time")
+		fs.IntVar(&c.RegionConcurrency, "region-concurrency", 0, "The number of regions to deploy at the same time (default all regions)")
+		fs.StringVar(&c.RegionFailurePolicy, "region-failure-policy", builder.REGION_FAILURE_POLICY_STOP, "Policy when deployment fails in a region: stop or continue")
+		fs.StringVar(&c.StateFile, "state-file", "", "Path of the file to save progress of deployment (default .goployer/state/<manifest>_<stack>.json)")
+		fs.StringVar(&c.OnInterrupt, "on-interrupt", builder.INTERRUPT_ACTION_ROLLBACK, "Action for the new version when deployment is interrupted: rollback or abandon")
+	},
+	Run: func(config builder.Config, args []string) error {
+		return runner.Deploy(config)
+	},
+}
+
+var resumeCommand = command{
+	Name:    "resume",
+	Usage:   "resume --manifest=<path> --stack=<stack> [options]",
+	Summary: "Continue the deployment which stopped in the middle",
+	Flags: func(fs *flag.FlagSet, c *builder.Config) {
+		builder.AddStackFlags(fs, c)
+		fs.StringVar(&c.StateFile, "state-file", "", "Path of the file which has progress of deployment (default .goployer/state/<manifest>_<stack>.json)")
+	},
+	Run: func(config builder.Config, args []string) error {
+		return runner.Resume(config)
+	},
+}
+
+var validateCommand = command{
+	Name:    "validate",
+	Usage:   "validate --manifest=<path> --stack=<stack> [options]",
+	Summary: "Validate the manifest and options without changing any resources",
+	Flags: func(fs *flag.FlagSet, c *builder.Config) {
+		builder.AddStackFlags(fs, c)
+		builder.AddDeploymentFlags(fs, c)
+		fs.BoolVar(&c.DisableMetrics, "disable-metrics", false, "Disable gathering metrics")
+		fs.BoolVar(&c.SkipPreflight, "skip-preflight", false, "Check only the manifest without looking up resources in AWS")
+	},
+	Run: func(config builder.Config, args []string) error {
+		return runner.Validate(config)
+	},
+}
+
+var statusCommand = command{
+	Name:    "status",
+	Usage:   "status --manifest=<path> --stack=<stack> [options]",
+	Summary: "Show autoscaling groups of the stack",
+	Flags: func(fs *flag.FlagSet, c *builder.Config) {
+		builder.AddStackFlags(fs, c)
+		fs.StringVar(&c.Output, "output", builder.OUTPUT_FORMAT_TABLE, "Output format: table or json")
+		fs.BoolVar(&c.DisableMetrics, "disable-metrics", false, "Disable reading status from the metrics table")
+	},
+	Run: func(config builder.Config, args []string) error {
+		return runner.Status(config)
+	},
+}
+
+var historyCommand = command{
+	Name:    "history",
+	Usage:   "history --manifest=<path> --stack=<stack> [options]",
+	Summary: "Show deployment history of the stack",
+	Flags: func(fs *flag.FlagSet, c *builder.Config) {
+		builder.AddStackFlags(fs, c)
+		fs.StringVar(&c.Since, "since", "", "Show deployments started at or after the date like 2020-07-01 (KST) or RFC3339 timestamp")
+		fs.StringVar(&c.Until, "until", "", "Show deployments started at or before the date like 2020-07-31 (KST) or RFC3339 timestamp")
+		fs.StringVar(&c.Output, "output", builder.OUTPUT_FORMAT_TABLE, "Output format: table or json")
+	},
+	Run: func(config builder.Config, args []string) error {
+		return runner.History(config)
+	},
+}
+
+var rollbackCommand = command{
+	Name:    "rollback",
+	Usage:   "rollback --manifest=<path> --stack=<stack> [options]",
+	Summary: "Reactivate the previous version of the stack",
+	Flags: func(fs *flag.FlagSet, c *builder.Config) {
+		builder.AddStackFlags(fs, c)
+		builder.AddExecutionFlags(fs, c)
+	},
+	Run: func(config builder.Config, args []string) error {
+		return runner.Rollback(config)
+	},
+}
+
+var deleteCommand = command{
+	Name:    "delete",
+	Usage:   "delete --manifest=<path> --stack=<stack> [options]",
+	Summary: "Delete every version of the stack",
+	Flags: func(fs *flag.FlagSet, c *builder.Config) {
+		builder.AddStackFlags(fs, c)
+		builder.AddExecutionFlags(fs, c)
+	},
+	Run: func(config builder.Config, args []string) error {
+		return runner.Delete(config)
+	},
+}
+
+var unlockCommand = command{
+	Name:    "unlock",
+	Usage:   "unlock --manifest=<path> --stack=<stack> [options]",
+	Summary: "Delete deployment locks of the stack left by dead processes",
+	Flags:   builder.AddStackFlags,
+	Run: func(config builder.Config, args []string) error {
+		return runner.Unlock(config)
+	},
+}
+
+var schemaCommand = command{
+	Name:    "schema",
+	Usage:   "schema",
+	Summary: "Print JSON schema of manifest for editors",
+	Run: func(config builder.Config, args []string) error {
+		schema, err := builder.GenerateSchema()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(schema))
+		return nil
+	},
+}
+
+var versionCommand = command{
+	Name:    "version",
+	Usage:   "version",
+	Summary: "Print the version of goployer",
+	Run: func(config builder.Config, args []string) error {
+		fmt.Println(version.Get().String())
+		return nil
+	},
+}
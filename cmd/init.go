@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var initOptions struct {
+	Name  string
+	Force bool
+}
+
+var initCommand = command{
+	Name:    "init",
+	Usage:   "init --name=<application> [--manifest=<path>]",
+	Summary: "Create a sample manifest for a new application",
+	Flags: func(fs *flag.FlagSet, c *builder.Config) {
+		fs.StringVar(&c.Manifest, "manifest", "", "The path of manifest file to create (default configs/<name>.yaml)")
+		fs.StringVar(&initOptions.Name, "name", "", "The name of application")
+		fs.BoolVar(&initOptions.Force, "force", false, "Overwrite the manifest file if it already exists")
+	},
+	Run: func(config builder.Config, args []string) error {
+		if len(initOptions.Name) == 0 {
+			return fmt.Errorf("you should specify application name with --name")
+		}
+
+		path := config.Manifest
+		if len(path) == 0 {
+			path = filepath.Join("configs", fmt.Sprintf("%s.yaml", initOptions.Name))
+		}
+
+		if _, err := os.Stat(path); err == nil && !initOptions.Force {
+			return fmt.Errorf("manifest file already exists : %s", path)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		manifest := strings.Replace(sampleManifest, "{{name}}", initOptions.Name, -1)
+		if err := ioutil.WriteFile(path, []byte(manifest), 0644); err != nil {
+			return err
+		}
+
+		fmt.Printf("manifest is created : %s\n", path)
+
+		return nil
+	},
+}
+
+// sampleManifest is the template of manifest created by init command
+const sampleManifest = `---
+name: {{name}}
+userdata:
+  type: local
+  path: scripts/userdata.sh
+
+# Tags should be like "key=value"
+tags:
+  - app={{name}}
+
+stacks:
+  - stack: dev
+
+    # account alias
+    account: dev
+
+    # environment variable
+    env: dev
+
+    # assume_role for deployment
+    assume_role: ""
+
+    # Replacement type : BlueGreen, Rolling or Canary
+    replacement_type: BlueGreen
+
+    # IAM instance profile
+    iam_instance_profile: ""
+
+    # ebs optimized
+    ebs_optimized: false
+
+    # capacity of autoscaling group
+    capacity:
+      min: 1
+      max: 2
+      desired: 1
+
+    # list of regions
+    regions:
+      - region: ap-northeast-2
+        instance_type: t3.medium
+        ssh_key: ""
+        ami_id: ""
+        use_public_subnets: false
+        vpc: ""
+        security_groups: []
+        healthcheck_target_group: ""
+        target_groups: []
+        availability_zones: []
+`
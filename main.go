@@ -1,14 +1,14 @@
 package main
 
 import (
-	"github.com/DevopsArtFactory/goployer/pkg/runner"
+	"github.com/DevopsArtFactory/goployer/cmd"
 	Logger "github.com/sirupsen/logrus"
 	"os"
 )
 
 func main() {
-	//Create new builder
-	if err := runner.Start(); err != nil {
+	//Run the subcommand
+	if err := cmd.Execute(os.Args[1:]); err != nil {
 		Logger.Error(err.Error())
 		os.Exit(1)
 	}
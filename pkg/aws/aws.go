@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
@@ -13,18 +14,30 @@ var (
 )
 
 type AWSClient struct {
-	Region            string
-	EC2Service        EC2Client
-	ELBService        ELBV2Client
-	CloudWatchService CloudWatchClient
-	SSMService        SSMClient
+	Region                string
+	EC2Service            EC2API
+	ELBService            ELBV2API
+	CloudWatchService     CloudWatchAPI
+	SSMService            SSMAPI
+	S3Service             S3API
+	SecretsManagerService SecretsManagerAPI
+	IAMService            IAMAPI
+	STSService            STSAPI
 }
 
 type MetricClient struct {
 	Region          string
-	DynamoDBService DynamoDBClient
+	DynamoDBService DynamoDBAPI
 }
 
+// Bootstrapper creates service clients of the region
+// Tests can replace it to run deployments against fake services.
+type Bootstrapper func(region string, assume_role string) AWSClient
+
+// MetricBootstrapper creates the client of metric storage in the region
+// Tests can replace it so that deployment records and locks are kept in fake storage.
+type MetricBootstrapper func(region string, assume_role string) MetricClient
+
 func getAwsSession() *session.Session {
 	mySession := session.Must(session.NewSession())
 	return mySession
@@ -53,16 +66,29 @@ func BootstrapServices(region string, assume_role string) AWSClient {
 
 	//Get all clients
 	client := AWSClient{
-		Region:            region,
-		EC2Service:        NewEC2Client(aws_session, region, creds),
-		ELBService:        NewELBV2Client(aws_session, region, creds),
-		CloudWatchService: NewCloudWatchClient(aws_session, region, creds),
-		SSMService:        NewSSMClient(aws_session, region, creds),
+		Region:                region,
+		EC2Service:            NewEC2Client(aws_session, region, creds),
+		ELBService:            NewELBV2Client(aws_session, region, creds),
+		CloudWatchService:     NewCloudWatchClient(aws_session, region, creds),
+		SSMService:            NewSSMClient(aws_session, region, creds),
+		S3Service:             NewS3Client(aws_session, region, creds),
+		SecretsManagerService: NewSecretsManagerClient(aws_session, region, creds),
+		IAMService:            NewIAMClient(aws_session, region, creds),
+		STSService:            NewSTSClient(aws_session, region, creds),
 	}
 
 	return client
 }
 
+// UserdataClients returns clients with which userdata is read from AWS
+func (a AWSClient) UserdataClients() builder.UserdataClients {
+	return builder.UserdataClients{
+		S3:             a.S3Service,
+		SSM:            a.SSMService,
+		SecretsManager: a.SecretsManagerService,
+	}
+}
+
 func BootstrapMetricService(region string, assume_role string) MetricClient {
 	aws_session := getAwsSession()
 
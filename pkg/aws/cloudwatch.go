@@ -9,10 +9,22 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	Logger "github.com/sirupsen/logrus"
+	"strings"
+	"time"
 )
 
+// CloudWatchAPI is the interface of cloudwatch operations which goployer uses
+type CloudWatchAPI interface {
+	CreateScalingAlarms(asg_name string, alarms []builder.AlarmConfigs, policyArns map[string]string) error
+	CreateCloudWatchAlarm(asg_name string, alarm builder.AlarmConfigs) error
+	GetAlarmsInAlarmState(alarmNames []string) ([]string, error)
+	GetExistingAlarms(alarmNames []string) ([]string, error)
+	GetMetricDatapoints(asg_name string, metric builder.CanaryMetric, start, end time.Time) ([]float64, error)
+}
+
 type CloudWatchClient struct {
-	Client *cloudwatch.CloudWatch
+	Client   *cloudwatch.CloudWatch
+	Recorder *Recorder
 }
 
 func NewCloudWatchClient(session *session.Session, region string, creds *credentials.Credentials) CloudWatchClient {
@@ -69,6 +81,10 @@ func (c CloudWatchClient) CreateCloudWatchAlarm(asg_name string, alarm builder.A
 		},
 	}
 
+	if c.Recorder.Record("PutMetricAlarm", alarm.Name, input) {
+		return nil
+	}
+
 	_, err := c.Client.PutMetricAlarm(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -90,3 +106,123 @@ func (c CloudWatchClient) CreateCloudWatchAlarm(asg_name string, alarm builder.A
 
 	return nil
 }
+
+// GetAlarmsInAlarmState returns names of alarms which are in ALARM state
+func (c CloudWatchClient) GetAlarmsInAlarmState(alarmNames []string) ([]string, error) {
+	if len(alarmNames) == 0 {
+		return nil, nil
+	}
+
+	input := &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: MakeStringArrayToAwsStrings(alarmNames),
+		StateValue: aws.String(cloudwatch.StateValueAlarm),
+	}
+
+	result, err := c.Client.DescribeAlarms(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case cloudwatch.ErrCodeInvalidNextToken:
+				Logger.Errorln(cloudwatch.ErrCodeInvalidNextToken, aerr.Error())
+			default:
+				Logger.Errorln(aerr.Error())
+			}
+		} else {
+			// Print the error, cast err to awserr.Error to get the Code and
+			// Message from an error.
+			Logger.Errorln(err.Error())
+		}
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, alarm := range result.MetricAlarms {
+		ret = append(ret, *alarm.AlarmName)
+	}
+
+	return ret, nil
+}
+
+// GetMetricDatapoints returns values of the metric for autoscaling group during the time range
+func (c CloudWatchClient) GetMetricDatapoints(asg_name string, metric builder.CanaryMetric, start, end time.Time) ([]float64, error) {
+	period := metric.Period
+	if period <= 0 {
+		period = 60
+	}
+
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(metric.Namespace),
+		MetricName: aws.String(metric.Metric),
+		Statistics: []*string{aws.String(metric.Statistic)},
+		Period:     aws.Int64(period),
+		StartTime:  aws.Time(start),
+		EndTime:    aws.Time(end),
+		Dimensions: []*cloudwatch.Dimension{
+			{
+				Name:  aws.String("AutoScalingGroupName"),
+				Value: aws.String(asg_name),
+			},
+		},
+	}
+
+	result, err := c.Client.GetMetricStatistics(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case cloudwatch.ErrCodeInvalidParameterValueException:
+				Logger.Errorln(cloudwatch.ErrCodeInvalidParameterValueException, aerr.Error())
+			case cloudwatch.ErrCodeMissingRequiredParameterException:
+				Logger.Errorln(cloudwatch.ErrCodeMissingRequiredParameterException, aerr.Error())
+			default:
+				Logger.Errorln(aerr.Error())
+			}
+		} else {
+			// Print the error, cast err to awserr.Error to get the Code and
+			// Message from an error.
+			Logger.Errorln(err.Error())
+		}
+		return nil, err
+	}
+
+	ret := []float64{}
+	for _, dp := range result.Datapoints {
+		switch metric.Statistic {
+		case cloudwatch.StatisticSum:
+			ret = append(ret, *dp.Sum)
+		case cloudwatch.StatisticMaximum:
+			ret = append(ret, *dp.Maximum)
+		case cloudwatch.StatisticMinimum:
+			ret = append(ret, *dp.Minimum)
+		case cloudwatch.StatisticSampleCount:
+			ret = append(ret, *dp.SampleCount)
+		default:
+			ret = append(ret, *dp.Average)
+		}
+	}
+
+	return ret, nil
+}
+
+// GetExistingAlarms returns names of alarms which exist among alarmNames
+func (c CloudWatchClient) GetExistingAlarms(alarmNames []string) ([]string, error) {
+	ret := []string{}
+	if len(alarmNames) == 0 {
+		return ret, nil
+	}
+
+	input := &cloudwatch.DescribeAlarmsInput{
+		AlarmNames: MakeStringArrayToAwsStrings(alarmNames),
+	}
+
+	err := c.Client.DescribeAlarmsPages(input, func(page *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+		for _, alarm := range page.MetricAlarms {
+			ret = append(ret, *alarm.AlarmName)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, &LookupError{Resource: "alarms", Name: strings.Join(alarmNames, ","), Err: err}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,174 @@
+package aws
+
+import (
+	"fmt"
+	Logger "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+)
+
+// Change is a mutating call which is recorded instead of being executed
+type Change struct {
+	Region   string
+	Action   string
+	Resource string
+	Input    fmt.Stringer
+}
+
+// Recorder records mutating calls of service clients in dry-run mode
+// Clients with nil recorder execute calls as usual.
+type Recorder struct {
+	region  string
+	mu      *sync.Mutex
+	changes *[]Change
+}
+
+// NewRecorder creates a recorder for dry-run mode
+func NewRecorder() *Recorder {
+	return &Recorder{
+		mu:      &sync.Mutex{},
+		changes: &[]Change{},
+	}
+}
+
+// ForRegion returns a recorder of the region which shares changes with r
+func (r *Recorder) ForRegion(region string) *Recorder {
+	if r == nil {
+		return nil
+	}
+
+	return &Recorder{
+		region:  region,
+		mu:      r.mu,
+		changes: r.changes,
+	}
+}
+
+// Enabled returns true if calls should be recorded instead of being executed
+func (r *Recorder) Enabled() bool {
+	return r != nil
+}
+
+// Record stores the input of mutating call
+// It returns false if the recorder is disabled so that the caller executes the call.
+func (r *Recorder) Record(action, resource string, input fmt.Stringer) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	*r.changes = append(*r.changes, Change{
+		Region:   r.region,
+		Action:   action,
+		Resource: resource,
+		Input:    input,
+	})
+
+	Logger.Infof("[dry-run] %s : %s (%s)", action, resource, r.region)
+
+	return true
+}
+
+// Changes returns all changes recorded in order
+func (r *Recorder) Changes() []Change {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Change{}, *r.changes...)
+}
+
+// Summary makes the plan of changes to print
+func (r *Recorder) Summary() string {
+	changes := r.Changes()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n========= Dry-run: %d change(s) will be made =========\n", len(changes)))
+	for i, c := range changes {
+		sb.WriteString(fmt.Sprintf("\n%d. [%s] %s : %s\n", i+1, c.Region, c.Action, c.Resource))
+		if c.Input != nil {
+			sb.WriteString(c.Input.String())
+			sb.WriteString("\n")
+		}
+	}
+
+	if deleted := r.deletedAutoscalingGroups(changes); len(deleted) > 0 {
+		sb.WriteString(fmt.Sprintf("\nAutoscaling groups to be deleted : %s\n", strings.Join(deleted, ", ")))
+	}
+
+	return sb.String()
+}
+
+// deletedAutoscalingGroups returns names of autoscaling groups in delete calls
+func (r *Recorder) deletedAutoscalingGroups(changes []Change) []string {
+	ret := []string{}
+	for _, c := range changes {
+		if c.Action == "DeleteAutoScalingGroup" {
+			ret = append(ret, c.Resource)
+		}
+	}
+	return ret
+}
+
+// WithRecorder returns clients whose mutating calls are recorded by recorder
+// Clients which do not change AWS resources, like S3 and IAM, are kept as they are.
+func (a AWSClient) WithRecorder(recorder *Recorder) AWSClient {
+	r := recorder.ForRegion(a.Region)
+
+	if c, ok := a.EC2Service.(interface{ WithRecorder(*Recorder) EC2API }); ok {
+		a.EC2Service = c.WithRecorder(r)
+	}
+	if c, ok := a.ELBService.(interface{ WithRecorder(*Recorder) ELBV2API }); ok {
+		a.ELBService = c.WithRecorder(r)
+	}
+	if c, ok := a.CloudWatchService.(interface{ WithRecorder(*Recorder) CloudWatchAPI }); ok {
+		a.CloudWatchService = c.WithRecorder(r)
+	}
+	if c, ok := a.SSMService.(interface{ WithRecorder(*Recorder) SSMAPI }); ok {
+		a.SSMService = c.WithRecorder(r)
+	}
+	return a
+}
+
+// WithRecorder returns metric client whose mutating calls are recorded by recorder
+func (m MetricClient) WithRecorder(recorder *Recorder) MetricClient {
+	if c, ok := m.DynamoDBService.(interface{ WithRecorder(*Recorder) DynamoDBAPI }); ok {
+		m.DynamoDBService = c.WithRecorder(recorder.ForRegion(m.Region))
+	}
+	return m
+}
+
+// WithRecorder returns the client whose mutating calls are recorded by recorder
+func (e EC2Client) WithRecorder(recorder *Recorder) EC2API {
+	e.Recorder = recorder
+	return e
+}
+
+// WithRecorder returns the client whose mutating calls are recorded by recorder
+func (e ELBV2Client) WithRecorder(recorder *Recorder) ELBV2API {
+	e.Recorder = recorder
+	return e
+}
+
+// WithRecorder returns the client whose mutating calls are recorded by recorder
+func (c CloudWatchClient) WithRecorder(recorder *Recorder) CloudWatchAPI {
+	c.Recorder = recorder
+	return c
+}
+
+// WithRecorder returns the client whose mutating calls are recorded by recorder
+func (s SSMClient) WithRecorder(recorder *Recorder) SSMAPI {
+	s.Recorder = recorder
+	return s
+}
+
+// WithRecorder returns the client whose mutating calls are recorded by recorder
+func (d DynamoDBClient) WithRecorder(recorder *Recorder) DynamoDBAPI {
+	d.Recorder = recorder
+	return d
+}
@@ -15,15 +15,41 @@ import (
 var (
 	hashKey            = "identifier"
 	statusTimeStampKey = map[string]string{
-		"deployed":   "deployed_date_kst",
-		"terminated": "terminated_date_kst",
+		"deployed":    "deployed_date_kst",
+		"terminated":  "terminated_date_kst",
+		"rolled_back": "rolled_back_date_kst",
+		"abandoned":   "abandoned_date_kst",
 	}
 	DEFAULT_READ_THROUGHPUT  = int64(5)
 	DEFAULT_WRITE_THROUGHPUT = int64(5)
+	lockHolderKey            = "lock_holder"
+	lockExpiresKey           = "lock_expires_at"
+
+	// Deployment records are looked up by the application in the order of start time
+	// Lock items do not have the application so that they are not in the index.
+	HISTORY_INDEX_NAME = "app-start_date_kst-index"
+	historyHashKey     = "app"
+	historyRangeKey    = "start_date_kst"
 )
 
+// DynamoDBAPI is the interface of dynamodb operations which goployer uses
+type DynamoDBAPI interface {
+	CheckTableExists(tableName string) (bool, error)
+	CreateTable(tableName string) error
+	MakeRecord(stack, config, tags string, asg string, tableName string, status string, additionalFields map[string]string) error
+	UpdateRecord(updateKey, asg string, tableName string, status string, updateFields map[string]string) error
+	GetSingleItem(asg, tableName string) (map[string]*dynamodb.AttributeValue, error)
+	AcquireLock(key, holder, tableName string, ttl time.Duration) (bool, error)
+	RenewLock(key, holder, tableName string, ttl time.Duration) error
+	ReleaseLock(key, holder, tableName string) error
+	CheckIndexExists(tableName, indexName string) (bool, error)
+	CreateHistoryIndex(tableName string) error
+	QueryHistory(app, since, until string, tableName string) ([]map[string]*dynamodb.AttributeValue, error)
+}
+
 type DynamoDBClient struct {
-	Client *dynamodb.DynamoDB
+	Client   *dynamodb.DynamoDB
+	Recorder *Recorder
 }
 
 func NewDynamoDBClient(session *session.Session, region string, creds *credentials.Credentials) DynamoDBClient {
@@ -78,6 +104,14 @@ func (d DynamoDBClient) CreateTable(tableName string) error {
 				AttributeName: aws.String(hashKey),
 				AttributeType: aws.String("S"),
 			},
+			{
+				AttributeName: aws.String(historyHashKey),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String(historyRangeKey),
+				AttributeType: aws.String("S"),
+			},
 		},
 		KeySchema: []*dynamodb.KeySchemaElement{
 			{
@@ -85,6 +119,9 @@ func (d DynamoDBClient) CreateTable(tableName string) error {
 				KeyType:       aws.String("HASH"),
 			},
 		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			historyIndex(),
+		},
 		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
 			ReadCapacityUnits:  aws.Int64(DEFAULT_WRITE_THROUGHPUT),
 			WriteCapacityUnits: aws.Int64(DEFAULT_READ_THROUGHPUT),
@@ -92,6 +129,10 @@ func (d DynamoDBClient) CreateTable(tableName string) error {
 		TableName: aws.String(tableName),
 	}
 
+	if d.Recorder.Record("CreateTable", tableName, input) {
+		return nil
+	}
+
 	_, err := d.Client.CreateTable(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -147,6 +188,10 @@ func (d DynamoDBClient) MakeRecord(stack, config, tags string, asg string, table
 		}
 	}
 
+	if d.Recorder.Record("PutItem", asg, input) {
+		return nil
+	}
+
 	_, err := d.Client.PutItem(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -218,6 +263,10 @@ func (d DynamoDBClient) UpdateRecord(updateKey, asg string, tableName string, st
 		}
 	}
 
+	if d.Recorder.Record("UpdateItem", asg, input) {
+		return nil
+	}
+
 	_, err := d.Client.UpdateItem(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -287,3 +336,306 @@ func (d DynamoDBClient) GetSingleItem(asg, tableName string) (map[string]*dynamo
 
 	return result.Item, err
 }
+
+// AcquireLock puts the lock item only if nobody holds it or the lock of other holder is expired
+// It returns false without error if other holder has the lock.
+func (d DynamoDBClient) AcquireLock(key, holder, tableName string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	input := &dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			hashKey: {
+				S: aws.String(key),
+			},
+			lockHolderKey: {
+				S: aws.String(holder),
+			},
+			lockExpiresKey: {
+				N: aws.String(fmt.Sprintf("%d", now.Add(ttl).Unix())),
+			},
+			"acquired_date_kst": {
+				S: aws.String(tool.GetKstTimestamp().Format(time.RFC3339)),
+			},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#K) OR #E < :now OR #H = :holder"),
+		ExpressionAttributeNames: map[string]*string{
+			"#K": aws.String(hashKey),
+			"#E": aws.String(lockExpiresKey),
+			"#H": aws.String(lockHolderKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {
+				N: aws.String(fmt.Sprintf("%d", now.Unix())),
+			},
+			":holder": {
+				S: aws.String(holder),
+			},
+		},
+		TableName: aws.String(tableName),
+	}
+
+	if d.Recorder.Record("PutItem", key, input) {
+		return true, nil
+	}
+
+	_, err := d.Client.PutItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		logDynamoDBError(err)
+		return false, err
+	}
+
+	Logger.Debugf("lock is acquired : %s", key)
+
+	return true, nil
+}
+
+// RenewLock extends the expiration of the lock which the holder has
+func (d DynamoDBClient) RenewLock(key, holder, tableName string, ttl time.Duration) error {
+	input := &dynamodb.UpdateItemInput{
+		ConditionExpression: aws.String("#H = :holder"),
+		ExpressionAttributeNames: map[string]*string{
+			"#H": aws.String(lockHolderKey),
+			"#E": aws.String(lockExpiresKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":holder": {
+				S: aws.String(holder),
+			},
+			":expires": {
+				N: aws.String(fmt.Sprintf("%d", time.Now().Add(ttl).Unix())),
+			},
+		},
+		Key: map[string]*dynamodb.AttributeValue{
+			hashKey: {
+				S: aws.String(key),
+			},
+		},
+		TableName:        aws.String(tableName),
+		UpdateExpression: aws.String("SET #E = :expires"),
+	}
+
+	if d.Recorder.Record("UpdateItem", key, input) {
+		return nil
+	}
+
+	_, err := d.Client.UpdateItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("lock is not held by %s anymore : %s", holder, key)
+		}
+		logDynamoDBError(err)
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseLock deletes the lock item which the holder has
+// If holder is empty, then the lock is deleted regardless of the holder.
+func (d DynamoDBClient) ReleaseLock(key, holder, tableName string) error {
+	input := &dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			hashKey: {
+				S: aws.String(key),
+			},
+		},
+		TableName: aws.String(tableName),
+	}
+
+	if len(holder) > 0 {
+		input.ConditionExpression = aws.String("#H = :holder")
+		input.ExpressionAttributeNames = map[string]*string{
+			"#H": aws.String(lockHolderKey),
+		}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":holder": {
+				S: aws.String(holder),
+			},
+		}
+	}
+
+	if d.Recorder.Record("DeleteItem", key, input) {
+		return nil
+	}
+
+	_, err := d.Client.DeleteItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("lock is not held by %s anymore : %s", holder, key)
+		}
+		logDynamoDBError(err)
+		return err
+	}
+
+	Logger.Debugf("lock is released : %s", key)
+
+	return nil
+}
+
+// historyIndex returns the global secondary index of deployment records of each application
+func historyIndex() *dynamodb.GlobalSecondaryIndex {
+	return &dynamodb.GlobalSecondaryIndex{
+		IndexName: aws.String(HISTORY_INDEX_NAME),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String(historyHashKey),
+				KeyType:       aws.String("HASH"),
+			},
+			{
+				AttributeName: aws.String(historyRangeKey),
+				KeyType:       aws.String("RANGE"),
+			},
+		},
+		Projection: &dynamodb.Projection{
+			ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(DEFAULT_READ_THROUGHPUT),
+			WriteCapacityUnits: aws.Int64(DEFAULT_WRITE_THROUGHPUT),
+		},
+	}
+}
+
+// CheckIndexExists checks if the table has the global secondary index
+func (d DynamoDBClient) CheckIndexExists(tableName, indexName string) (bool, error) {
+	result, err := d.Client.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		logDynamoDBError(err)
+		return false, err
+	}
+
+	if result.Table == nil {
+		return false, nil
+	}
+
+	for _, index := range result.Table.GlobalSecondaryIndexes {
+		if aws.StringValue(index.IndexName) == indexName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// CreateHistoryIndex adds the index of deployment history to the table which was created without it
+// Records stamped before the index is created are not in the index if they do not have the application.
+func (d DynamoDBClient) CreateHistoryIndex(tableName string) error {
+	index := historyIndex()
+	input := &dynamodb.UpdateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String(historyHashKey),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String(historyRangeKey),
+				AttributeType: aws.String("S"),
+			},
+		},
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+			{
+				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName:             index.IndexName,
+					KeySchema:             index.KeySchema,
+					Projection:            index.Projection,
+					ProvisionedThroughput: index.ProvisionedThroughput,
+				},
+			},
+		},
+		TableName: aws.String(tableName),
+	}
+
+	if d.Recorder.Record("UpdateTable", tableName, input) {
+		return nil
+	}
+
+	if _, err := d.Client.UpdateTable(input); err != nil {
+		logDynamoDBError(err)
+		return err
+	}
+
+	return nil
+}
+
+// QueryHistory returns deployment records of the application which started in the range, latest first
+// since and until are RFC3339 timestamps in KST, and empty one means the range is open on that side.
+func (d DynamoDBClient) QueryHistory(app, since, until string, tableName string) ([]map[string]*dynamodb.AttributeValue, error) {
+	condition := "#A = :app"
+	values := map[string]*dynamodb.AttributeValue{
+		":app": {
+			S: aws.String(app),
+		},
+	}
+
+	switch {
+	case len(since) > 0 && len(until) > 0:
+		condition = fmt.Sprintf("%s AND #D BETWEEN :since AND :until", condition)
+	case len(since) > 0:
+		condition = fmt.Sprintf("%s AND #D >= :since", condition)
+	case len(until) > 0:
+		condition = fmt.Sprintf("%s AND #D <= :until", condition)
+	}
+
+	names := map[string]*string{
+		"#A": aws.String(historyHashKey),
+	}
+
+	if len(since) > 0 {
+		values[":since"] = &dynamodb.AttributeValue{S: aws.String(since)}
+		names["#D"] = aws.String(historyRangeKey)
+	}
+
+	if len(until) > 0 {
+		values[":until"] = &dynamodb.AttributeValue{S: aws.String(until)}
+		names["#D"] = aws.String(historyRangeKey)
+	}
+
+	input := &dynamodb.QueryInput{
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		IndexName:                 aws.String(HISTORY_INDEX_NAME),
+		KeyConditionExpression:    aws.String(condition),
+		ScanIndexForward:          aws.Bool(false),
+		TableName:                 aws.String(tableName),
+	}
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	err := d.Client.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return !lastPage
+	})
+	if err != nil {
+		logDynamoDBError(err)
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// logDynamoDBError prints error from dynamodb API
+func logDynamoDBError(err error) {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case dynamodb.ErrCodeProvisionedThroughputExceededException:
+			fmt.Println(dynamodb.ErrCodeProvisionedThroughputExceededException, aerr.Error())
+		case dynamodb.ErrCodeResourceNotFoundException:
+			fmt.Println(dynamodb.ErrCodeResourceNotFoundException, aerr.Error())
+		case dynamodb.ErrCodeTransactionConflictException:
+			fmt.Println(dynamodb.ErrCodeTransactionConflictException, aerr.Error())
+		case dynamodb.ErrCodeRequestLimitExceeded:
+			fmt.Println(dynamodb.ErrCodeRequestLimitExceeded, aerr.Error())
+		case dynamodb.ErrCodeInternalServerError:
+			fmt.Println(dynamodb.ErrCodeInternalServerError, aerr.Error())
+		default:
+			fmt.Println(aerr.Error())
+		}
+	} else {
+		// Print the error, cast err to awserr.Error to get the Code and
+		// Message from an error.
+		fmt.Println(err.Error())
+	}
+}
@@ -11,20 +11,57 @@ import (
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	Logger "github.com/sirupsen/logrus"
-	"os"
 	"regexp"
 	"strings"
 )
 
+// EC2API is the interface of EC2 and autoscaling operations which goployer uses
+type EC2API interface {
+	GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error)
+	GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error)
+	DeleteLaunchConfigurations(asg_name string) error
+	DeleteLaunchTemplates(asg_name string) error
+	DeleteLaunchTemplate(lt_name string) error
+	DeleteAutoscalingSet(asg_name string) bool
+	ForceDeleteAutoscalingSet(asg_name string) bool
+	CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) error
+	CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions builder.InstanceMarketOptions) error
+	GetSecurityGroupList(vpc string, sgList []string) ([]*string, error)
+	MakeBlockDevices(blocks []builder.BlockDevice) []*autoscaling.BlockDeviceMapping
+	MakeLaunchTemplateBlockDeviceMappings(blocks []builder.BlockDevice) []*ec2.LaunchTemplateBlockDeviceMappingRequest
+	GetVPCId(vpc string) (string, error)
+	CreateAutoScalingGroup(name, launch_template_name, healthcheck_type string, healthcheck_grace_period int64, capacity builder.Capacity, loadbalancers, target_group_arns, termination_policies, availability_zones []*string, tags []*(autoscaling.Tag), subnets []string, mixedInstancePolicy builder.MixedInstancesPolicy, hooks []*autoscaling.LifecycleHookSpecification) error
+	GenerateTags(tagList []string, asg_name, app, stack, ansibleTags, extraTags, ansibleExtraVars, region string) []*autoscaling.Tag
+	GetAvailabilityZones(vpc string, azs []string) ([]string, error)
+	GetSubnets(vpc string, use_public_subnets bool, azs []string) ([]string, error)
+	UpdateAutoScalingGroup(asg string, min, max, desired int64) error
+	UpdateAutoScalingGroupLaunchTemplate(asg, launch_template_name string, mixedInstancePolicyEnabled bool) error
+	TerminateInstanceInAutoScalingGroup(instanceId string) error
+	CreateScalingPolicy(policy builder.ScalePolicy, asg_name string) (*string, error)
+	EnableMetrics(asg_name string) error
+	TagAutoScalingGroup(asg_name string, tags map[string]string) error
+	GenerateLifecycleHooks(hooks builder.LifecycleHooks) []*autoscaling.LifecycleHookSpecification
+	ResolveAmi(selector builder.AmiSelector) (string, error)
+	CheckImage(ami string) error
+	CheckKeyPair(name string) error
+	GetInstanceTypeZones(instanceType string) ([]string, error)
+	GetLaunchTemplateData(name, version string) (*ec2.ResponseLaunchTemplateData, error)
+}
+
 type EC2Client struct {
 	Client   *ec2.EC2
 	AsClient *autoscaling.AutoScaling
+	Recorder *Recorder
+
+	// AMI ID can be stored in SSM parameter
+	SsmClient SSMClient
 }
 
 func NewEC2Client(session *session.Session, region string, creds *credentials.Credentials) EC2Client {
 	return EC2Client{
-		Client:   getEC2ClientFn(session, region, creds),
-		AsClient: getAsgClientFn(session, region, creds),
+		Client:    getEC2ClientFn(session, region, creds),
+		AsClient:  getAsgClientFn(session, region, creds),
+		SsmClient: NewSSMClient(session, region, creds),
 	}
 }
 
@@ -42,10 +79,12 @@ func getAsgClientFn(session *session.Session, region string, creds *credentials.
 	return autoscaling.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
 }
 
-func (e EC2Client) GetMatchingAutoscalingGroup(name string) *autoscaling.Group {
+func (e EC2Client) GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error) {
 
-	asgGroups := []*autoscaling.Group{}
-	asgGroups = getAutoScalingGroups(e.AsClient, asgGroups, nil)
+	asgGroups, err := getAutoScalingGroups(e.AsClient, []*autoscaling.Group{}, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	ret := []*autoscaling.Group{}
 	for _, asgGroup := range asgGroups {
@@ -55,10 +94,10 @@ func (e EC2Client) GetMatchingAutoscalingGroup(name string) *autoscaling.Group {
 	}
 
 	if len(ret) > 0 {
-		return ret[0]
+		return ret[0], nil
 	}
 
-	return nil
+	return nil, nil
 }
 
 // Delete All Launch Configurations belongs to the autoscaling group
@@ -67,6 +106,10 @@ func (e EC2Client) DeleteLaunchConfigurations(asg_name string) error {
 
 	for _, lc := range lcs {
 		if strings.HasPrefix(*lc.LaunchConfigurationName, asg_name) {
+			if e.Recorder.Record("DeleteLaunchConfiguration", *lc.LaunchConfigurationName, &autoscaling.DeleteLaunchConfigurationInput{LaunchConfigurationName: lc.LaunchConfigurationName}) {
+				continue
+			}
+
 			err := deleteLaunchConfiguration(e.AsClient, *lc.LaunchConfigurationName)
 			if err != nil {
 				return err
@@ -83,6 +126,10 @@ func (e EC2Client) DeleteLaunchTemplates(asg_name string) error {
 
 	for _, lt := range lts {
 		if strings.HasPrefix(*lt.LaunchTemplateName, asg_name) {
+			if e.Recorder.Record("DeleteLaunchTemplate", *lt.LaunchTemplateName, &ec2.DeleteLaunchTemplateInput{LaunchTemplateName: lt.LaunchTemplateName}) {
+				continue
+			}
+
 			err := deleteLaunchTemplate(e.Client, *lt.LaunchTemplateName)
 			if err != nil {
 				return err
@@ -97,8 +144,24 @@ func (e EC2Client) DeleteLaunchTemplates(asg_name string) error {
 // 1. Autoscaling Group
 // 2. Luanch Configurations in asg
 func (e EC2Client) DeleteAutoscalingSet(asg_name string) bool {
+	return e.deleteAutoscalingGroup(asg_name, false)
+}
+
+// ForceDeleteAutoscalingSet deletes autoscaling group without waiting for instances to be terminated
+// Instances in the autoscaling group are terminated together.
+func (e EC2Client) ForceDeleteAutoscalingSet(asg_name string) bool {
+	return e.deleteAutoscalingGroup(asg_name, true)
+}
+
+// deleteAutoscalingGroup deletes single autoscaling group
+func (e EC2Client) deleteAutoscalingGroup(asg_name string, forceDelete bool) bool {
 	input := &autoscaling.DeleteAutoScalingGroupInput{
 		AutoScalingGroupName: aws.String(asg_name),
+		ForceDelete:          aws.Bool(forceDelete),
+	}
+
+	if e.Recorder.Record("DeleteAutoScalingGroup", asg_name, input) {
+		return true
 	}
 
 	_, err := e.AsClient.DeleteAutoScalingGroup(input)
@@ -127,9 +190,11 @@ func (e EC2Client) DeleteAutoscalingSet(asg_name string) bool {
 
 // Get All matching autoscaling groups with aws prefix
 // By this function, you could get the latest version of deployment
-func (e EC2Client) GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) []*autoscaling.Group {
-	asgGroups := []*autoscaling.Group{}
-	asgGroups = getAutoScalingGroups(e.AsClient, asgGroups, nil)
+func (e EC2Client) GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error) {
+	asgGroups, err := getAutoScalingGroups(e.AsClient, []*autoscaling.Group{}, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	ret := []*autoscaling.Group{}
 	for _, asgGroup := range asgGroups {
@@ -138,18 +203,18 @@ func (e EC2Client) GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) []*a
 		}
 	}
 
-	return ret
+	return ret, nil
 }
 
 // Batch of retrieving list of autoscaling group
 // By Token, if needed, you could get all autoscaling groups with paging.
-func getAutoScalingGroups(client *autoscaling.AutoScaling, asgGroup []*(autoscaling.Group), nextToken *string) []*autoscaling.Group {
+func getAutoScalingGroups(client *autoscaling.AutoScaling, asgGroup []*(autoscaling.Group), nextToken *string) ([]*autoscaling.Group, error) {
 	input := &autoscaling.DescribeAutoScalingGroupsInput{
 		NextToken: nextToken,
 	}
 	ret, err := client.DescribeAutoScalingGroups(input)
 	if err != nil {
-		tool.FatalError(err)
+		return nil, &LookupError{Resource: "autoscaling groups", Err: err}
 	}
 
 	asgGroup = append(asgGroup, ret.AutoScalingGroups...)
@@ -158,7 +223,7 @@ func getAutoScalingGroups(client *autoscaling.AutoScaling, asgGroup []*(autoscal
 		return getAutoScalingGroups(client, asgGroup, ret.NextToken)
 	}
 
-	return asgGroup
+	return asgGroup, nil
 }
 
 // Batch of retrieving all launch configurations
@@ -274,7 +339,7 @@ func deleteLaunchTemplate(client *ec2.EC2, lt_name string) error {
 }
 
 // Create New Launch Configuration
-func (e EC2Client) CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) bool {
+func (e EC2Client) CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) error {
 	input := &autoscaling.CreateLaunchConfigurationInput{
 		LaunchConfigurationName: aws.String(name),
 		ImageId:                 aws.String(ami),
@@ -287,6 +352,10 @@ func (e EC2Client) CreateNewLaunchConfiguration(name, ami, instanceType, keyName
 		BlockDeviceMappings:     blockDevices,
 	}
 
+	if e.Recorder.Record("CreateLaunchConfiguration", name, input) {
+		return nil
+	}
+
 	_, err := e.AsClient.CreateLaunchConfiguration(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -305,16 +374,16 @@ func (e EC2Client) CreateNewLaunchConfiguration(name, ami, instanceType, keyName
 			// Message from an error.
 			Logger.Errorln(err.Error())
 		}
-		return false
+		return &ResourceError{Action: "create launch configuration", Resource: name, Err: err}
 	}
 
 	Logger.Info("Successfully create new launch configurations : ", name)
 
-	return true
+	return nil
 }
 
 // Create New Launch Template
-func (e EC2Client) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions builder.InstanceMarketOptions) bool {
+func (e EC2Client) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions builder.InstanceMarketOptions) error {
 	input := &ec2.CreateLaunchTemplateInput{
 		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
 			ImageId:      aws.String(ami),
@@ -349,6 +418,10 @@ func (e EC2Client) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iam
 		}
 	}
 
+	if e.Recorder.Record("CreateLaunchTemplate", name, input) {
+		return nil
+	}
+
 	_, err := e.Client.CreateLaunchTemplate(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -361,21 +434,24 @@ func (e EC2Client) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iam
 			// Message from an error.
 			Logger.Errorln(err.Error())
 		}
-		return false
+		return &ResourceError{Action: "create launch template", Resource: name, Err: err}
 	}
 
 	Logger.Info("Successfully create new launch template : ", name)
 
-	return true
+	return nil
 }
 
 // Get All Security Group Information New Launch Configuration
-func (e EC2Client) GetSecurityGroupList(vpc string, sgList []string) []*string {
+func (e EC2Client) GetSecurityGroupList(vpc string, sgList []string) ([]*string, error) {
 	if len(sgList) == 0 {
-		tool.ErrorLogging("Need to specify at least one security group")
+		return nil, &LookupError{Resource: "security group", Reason: "need to specify at least one security group"}
 	}
 
-	vpcId := e.GetVPCId(vpc)
+	vpcId, err := e.GetVPCId(vpc)
+	if err != nil {
+		return nil, err
+	}
 
 	var retList []*string
 	for _, sg := range sgList {
@@ -414,7 +490,7 @@ func (e EC2Client) GetSecurityGroupList(vpc string, sgList []string) []*string {
 				Logger.Errorln(err.Error())
 			}
 
-			os.Exit(1)
+			return nil, &LookupError{Resource: "security group", Name: sg, Err: err}
 		}
 
 		//If it matches 0 or more than 1, it is wrong
@@ -423,13 +499,13 @@ func (e EC2Client) GetSecurityGroupList(vpc string, sgList []string) []*string {
 			for _, s := range result.SecurityGroups {
 				matched = append(matched, *s.GroupName)
 			}
-			tool.ErrorLogging(fmt.Sprintf("Expected only one security group on name lookup for \"%s\" got \"%s\"", sg, strings.Join(matched, ",")))
+			return nil, &LookupError{Resource: "security group", Name: sg, Reason: fmt.Sprintf("expected only one security group on name lookup, got \"%s\"", strings.Join(matched, ","))}
 		}
 
 		retList = append(retList, aws.String(*result.SecurityGroups[0].GroupId))
 	}
 
-	return retList
+	return retList, nil
 }
 
 // MakeBlockDevices returns list of block device mapping for launch configuration
@@ -494,15 +570,14 @@ func (e EC2Client) MakeLaunchTemplateBlockDeviceMappings(blocks []builder.BlockD
 	return ret
 }
 
-func (e EC2Client) GetVPCId(vpc string) string {
+func (e EC2Client) GetVPCId(vpc string) (string, error) {
 	ret, err := regexp.MatchString("vpc-[0-9A-Fa-f]{17}", vpc)
 	if err != nil {
-		fmt.Errorf("Error occurs when checking regex %v", err.Error())
-		os.Exit(1)
+		return "", &LookupError{Resource: "VPC", Name: vpc, Err: err}
 	}
 
 	if ret {
-		return vpc
+		return vpc, nil
 	}
 
 	input := &ec2.DescribeVpcsInput{
@@ -528,20 +603,20 @@ func (e EC2Client) GetVPCId(vpc string) string {
 			// Message from an error.
 			Logger.Errorln(err.Error())
 		}
-		os.Exit(1)
+		return "", &LookupError{Resource: "VPC", Name: vpc, Err: err}
 	}
 
 	// More than 1 vpc..
 	if len(result.Vpcs) > 1 {
-		tool.ErrorLogging(fmt.Sprintf("Expected only one VPC on name lookup for %v", vpc))
+		return "", &LookupError{Resource: "VPC", Name: vpc, Reason: "expected only one VPC on name lookup"}
 	}
 
 	// No VPC found
 	if len(result.Vpcs) < 1 {
-		tool.ErrorLogging(fmt.Sprintf("Unable to find VPC on name lookup for %v", vpc))
+		return "", &LookupError{Resource: "VPC", Name: vpc, Reason: "unable to find VPC on name lookup"}
 	}
 
-	return *result.Vpcs[0].VpcId
+	return *result.Vpcs[0].VpcId, nil
 }
 
 func (e EC2Client) CreateAutoScalingGroup(name, launch_template_name, healthcheck_type string,
@@ -550,7 +625,7 @@ func (e EC2Client) CreateAutoScalingGroup(name, launch_template_name, healthchec
 	loadbalancers, target_group_arns, termination_policies, availability_zones []*string,
 	tags []*(autoscaling.Tag),
 	subnets []string,
-	mixedInstancePolicy builder.MixedInstancesPolicy, hooks []*autoscaling.LifecycleHookSpecification) bool {
+	mixedInstancePolicy builder.MixedInstancesPolicy, hooks []*autoscaling.LifecycleHookSpecification) error {
 
 	lt := autoscaling.LaunchTemplateSpecification{
 		LaunchTemplateName: aws.String(launch_template_name),
@@ -609,6 +684,10 @@ func (e EC2Client) CreateAutoScalingGroup(name, launch_template_name, healthchec
 		input.LifecycleHookSpecificationList = hooks
 	}
 
+	if e.Recorder.Record("CreateAutoScalingGroup", name, input) {
+		return nil
+	}
+
 	_, err := e.AsClient.CreateAutoScalingGroup(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -629,12 +708,12 @@ func (e EC2Client) CreateAutoScalingGroup(name, launch_template_name, healthchec
 			// Message from an error.
 			Logger.Errorln(err.Error())
 		}
-		return false
+		return &ResourceError{Action: "create autoscaling group", Resource: name, Err: err}
 	}
 
 	Logger.Info("Successfully create new autoscaling group : ", name)
 
-	return true
+	return nil
 }
 
 // GenerateTags creates tag list for autoscaling group
@@ -709,9 +788,12 @@ func (e EC2Client) GenerateTags(tagList []string, asg_name, app, stack, ansibleT
 	return ret
 }
 
-func (e EC2Client) GetAvailabilityZones(vpc string, azs []string) []string {
+func (e EC2Client) GetAvailabilityZones(vpc string, azs []string) ([]string, error) {
 	ret := []string{}
-	vpcId := e.GetVPCId(vpc)
+	vpcId, err := e.GetVPCId(vpc)
+	if err != nil {
+		return nil, err
+	}
 
 	input := &ec2.DescribeSubnetsInput{
 		Filters: []*ec2.Filter{
@@ -736,7 +818,7 @@ func (e EC2Client) GetAvailabilityZones(vpc string, azs []string) []string {
 			// Message from an error.
 			Logger.Errorln(err.Error())
 		}
-		os.Exit(1)
+		return nil, &LookupError{Resource: "availability zones", Name: vpc, Err: err}
 	}
 
 	for _, subnet := range result.Subnets {
@@ -746,11 +828,14 @@ func (e EC2Client) GetAvailabilityZones(vpc string, azs []string) []string {
 		ret = append(ret, *subnet.AvailabilityZone)
 	}
 
-	return ret
+	return ret, nil
 }
 
-func (e EC2Client) GetSubnets(vpc string, use_public_subnets bool, azs []string) []string {
-	vpcId := e.GetVPCId(vpc)
+func (e EC2Client) GetSubnets(vpc string, use_public_subnets bool, azs []string) ([]string, error) {
+	vpcId, err := e.GetVPCId(vpc)
+	if err != nil {
+		return nil, err
+	}
 
 	input := &ec2.DescribeSubnetsInput{
 		Filters: []*ec2.Filter{
@@ -775,7 +860,7 @@ func (e EC2Client) GetSubnets(vpc string, use_public_subnets bool, azs []string)
 			// Message from an error.
 			Logger.Errorln(err.Error())
 		}
-		os.Exit(1)
+		return nil, &LookupError{Resource: "subnets", Name: vpc, Err: err}
 	}
 
 	ret := []string{}
@@ -795,7 +880,11 @@ func (e EC2Client) GetSubnets(vpc string, use_public_subnets bool, azs []string)
 		}
 	}
 
-	return ret
+	if len(ret) == 0 {
+		return nil, &LookupError{Resource: "subnets", Name: vpc, Reason: fmt.Sprintf("no %s subnet exists in %s", subnetType, strings.Join(azs, ","))}
+	}
+
+	return ret, nil
 }
 
 // Update Autoscaling Group size
@@ -807,6 +896,10 @@ func (e EC2Client) UpdateAutoScalingGroup(asg string, min, max, desired int64) e
 		DesiredCapacity:      aws.Int64(desired),
 	}
 
+	if e.Recorder.Record("UpdateAutoScalingGroup", asg, input) {
+		return nil
+	}
+
 	_, err := e.AsClient.UpdateAutoScalingGroup(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -831,6 +924,100 @@ func (e EC2Client) UpdateAutoScalingGroup(asg string, min, max, desired int64) e
 	return nil
 }
 
+// UpdateAutoScalingGroupLaunchTemplate replaces the launch template of autoscaling group
+// Instances already running are not affected until they are replaced.
+func (e EC2Client) UpdateAutoScalingGroupLaunchTemplate(asg, launch_template_name string, mixedInstancePolicyEnabled bool) error {
+	lt := &autoscaling.LaunchTemplateSpecification{
+		LaunchTemplateName: aws.String(launch_template_name),
+		Version:            aws.String("$Latest"),
+	}
+
+	input := &autoscaling.UpdateAutoScalingGroupInput{
+		AutoScalingGroupName: aws.String(asg),
+	}
+
+	if mixedInstancePolicyEnabled {
+		input.MixedInstancesPolicy = &autoscaling.MixedInstancesPolicy{
+			LaunchTemplate: &autoscaling.LaunchTemplate{
+				LaunchTemplateSpecification: lt,
+			},
+		}
+	} else {
+		input.LaunchTemplate = lt
+	}
+
+	if e.Recorder.Record("UpdateAutoScalingGroup", asg, input) {
+		return nil
+	}
+
+	_, err := e.AsClient.UpdateAutoScalingGroup(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case autoscaling.ErrCodeScalingActivityInProgressFault:
+				Logger.Errorln(autoscaling.ErrCodeScalingActivityInProgressFault, aerr.Error())
+			case autoscaling.ErrCodeResourceContentionFault:
+				Logger.Errorln(autoscaling.ErrCodeResourceContentionFault, aerr.Error())
+			case autoscaling.ErrCodeServiceLinkedRoleFailure:
+				Logger.Errorln(autoscaling.ErrCodeServiceLinkedRoleFailure, aerr.Error())
+			default:
+				Logger.Errorln(aerr.Error())
+			}
+		} else {
+			// Print the error, cast err to awserr.Error to get the Code and
+			// Message from an error.
+			Logger.Errorln(err.Error())
+		}
+		return err
+	}
+
+	Logger.Info(fmt.Sprintf("Launch template of autoscaling group is updated : %s / %s", asg, launch_template_name))
+
+	return nil
+}
+
+// TerminateInstanceInAutoScalingGroup terminates instance and lets autoscaling group launch a new one
+func (e EC2Client) TerminateInstanceInAutoScalingGroup(instanceId string) error {
+	input := &autoscaling.TerminateInstanceInAutoScalingGroupInput{
+		InstanceId:                     aws.String(instanceId),
+		ShouldDecrementDesiredCapacity: aws.Bool(false),
+	}
+
+	if e.Recorder.Record("TerminateInstanceInAutoScalingGroup", instanceId, input) {
+		return nil
+	}
+
+	_, err := e.AsClient.TerminateInstanceInAutoScalingGroup(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case autoscaling.ErrCodeScalingActivityInProgressFault:
+				Logger.Errorln(autoscaling.ErrCodeScalingActivityInProgressFault, aerr.Error())
+			case autoscaling.ErrCodeResourceContentionFault:
+				Logger.Errorln(autoscaling.ErrCodeResourceContentionFault, aerr.Error())
+			default:
+				Logger.Errorln(aerr.Error())
+			}
+		} else {
+			// Print the error, cast err to awserr.Error to get the Code and
+			// Message from an error.
+			Logger.Errorln(err.Error())
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeleteLaunchTemplate deletes single launch template with the exact name
+func (e EC2Client) DeleteLaunchTemplate(lt_name string) error {
+	if e.Recorder.Record("DeleteLaunchTemplate", lt_name, &ec2.DeleteLaunchTemplateInput{LaunchTemplateName: aws.String(lt_name)}) {
+		return nil
+	}
+
+	return deleteLaunchTemplate(e.Client, lt_name)
+}
+
 //CreateScalingPolicy creates scaling policy
 func (e EC2Client) CreateScalingPolicy(policy builder.ScalePolicy, asg_name string) (*string, error) {
 	input := &autoscaling.PutScalingPolicyInput{
@@ -841,6 +1028,10 @@ func (e EC2Client) CreateScalingPolicy(policy builder.ScalePolicy, asg_name stri
 		Cooldown:             aws.Int64(policy.Cooldown),
 	}
 
+	if e.Recorder.Record("PutScalingPolicy", policy.Name, input) {
+		return aws.String(fmt.Sprintf("dry-run:%s", policy.Name)), nil
+	}
+
 	result, err := e.AsClient.PutScalingPolicy(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -872,6 +1063,10 @@ func (e EC2Client) EnableMetrics(asg_name string) error {
 		Granularity:          aws.String("1Minute"),
 	}
 
+	if e.Recorder.Record("EnableMetricsCollection", asg_name, input) {
+		return nil
+	}
+
 	_, err := e.AsClient.EnableMetricsCollection(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -894,6 +1089,52 @@ func (e EC2Client) EnableMetrics(asg_name string) error {
 	return nil
 }
 
+// TagAutoScalingGroup creates or updates tags of autoscaling group
+// Tags are propagated to instances launched after the change.
+func (e EC2Client) TagAutoScalingGroup(asg_name string, tags map[string]string) error {
+	input := &autoscaling.CreateOrUpdateTagsInput{}
+	for k, v := range tags {
+		input.Tags = append(input.Tags, &autoscaling.Tag{
+			Key:               aws.String(k),
+			Value:             aws.String(v),
+			PropagateAtLaunch: aws.Bool(true),
+			ResourceId:        aws.String(asg_name),
+			ResourceType:      aws.String("auto-scaling-group"),
+		})
+	}
+
+	if e.Recorder.Record("CreateOrUpdateTags", asg_name, input) {
+		return nil
+	}
+
+	_, err := e.AsClient.CreateOrUpdateTags(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case autoscaling.ErrCodeLimitExceededFault:
+				Logger.Errorln(autoscaling.ErrCodeLimitExceededFault, aerr.Error())
+			case autoscaling.ErrCodeAlreadyExistsFault:
+				Logger.Errorln(autoscaling.ErrCodeAlreadyExistsFault, aerr.Error())
+			case autoscaling.ErrCodeResourceContentionFault:
+				Logger.Errorln(autoscaling.ErrCodeResourceContentionFault, aerr.Error())
+			case autoscaling.ErrCodeResourceInUseFault:
+				Logger.Errorln(autoscaling.ErrCodeResourceInUseFault, aerr.Error())
+			default:
+				Logger.Errorln(aerr.Error())
+			}
+		} else {
+			// Print the error, cast err to awserr.Error to get the Code and
+			// Message from an error.
+			Logger.Errorln(err.Error())
+		}
+		return &ResourceError{Action: "tag", Resource: asg_name, Err: err}
+	}
+
+	Logger.Debugf("Tags of autoscaling group are updated : %s", asg_name)
+
+	return nil
+}
+
 // Generate Lifecycle Hooks
 func (e EC2Client) GenerateLifecycleHooks(hooks builder.LifecycleHooks) []*autoscaling.LifecycleHookSpecification {
 	ret := []*autoscaling.LifecycleHookSpecification{}
@@ -943,3 +1184,168 @@ func createSingleLifecycleHookSpecification(l builder.LifecycleHookSpecification
 
 	return lhs
 }
+
+// ResolveAmi returns the AMI ID which the selector finds in the region
+// If more than one image matches, then the newest one is selected.
+func (e EC2Client) ResolveAmi(selector builder.AmiSelector) (string, error) {
+	if len(selector.SSMParameter) > 0 {
+		ami, err := e.SsmClient.GetParameter(selector.SSMParameter)
+		if err != nil {
+			return "", err
+		}
+
+		if !builder.IsAmiId(ami) {
+			return "", &LookupError{Resource: "AMI", Name: selector.String(), Reason: fmt.Sprintf("parameter is not an ami id : %s", ami)}
+		}
+
+		return ami, nil
+	}
+
+	filters := []*ec2.Filter{
+		{
+			Name:   aws.String("state"),
+			Values: aws.StringSlice([]string{"available"}),
+		},
+	}
+
+	if len(selector.Name) > 0 {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String("name"),
+			Values: aws.StringSlice([]string{selector.Name}),
+		})
+	}
+
+	for k, v := range selector.Tags {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String(fmt.Sprintf("tag:%s", k)),
+			Values: aws.StringSlice([]string{v}),
+		})
+	}
+
+	input := &ec2.DescribeImagesInput{
+		Owners:  aws.StringSlice(selector.GetOwners()),
+		Filters: filters,
+	}
+
+	result, err := e.Client.DescribeImages(input)
+	if err != nil {
+		return "", &LookupError{Resource: "AMI", Name: selector.String(), Err: err}
+	}
+
+	var latest *ec2.Image
+	for _, image := range result.Images {
+		// Creation date is ISO 8601 format so that it can be compared as string
+		if latest == nil || aws.StringValue(image.CreationDate) > aws.StringValue(latest.CreationDate) {
+			latest = image
+		}
+	}
+
+	if latest == nil {
+		return "", &LookupError{Resource: "AMI", Name: selector.String(), Reason: "no image matches the selector"}
+	}
+
+	return aws.StringValue(latest.ImageId), nil
+}
+
+// CheckImage checks if the AMI is available in the region
+func (e EC2Client) CheckImage(ami string) error {
+	input := &ec2.DescribeImagesInput{
+		ImageIds: aws.StringSlice([]string{ami}),
+	}
+
+	result, err := e.Client.DescribeImages(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case "InvalidAMIID.NotFound", "InvalidAMIID.Unavailable", "InvalidAMIID.Malformed":
+				return &LookupError{Resource: "AMI", Name: ami, Reason: "image does not exist"}
+			}
+		}
+		return &LookupError{Resource: "AMI", Name: ami, Err: err}
+	}
+
+	if len(result.Images) == 0 {
+		return &LookupError{Resource: "AMI", Name: ami, Reason: "image does not exist"}
+	}
+
+	if state := aws.StringValue(result.Images[0].State); state != ec2.ImageStateAvailable {
+		return &LookupError{Resource: "AMI", Name: ami, Reason: fmt.Sprintf("image is %s", state)}
+	}
+
+	return nil
+}
+
+// CheckKeyPair checks if the key pair exists in the region
+func (e EC2Client) CheckKeyPair(name string) error {
+	input := &ec2.DescribeKeyPairsInput{
+		KeyNames: aws.StringSlice([]string{name}),
+	}
+
+	if _, err := e.Client.DescribeKeyPairs(input); err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case "InvalidKeyPair.NotFound":
+				return &LookupError{Resource: "key pair", Name: name, Reason: "key pair does not exist"}
+			}
+		}
+		return &LookupError{Resource: "key pair", Name: name, Err: err}
+	}
+
+	return nil
+}
+
+// GetInstanceTypeZones returns availability zones of the region in which the instance type is offered
+func (e EC2Client) GetInstanceTypeZones(instanceType string) ([]string, error) {
+	input := &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String(ec2.LocationTypeAvailabilityZone),
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-type"),
+				Values: aws.StringSlice([]string{instanceType}),
+			},
+		},
+	}
+
+	ret := []string{}
+	err := e.Client.DescribeInstanceTypeOfferingsPages(input, func(page *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
+		for _, offering := range page.InstanceTypeOfferings {
+			ret = append(ret, aws.StringValue(offering.Location))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, &LookupError{Resource: "instance type offerings", Name: instanceType, Err: err}
+	}
+
+	return ret, nil
+}
+
+// GetLaunchTemplateData returns the data of the launch template version like AMI and instance type
+// The default version is used if version is empty.
+func (e EC2Client) GetLaunchTemplateData(name, version string) (*ec2.ResponseLaunchTemplateData, error) {
+	if len(version) == 0 {
+		version = "$Default"
+	}
+
+	input := &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateName: aws.String(name),
+		Versions:           aws.StringSlice([]string{version}),
+	}
+
+	result, err := e.Client.DescribeLaunchTemplateVersions(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case "InvalidLaunchTemplateName.NotFoundException", "InvalidLaunchTemplateId.VersionNotFound":
+				return nil, &LookupError{Resource: "launch template", Name: name, Reason: "launch template does not exist"}
+			}
+		}
+		return nil, &LookupError{Resource: "launch template", Name: name, Err: err}
+	}
+
+	if len(result.LaunchTemplateVersions) == 0 {
+		return nil, &LookupError{Resource: "launch template", Name: name, Reason: fmt.Sprintf("version does not exist : %s", version)}
+	}
+
+	return result.LaunchTemplateVersions[0].LaunchTemplateData, nil
+}
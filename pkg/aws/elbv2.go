@@ -10,11 +10,21 @@ import (
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	Logger "github.com/sirupsen/logrus"
-	"os"
+	"sort"
+	"strings"
 )
 
+// ELBV2API is the interface of elastic load balancing operations which goployer uses
+type ELBV2API interface {
+	GetTargetGroupARNs(target_groups []string) ([]*string, error)
+	GetHostInTarget(group *autoscaling.Group, target_group_arn *string) ([]HealthcheckHost, error)
+	GetForwardWeights(arn string) (map[string]int64, error)
+	ModifyForwardWeights(arn string, weights map[string]int64) error
+}
+
 type ELBV2Client struct {
-	Client *elbv2.ELBV2
+	Client   *elbv2.ELBV2
+	Recorder *Recorder
 }
 
 type HealthcheckHost struct {
@@ -39,9 +49,9 @@ func getElbClientFn(session *session.Session, region string, creds *credentials.
 }
 
 // GetTargetGroupARNs returns arn list of target groups
-func (e ELBV2Client) GetTargetGroupARNs(target_groups []string) []*string {
+func (e ELBV2Client) GetTargetGroupARNs(target_groups []string) ([]*string, error) {
 	if len(target_groups) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	input := &elbv2.DescribeTargetGroupsInput{
@@ -64,7 +74,7 @@ func (e ELBV2Client) GetTargetGroupARNs(target_groups []string) []*string {
 			// Message from an error.
 			Logger.Errorln(err.Error())
 		}
-		os.Exit(1)
+		return nil, &LookupError{Resource: "target groups", Name: strings.Join(target_groups, ","), Err: err}
 	}
 
 	ret := []*string{}
@@ -72,11 +82,11 @@ func (e ELBV2Client) GetTargetGroupARNs(target_groups []string) []*string {
 		ret = append(ret, group.TargetGroupArn)
 	}
 
-	return ret
+	return ret, nil
 }
 
 // GetHostInTarget gets host instance
-func (e ELBV2Client) GetHostInTarget(group *autoscaling.Group, target_group_arn *string) []HealthcheckHost {
+func (e ELBV2Client) GetHostInTarget(group *autoscaling.Group, target_group_arn *string) ([]HealthcheckHost, error) {
 	Logger.Debug(fmt.Sprintf("[Checking healthy host count] Autoscaling Group: %s", *group.AutoScalingGroupName))
 
 	input := &elbv2.DescribeTargetHealthInput{
@@ -101,7 +111,7 @@ func (e ELBV2Client) GetHostInTarget(group *autoscaling.Group, target_group_arn
 			// Message from an error.
 			Logger.Errorln(err.Error())
 		}
-		os.Exit(1)
+		return nil, &LookupError{Resource: "target health", Name: *target_group_arn, Err: err}
 	}
 
 	ret := []HealthcheckHost{}
@@ -122,5 +132,192 @@ func (e ELBV2Client) GetHostInTarget(group *autoscaling.Group, target_group_arn
 			Healthy:        *instance.LifecycleState == "InService" && target_state == "healthy" && *instance.HealthStatus == "Healthy",
 		})
 	}
-	return ret
+	return ret, nil
+}
+
+// GetForwardWeights returns weights of target groups in forward action of listener or listener rule
+func (e ELBV2Client) GetForwardWeights(arn string) (map[string]int64, error) {
+	actions, err := e.getActions(arn)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]int64{}
+	for _, action := range actions {
+		if *action.Type != elbv2.ActionTypeEnumForward {
+			continue
+		}
+
+		if action.ForwardConfig != nil && len(action.ForwardConfig.TargetGroups) > 0 {
+			for _, tg := range action.ForwardConfig.TargetGroups {
+				weight := int64(1)
+				if tg.Weight != nil {
+					weight = *tg.Weight
+				}
+				ret[*tg.TargetGroupArn] = weight
+			}
+		} else if action.TargetGroupArn != nil {
+			ret[*action.TargetGroupArn] = 1
+		}
+	}
+
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no forward action found : %s", arn)
+	}
+
+	return ret, nil
+}
+
+// getActions returns actions of listener rule or default actions of listener
+func (e ELBV2Client) getActions(arn string) ([]*elbv2.Action, error) {
+	if IsListenerRule(arn) {
+		result, err := e.Client.DescribeRules(&elbv2.DescribeRulesInput{
+			RuleArns: []*string{aws.String(arn)},
+		})
+		if err != nil {
+			logElbError(err)
+			return nil, err
+		}
+
+		if len(result.Rules) == 0 {
+			return nil, fmt.Errorf("no listener rule found : %s", arn)
+		}
+		return result.Rules[0].Actions, nil
+	}
+
+	result, err := e.Client.DescribeListeners(&elbv2.DescribeListenersInput{
+		ListenerArns: []*string{aws.String(arn)},
+	})
+	if err != nil {
+		logElbError(err)
+		return nil, err
+	}
+
+	if len(result.Listeners) == 0 {
+		return nil, fmt.Errorf("no listener found : %s", arn)
+	}
+	return result.Listeners[0].DefaultActions, nil
+}
+
+// ModifyForwardWeights changes weights of target groups in forward action of listener or listener rule
+// Other actions like authentication and settings of forward action like stickiness are submitted as they are.
+// Target groups which are not in weights keep their weights.
+func (e ELBV2Client) ModifyForwardWeights(arn string, weights map[string]int64) error {
+	actions, err := e.getActions(arn)
+	if err != nil {
+		return err
+	}
+
+	forwarded := false
+	for _, action := range actions {
+		switch *action.Type {
+		case elbv2.ActionTypeEnumForward:
+			setForwardWeights(action, weights)
+			forwarded = true
+		case elbv2.ActionTypeEnumAuthenticateOidc:
+			// Client secret is not returned by describe API
+			if action.AuthenticateOidcConfig != nil {
+				action.AuthenticateOidcConfig.ClientSecret = nil
+				action.AuthenticateOidcConfig.UseExistingClientSecret = aws.Bool(true)
+			}
+		}
+	}
+
+	if !forwarded {
+		return fmt.Errorf("no forward action found : %s", arn)
+	}
+
+	if IsListenerRule(arn) {
+		input := &elbv2.ModifyRuleInput{
+			RuleArn: aws.String(arn),
+			Actions: actions,
+		}
+		if e.Recorder.Record("ModifyRule", arn, input) {
+			return nil
+		}
+		_, err = e.Client.ModifyRule(input)
+	} else {
+		input := &elbv2.ModifyListenerInput{
+			ListenerArn:    aws.String(arn),
+			DefaultActions: actions,
+		}
+		if e.Recorder.Record("ModifyListener", arn, input) {
+			return nil
+		}
+		_, err = e.Client.ModifyListener(input)
+	}
+
+	if err != nil {
+		logElbError(err)
+		return err
+	}
+
+	Logger.Debugf("weights of target groups are modified : %s", arn)
+
+	return nil
+}
+
+// setForwardWeights changes weights of target groups in the forward action
+// Forward action with a single target group is converted to forward config so that it can have weights.
+func setForwardWeights(action *elbv2.Action, weights map[string]int64) {
+	if action.ForwardConfig == nil {
+		action.ForwardConfig = &elbv2.ForwardActionConfig{}
+	}
+
+	if len(action.ForwardConfig.TargetGroups) == 0 && action.TargetGroupArn != nil {
+		action.ForwardConfig.TargetGroups = []*elbv2.TargetGroupTuple{
+			{TargetGroupArn: action.TargetGroupArn, Weight: aws.Int64(1)},
+		}
+	}
+
+	// TargetGroupArn cannot be used with forward config of several target groups
+	action.TargetGroupArn = nil
+
+	applied := map[string]bool{}
+	for _, tg := range action.ForwardConfig.TargetGroups {
+		if weight, ok := weights[*tg.TargetGroupArn]; ok {
+			tg.Weight = aws.Int64(weight)
+			applied[*tg.TargetGroupArn] = true
+		}
+	}
+
+	arns := []string{}
+	for tgArn := range weights {
+		if !applied[tgArn] {
+			arns = append(arns, tgArn)
+		}
+	}
+	sort.Strings(arns)
+
+	for _, tgArn := range arns {
+		action.ForwardConfig.TargetGroups = append(action.ForwardConfig.TargetGroups, &elbv2.TargetGroupTuple{
+			TargetGroupArn: aws.String(tgArn),
+			Weight:         aws.Int64(weights[tgArn]),
+		})
+	}
+}
+
+// IsListenerRule checks if arn is for listener rule, not listener
+func IsListenerRule(arn string) bool {
+	return strings.Contains(arn, ":listener-rule/")
+}
+
+// logElbError prints error from elbv2 API
+func logElbError(err error) {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case elbv2.ErrCodeListenerNotFoundException:
+			Logger.Errorln(elbv2.ErrCodeListenerNotFoundException, aerr.Error())
+		case elbv2.ErrCodeRuleNotFoundException:
+			Logger.Errorln(elbv2.ErrCodeRuleNotFoundException, aerr.Error())
+		case elbv2.ErrCodeTargetGroupNotFoundException:
+			Logger.Errorln(elbv2.ErrCodeTargetGroupNotFoundException, aerr.Error())
+		default:
+			Logger.Errorln(aerr.Error())
+		}
+	} else {
+		// Print the error, cast err to awserr.Error to get the Code and
+		// Message from an error.
+		Logger.Errorln(err.Error())
+	}
 }
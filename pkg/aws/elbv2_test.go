@@ -0,0 +1,100 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+func TestSetForwardWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  *elbv2.Action
+		weights map[string]int64
+		want    map[string]int64
+	}{
+		{
+			name: "weights of existing target groups are changed",
+			action: &elbv2.Action{
+				Type: aws.String(elbv2.ActionTypeEnumForward),
+				ForwardConfig: &elbv2.ForwardActionConfig{
+					TargetGroups: []*elbv2.TargetGroupTuple{
+						{TargetGroupArn: aws.String("blue"), Weight: aws.Int64(100)},
+						{TargetGroupArn: aws.String("green"), Weight: aws.Int64(0)},
+					},
+				},
+			},
+			weights: map[string]int64{"blue": 90, "green": 10},
+			want:    map[string]int64{"blue": 90, "green": 10},
+		},
+		{
+			name: "target groups not in weights are kept",
+			action: &elbv2.Action{
+				Type: aws.String(elbv2.ActionTypeEnumForward),
+				ForwardConfig: &elbv2.ForwardActionConfig{
+					TargetGroups: []*elbv2.TargetGroupTuple{
+						{TargetGroupArn: aws.String("blue"), Weight: aws.Int64(50)},
+						{TargetGroupArn: aws.String("other"), Weight: aws.Int64(50)},
+					},
+				},
+			},
+			weights: map[string]int64{"blue": 25, "green": 25},
+			want:    map[string]int64{"blue": 25, "green": 25, "other": 50},
+		},
+		{
+			name: "single target group is converted to forward config",
+			action: &elbv2.Action{
+				Type:           aws.String(elbv2.ActionTypeEnumForward),
+				TargetGroupArn: aws.String("blue"),
+			},
+			weights: map[string]int64{"blue": 1, "green": 0},
+			want:    map[string]int64{"blue": 1, "green": 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setForwardWeights(test.action, test.weights)
+
+			if test.action.TargetGroupArn != nil {
+				t.Errorf("TargetGroupArn = %s, want nil", *test.action.TargetGroupArn)
+			}
+
+			got := map[string]int64{}
+			for _, tg := range test.action.ForwardConfig.TargetGroups {
+				got[*tg.TargetGroupArn] = *tg.Weight
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("weights = %v, want %v", got, test.want)
+			}
+
+			for arn, weight := range test.want {
+				if got[arn] != weight {
+					t.Errorf("weights = %v, want %v", got, test.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSetForwardWeightsKeepsStickiness(t *testing.T) {
+	stickiness := &elbv2.TargetGroupStickinessConfig{Enabled: aws.Bool(true), DurationSeconds: aws.Int64(300)}
+	action := &elbv2.Action{
+		Type: aws.String(elbv2.ActionTypeEnumForward),
+		ForwardConfig: &elbv2.ForwardActionConfig{
+			TargetGroupStickinessConfig: stickiness,
+			TargetGroups: []*elbv2.TargetGroupTuple{
+				{TargetGroupArn: aws.String("blue"), Weight: aws.Int64(1)},
+			},
+		},
+	}
+
+	setForwardWeights(action, map[string]int64{"blue": 0, "green": 1})
+
+	if action.ForwardConfig.TargetGroupStickinessConfig != stickiness {
+		t.Errorf("stickiness config = %v, want %v", action.ForwardConfig.TargetGroupStickinessConfig, stickiness)
+	}
+}
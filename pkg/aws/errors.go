@@ -0,0 +1,37 @@
+package aws
+
+import "fmt"
+
+// LookupError is returned when a resource cannot be resolved from AWS
+type LookupError struct {
+	Resource string
+	Name     string
+	Reason   string
+	Err      error
+}
+
+func (e *LookupError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("failed to look up %s %q : %s", e.Resource, e.Name, e.Err.Error())
+	}
+	return fmt.Sprintf("failed to look up %s %q : %s", e.Resource, e.Name, e.Reason)
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// ResourceError is returned when a resource cannot be created or changed
+type ResourceError struct {
+	Action   string
+	Resource string
+	Err      error
+}
+
+func (e *ResourceError) Error() string {
+	return fmt.Sprintf("failed to %s %s : %s", e.Action, e.Resource, e.Err.Error())
+}
+
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
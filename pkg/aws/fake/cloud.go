@@ -0,0 +1,183 @@
+package fake
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"sync"
+)
+
+// LaunchTemplate is a launch template created in the fake cloud
+type LaunchTemplate struct {
+	Name           string
+	Ami            string
+	InstanceType   string
+	Userdata       string
+	SecurityGroups []string
+}
+
+// Image is an AMI registered in the fake cloud
+// Owner is empty for images of the account.
+type Image struct {
+	Id           string
+	Region       string
+	Name         string
+	Owner        string
+	Tags         map[string]string
+	CreationDate string
+}
+
+// Cloud simulates AWS resources in memory so that deployments can run offline
+// Every client bootstrapped from the same cloud shares resources.
+type Cloud struct {
+	mu       sync.Mutex
+	sequence int
+
+	// DefaultTargetHealth is the target health of new instances
+	DefaultTargetHealth string
+	// RegionTargetHealth overrides the target health of new instances in the region
+	RegionTargetHealth map[string]string
+
+	AutoScalingGroups map[string]*autoscaling.Group
+	LaunchTemplates   map[string]LaunchTemplate
+	Images            []Image
+	ScalingPolicies   map[string]string
+	MetricsEnabled    map[string]bool
+
+	TargetGroups map[string]string
+	TargetHealth map[string]string
+	Weights      map[string]map[string]int64
+
+	Alarms           map[string]builder.AlarmConfigs
+	AlarmStates      map[string]string
+	MetricDatapoints map[string][]float64
+
+	Commands [][]string
+
+	Objects    map[string][]byte
+	Parameters map[string]string
+	Secrets    map[string]string
+
+	// DeniedActions are IAM actions which the assume role is not allowed to call
+	DeniedActions []string
+
+	Tables map[string]map[string]map[string]*dynamodb.AttributeValue
+	// Indexes are global secondary indexes of each table
+	Indexes map[string][]string
+}
+
+// NewCloud creates an empty fake cloud in which new instances become healthy at once
+func NewCloud() *Cloud {
+	return &Cloud{
+		DefaultTargetHealth: "healthy",
+		RegionTargetHealth:  map[string]string{},
+		AutoScalingGroups:   map[string]*autoscaling.Group{},
+		LaunchTemplates:     map[string]LaunchTemplate{},
+		ScalingPolicies:     map[string]string{},
+		MetricsEnabled:      map[string]bool{},
+		TargetGroups:        map[string]string{},
+		TargetHealth:        map[string]string{},
+		Weights:             map[string]map[string]int64{},
+		Alarms:              map[string]builder.AlarmConfigs{},
+		AlarmStates:         map[string]string{},
+		MetricDatapoints:    map[string][]float64{},
+		Tables:              map[string]map[string]map[string]*dynamodb.AttributeValue{},
+		Indexes:             map[string][]string{},
+		Objects:             map[string][]byte{},
+		Parameters:          map[string]string{},
+		Secrets:             map[string]string{},
+	}
+}
+
+// Bootstrap returns service clients of the fake cloud
+// It has the same signature with aws.BootstrapServices.
+func (c *Cloud) Bootstrap(region string, assume_role string) aws.AWSClient {
+	return aws.AWSClient{
+		Region:                region,
+		EC2Service:            EC2{cloud: c, region: region},
+		ELBService:            ELBV2{cloud: c, region: region},
+		CloudWatchService:     CloudWatch{cloud: c},
+		SSMService:            SSM{cloud: c},
+		S3Service:             S3{cloud: c},
+		SecretsManagerService: SecretsManager{cloud: c},
+		IAMService:            IAM{cloud: c},
+		STSService:            STS{cloud: c},
+	}
+}
+
+// MetricClient returns metric client of the fake cloud
+func (c *Cloud) MetricClient(region string) aws.MetricClient {
+	return aws.MetricClient{
+		Region:          region,
+		DynamoDBService: DynamoDB{cloud: c},
+	}
+}
+
+// MetricBootstrap returns metric client of the fake cloud
+// It has the same signature with aws.BootstrapMetricService.
+func (c *Cloud) MetricBootstrap(region string, assume_role string) aws.MetricClient {
+	return c.MetricClient(region)
+}
+
+// AddTargetGroup registers a target group with the name
+func (c *Cloud) AddTargetGroup(region, name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	arn := fmt.Sprintf("arn:aws:elasticloadbalancing:%s:000000000000:targetgroup/%s/%04d", region, name, c.next())
+	c.TargetGroups[name] = arn
+
+	return arn
+}
+
+// PutObject stores the object in the bucket
+// If version is not empty, the object is stored only as the version.
+func (c *Cloud) PutObject(bucket, key, version string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Objects[objectName(bucket, key, version)] = body
+}
+
+// AddImage registers the AMI in the region
+func (c *Cloud) AddImage(image Image) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Images = append(c.Images, image)
+}
+
+// SetTargetHealth changes target health of all instances in the autoscaling group
+func (c *Cloud) SetTargetHealth(asg, state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.AutoScalingGroups[asg]
+	if !ok {
+		return
+	}
+
+	for _, instance := range group.Instances {
+		c.TargetHealth[*instance.InstanceId] = state
+	}
+}
+
+// next returns the next sequence to make unique identifiers
+func (c *Cloud) next() int {
+	c.sequence++
+	return c.sequence
+}
+
+var (
+	_ aws.EC2API            = EC2{}
+	_ aws.ELBV2API          = ELBV2{}
+	_ aws.CloudWatchAPI     = CloudWatch{}
+	_ aws.SSMAPI            = SSM{}
+	_ aws.S3API             = S3{}
+	_ aws.SecretsManagerAPI = SecretsManager{}
+	_ aws.IAMAPI            = IAM{}
+	_ aws.STSAPI            = STS{}
+	_ aws.DynamoDBAPI       = DynamoDB{}
+)
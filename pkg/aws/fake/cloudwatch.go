@@ -0,0 +1,73 @@
+package fake
+
+import (
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"time"
+)
+
+// CloudWatch simulates alarms and metric datapoints
+type CloudWatch struct {
+	cloud    *Cloud
+	recorder *aws.Recorder
+}
+
+func (c CloudWatch) CreateScalingAlarms(asg_name string, alarms []builder.AlarmConfigs, policyArns map[string]string) error {
+	for _, alarm := range alarms {
+		if err := c.CreateCloudWatchAlarm(asg_name, alarm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c CloudWatch) CreateCloudWatchAlarm(asg_name string, alarm builder.AlarmConfigs) error {
+	if c.recorder.Record("PutMetricAlarm", alarm.Name, nil) {
+		return nil
+	}
+
+	c.cloud.mu.Lock()
+	defer c.cloud.mu.Unlock()
+
+	c.cloud.Alarms[alarm.Name] = alarm
+	c.cloud.AlarmStates[alarm.Name] = "OK"
+
+	return nil
+}
+
+func (c CloudWatch) GetAlarmsInAlarmState(alarmNames []string) ([]string, error) {
+	c.cloud.mu.Lock()
+	defer c.cloud.mu.Unlock()
+
+	ret := []string{}
+	for _, name := range alarmNames {
+		if c.cloud.AlarmStates[name] == "ALARM" {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret, nil
+}
+
+// GetMetricDatapoints returns values set in MetricDatapoints with the name of metric
+func (c CloudWatch) GetMetricDatapoints(asg_name string, metric builder.CanaryMetric, start, end time.Time) ([]float64, error) {
+	c.cloud.mu.Lock()
+	defer c.cloud.mu.Unlock()
+
+	return append([]float64{}, c.cloud.MetricDatapoints[metric.Metric]...), nil
+}
+
+// GetExistingAlarms returns alarms which have any state in the fake cloud
+func (c CloudWatch) GetExistingAlarms(alarmNames []string) ([]string, error) {
+	c.cloud.mu.Lock()
+	defer c.cloud.mu.Unlock()
+
+	ret := []string{}
+	for _, name := range alarmNames {
+		if _, ok := c.cloud.AlarmStates[name]; ok {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,35 @@
+package fake
+
+import (
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+)
+
+// WithRecorder returns the client whose mutating calls are recorded instead of changing the fake cloud
+func (e EC2) WithRecorder(recorder *aws.Recorder) aws.EC2API {
+	e.recorder = recorder
+	return e
+}
+
+// WithRecorder returns the client whose mutating calls are recorded instead of changing the fake cloud
+func (e ELBV2) WithRecorder(recorder *aws.Recorder) aws.ELBV2API {
+	e.recorder = recorder
+	return e
+}
+
+// WithRecorder returns the client whose mutating calls are recorded instead of changing the fake cloud
+func (c CloudWatch) WithRecorder(recorder *aws.Recorder) aws.CloudWatchAPI {
+	c.recorder = recorder
+	return c
+}
+
+// WithRecorder returns the client whose mutating calls are recorded instead of changing the fake cloud
+func (s SSM) WithRecorder(recorder *aws.Recorder) aws.SSMAPI {
+	s.recorder = recorder
+	return s
+}
+
+// WithRecorder returns the client whose mutating calls are recorded instead of changing the fake cloud
+func (d DynamoDB) WithRecorder(recorder *aws.Recorder) aws.DynamoDBAPI {
+	d.recorder = recorder
+	return d
+}
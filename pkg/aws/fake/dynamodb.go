@@ -0,0 +1,260 @@
+package fake
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	sdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DynamoDB simulates tables of deployment records
+type DynamoDB struct {
+	cloud    *Cloud
+	recorder *aws.Recorder
+}
+
+func (d DynamoDB) CheckTableExists(tableName string) (bool, error) {
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	_, ok := d.cloud.Tables[tableName]
+
+	return ok, nil
+}
+
+func (d DynamoDB) CreateTable(tableName string) error {
+	if d.recorder.Record("CreateTable", tableName, nil) {
+		return nil
+	}
+
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	if _, ok := d.cloud.Tables[tableName]; ok {
+		return fmt.Errorf("table already exists : %s", tableName)
+	}
+	d.cloud.Tables[tableName] = map[string]map[string]*dynamodb.AttributeValue{}
+	d.cloud.Indexes[tableName] = []string{aws.HISTORY_INDEX_NAME}
+
+	return nil
+}
+
+func (d DynamoDB) MakeRecord(stack, config, tags string, asg string, tableName string, status string, additionalFields map[string]string) error {
+	if d.recorder.Record("PutItem", asg, nil) {
+		return nil
+	}
+
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	table, ok := d.cloud.Tables[tableName]
+	if !ok {
+		return fmt.Errorf("table does not exist : %s", tableName)
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"identifier":        {S: sdk.String(asg)},
+		"deployment_status": {S: sdk.String(status)},
+		"stack":             {S: sdk.String(stack)},
+		"config":            {S: sdk.String(config)},
+		"start_date_kst":    {S: sdk.String(tool.GetKstTimestamp().Format(time.RFC3339))},
+		"tag":               {S: sdk.String(tags)},
+	}
+
+	for k, v := range additionalFields {
+		item[k] = &dynamodb.AttributeValue{S: sdk.String(v)}
+	}
+	table[asg] = item
+
+	return nil
+}
+
+func (d DynamoDB) UpdateRecord(updateKey, asg string, tableName string, status string, updateFields map[string]string) error {
+	if d.recorder.Record("UpdateItem", asg, nil) {
+		return nil
+	}
+
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	table, ok := d.cloud.Tables[tableName]
+	if !ok {
+		return fmt.Errorf("table does not exist : %s", tableName)
+	}
+
+	item, ok := table[asg]
+	if !ok {
+		item = map[string]*dynamodb.AttributeValue{"identifier": {S: sdk.String(asg)}}
+		table[asg] = item
+	}
+
+	item[updateKey] = &dynamodb.AttributeValue{S: sdk.String(status)}
+	item[fmt.Sprintf("%s_date_kst", status)] = &dynamodb.AttributeValue{S: sdk.String(tool.GetKstTimestamp().Format(time.RFC3339))}
+	for k, v := range updateFields {
+		item[k] = &dynamodb.AttributeValue{S: sdk.String(v)}
+	}
+
+	return nil
+}
+
+func (d DynamoDB) GetSingleItem(asg, tableName string) (map[string]*dynamodb.AttributeValue, error) {
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	table, ok := d.cloud.Tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table does not exist : %s", tableName)
+	}
+
+	ret := map[string]*dynamodb.AttributeValue{}
+	for k, v := range table[asg] {
+		ret[k] = v
+	}
+
+	return ret, nil
+}
+
+func (d DynamoDB) AcquireLock(key, holder, tableName string, ttl time.Duration) (bool, error) {
+	if d.recorder.Record("PutItem", key, nil) {
+		return true, nil
+	}
+
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	table, ok := d.cloud.Tables[tableName]
+	if !ok {
+		return false, fmt.Errorf("table does not exist : %s", tableName)
+	}
+
+	now := time.Now()
+	if item, ok := table[key]; ok && *item["lock_holder"].S != holder {
+		expires, _ := strconv.ParseInt(*item["lock_expires_at"].N, 10, 64)
+		if expires >= now.Unix() {
+			return false, nil
+		}
+	}
+
+	table[key] = map[string]*dynamodb.AttributeValue{
+		"identifier":        {S: sdk.String(key)},
+		"lock_holder":       {S: sdk.String(holder)},
+		"lock_expires_at":   {N: sdk.String(strconv.FormatInt(now.Add(ttl).Unix(), 10))},
+		"acquired_date_kst": {S: sdk.String(tool.GetKstTimestamp().Format(time.RFC3339))},
+	}
+
+	return true, nil
+}
+
+func (d DynamoDB) RenewLock(key, holder, tableName string, ttl time.Duration) error {
+	if d.recorder.Record("UpdateItem", key, nil) {
+		return nil
+	}
+
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	item, ok := d.cloud.Tables[tableName][key]
+	if !ok || *item["lock_holder"].S != holder {
+		return fmt.Errorf("lock is not held by %s anymore : %s", holder, key)
+	}
+	item["lock_expires_at"] = &dynamodb.AttributeValue{N: sdk.String(strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))}
+
+	return nil
+}
+
+func (d DynamoDB) ReleaseLock(key, holder, tableName string) error {
+	if d.recorder.Record("DeleteItem", key, nil) {
+		return nil
+	}
+
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	table, ok := d.cloud.Tables[tableName]
+	if !ok {
+		return fmt.Errorf("table does not exist : %s", tableName)
+	}
+
+	if item, ok := table[key]; len(holder) > 0 && (!ok || *item["lock_holder"].S != holder) {
+		return fmt.Errorf("lock is not held by %s anymore : %s", holder, key)
+	}
+	delete(table, key)
+
+	return nil
+}
+
+func (d DynamoDB) CheckIndexExists(tableName, indexName string) (bool, error) {
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	if _, ok := d.cloud.Tables[tableName]; !ok {
+		return false, fmt.Errorf("table does not exist : %s", tableName)
+	}
+
+	return tool.IsStringInArray(indexName, d.cloud.Indexes[tableName]), nil
+}
+
+func (d DynamoDB) CreateHistoryIndex(tableName string) error {
+	if d.recorder.Record("UpdateTable", tableName, nil) {
+		return nil
+	}
+
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	if _, ok := d.cloud.Tables[tableName]; !ok {
+		return fmt.Errorf("table does not exist : %s", tableName)
+	}
+
+	if tool.IsStringInArray(aws.HISTORY_INDEX_NAME, d.cloud.Indexes[tableName]) {
+		return fmt.Errorf("index already exists : %s", aws.HISTORY_INDEX_NAME)
+	}
+	d.cloud.Indexes[tableName] = append(d.cloud.Indexes[tableName], aws.HISTORY_INDEX_NAME)
+
+	return nil
+}
+
+func (d DynamoDB) QueryHistory(app, since, until string, tableName string) ([]map[string]*dynamodb.AttributeValue, error) {
+	d.cloud.mu.Lock()
+	defer d.cloud.mu.Unlock()
+
+	table, ok := d.cloud.Tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table does not exist : %s", tableName)
+	}
+
+	if !tool.IsStringInArray(aws.HISTORY_INDEX_NAME, d.cloud.Indexes[tableName]) {
+		return nil, fmt.Errorf("index does not exist : %s", aws.HISTORY_INDEX_NAME)
+	}
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, item := range table {
+		if item["app"] == nil || item["start_date_kst"] == nil || *item["app"].S != app {
+			continue
+		}
+
+		start := *item["start_date_kst"].S
+		if (len(since) > 0 && start < since) || (len(until) > 0 && start > until) {
+			continue
+		}
+
+		copied := map[string]*dynamodb.AttributeValue{}
+		for k, v := range item {
+			copied[k] = v
+		}
+		items = append(items, copied)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if *items[i]["start_date_kst"].S == *items[j]["start_date_kst"].S {
+			return *items[i]["identifier"].S > *items[j]["identifier"].S
+		}
+		return *items[i]["start_date_kst"].S > *items[j]["start_date_kst"].S
+	})
+
+	return items, nil
+}
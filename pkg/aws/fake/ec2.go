@@ -0,0 +1,498 @@
+package fake
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	sdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EC2 simulates autoscaling groups, instances and launch templates
+type EC2 struct {
+	cloud    *Cloud
+	region   string
+	recorder *aws.Recorder
+}
+
+func (e EC2) GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error) {
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	group, ok := e.cloud.AutoScalingGroups[name]
+	if !ok {
+		return nil, nil
+	}
+
+	return copyGroup(group), nil
+}
+
+func (e EC2) GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error) {
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	names := []string{}
+	for name := range e.cloud.AutoScalingGroups {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	ret := []*autoscaling.Group{}
+	for _, name := range names {
+		ret = append(ret, copyGroup(e.cloud.AutoScalingGroups[name]))
+	}
+
+	return ret, nil
+}
+
+func (e EC2) DeleteLaunchConfigurations(asg_name string) error {
+	return nil
+}
+
+func (e EC2) DeleteLaunchTemplates(asg_name string) error {
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	for name := range e.cloud.LaunchTemplates {
+		if !strings.HasPrefix(name, asg_name) || e.recorder.Record("DeleteLaunchTemplate", name, nil) {
+			continue
+		}
+		delete(e.cloud.LaunchTemplates, name)
+	}
+
+	return nil
+}
+
+func (e EC2) DeleteLaunchTemplate(lt_name string) error {
+	if e.recorder.Record("DeleteLaunchTemplate", lt_name, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	if _, ok := e.cloud.LaunchTemplates[lt_name]; !ok {
+		return fmt.Errorf("launch template does not exist : %s", lt_name)
+	}
+	delete(e.cloud.LaunchTemplates, lt_name)
+
+	return nil
+}
+
+// DeleteAutoscalingSet fails if instances are still running like AWS does
+func (e EC2) DeleteAutoscalingSet(asg_name string) bool {
+	return e.deleteAutoscalingGroup(asg_name, false)
+}
+
+func (e EC2) ForceDeleteAutoscalingSet(asg_name string) bool {
+	return e.deleteAutoscalingGroup(asg_name, true)
+}
+
+func (e EC2) deleteAutoscalingGroup(asg_name string, forceDelete bool) bool {
+	if e.recorder.Record("DeleteAutoScalingGroup", asg_name, nil) {
+		return true
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	group, ok := e.cloud.AutoScalingGroups[asg_name]
+	if !ok {
+		return false
+	}
+
+	if len(group.Instances) > 0 && !forceDelete {
+		return false
+	}
+
+	for _, instance := range group.Instances {
+		delete(e.cloud.TargetHealth, *instance.InstanceId)
+	}
+	delete(e.cloud.AutoScalingGroups, asg_name)
+
+	return true
+}
+
+func (e EC2) CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) error {
+	return e.CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata, ebsOptimized, false, securityGroups, nil, builder.InstanceMarketOptions{})
+}
+
+func (e EC2) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions builder.InstanceMarketOptions) error {
+	if e.recorder.Record("CreateLaunchTemplate", name, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	if _, ok := e.cloud.LaunchTemplates[name]; ok {
+		return &aws.ResourceError{Action: "create launch template", Resource: name, Err: fmt.Errorf("already exists")}
+	}
+
+	e.cloud.LaunchTemplates[name] = LaunchTemplate{
+		Name:           name,
+		Ami:            ami,
+		InstanceType:   instanceType,
+		Userdata:       userdata,
+		SecurityGroups: sdk.StringValueSlice(securityGroups),
+	}
+
+	return nil
+}
+
+// GetSecurityGroupList returns names of security groups as their IDs
+func (e EC2) GetSecurityGroupList(vpc string, sgList []string) ([]*string, error) {
+	if len(sgList) == 0 {
+		return nil, &aws.LookupError{Resource: "security group", Reason: "need to specify at least one security group"}
+	}
+	return aws.MakeStringArrayToAwsStrings(sgList), nil
+}
+
+func (e EC2) MakeBlockDevices(blocks []builder.BlockDevice) []*autoscaling.BlockDeviceMapping {
+	return aws.EC2Client{}.MakeBlockDevices(blocks)
+}
+
+func (e EC2) MakeLaunchTemplateBlockDeviceMappings(blocks []builder.BlockDevice) []*ec2.LaunchTemplateBlockDeviceMappingRequest {
+	return aws.EC2Client{}.MakeLaunchTemplateBlockDeviceMappings(blocks)
+}
+
+func (e EC2) GetVPCId(vpc string) (string, error) {
+	return vpc, nil
+}
+
+func (e EC2) CreateAutoScalingGroup(name, launch_template_name, healthcheck_type string, healthcheck_grace_period int64, capacity builder.Capacity, loadbalancers, target_group_arns, termination_policies, availability_zones []*string, tags []*(autoscaling.Tag), subnets []string, mixedInstancePolicy builder.MixedInstancesPolicy, hooks []*autoscaling.LifecycleHookSpecification) error {
+	if e.recorder.Record("CreateAutoScalingGroup", name, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	if _, ok := e.cloud.AutoScalingGroups[name]; ok {
+		return &aws.ResourceError{Action: "create autoscaling group", Resource: name, Err: fmt.Errorf("already exists")}
+	}
+
+	if _, ok := e.cloud.LaunchTemplates[launch_template_name]; !ok {
+		return &aws.ResourceError{Action: "create autoscaling group", Resource: name, Err: fmt.Errorf("launch template does not exist : %s", launch_template_name)}
+	}
+
+	group := &autoscaling.Group{
+		AutoScalingGroupName: sdk.String(name),
+		MinSize:              sdk.Int64(capacity.Min),
+		MaxSize:              sdk.Int64(capacity.Max),
+		DesiredCapacity:      sdk.Int64(capacity.Desired),
+		CreatedTime:          sdk.Time(time.Now()),
+		AvailabilityZones:    availability_zones,
+		TargetGroupARNs:      target_group_arns,
+		Tags:                 []*autoscaling.TagDescription{},
+	}
+
+	lt := &autoscaling.LaunchTemplateSpecification{LaunchTemplateName: sdk.String(launch_template_name)}
+	if mixedInstancePolicy.Enabled {
+		group.MixedInstancesPolicy = &autoscaling.MixedInstancesPolicy{
+			LaunchTemplate: &autoscaling.LaunchTemplate{LaunchTemplateSpecification: lt},
+		}
+	} else {
+		group.LaunchTemplate = lt
+	}
+
+	for _, tag := range tags {
+		group.Tags = append(group.Tags, &autoscaling.TagDescription{Key: tag.Key, Value: tag.Value})
+	}
+
+	e.cloud.AutoScalingGroups[name] = group
+	e.cloud.scale(group, e.region)
+
+	return nil
+}
+
+func (e EC2) GenerateTags(tagList []string, asg_name, app, stack, ansibleTags, extraTags, ansibleExtraVars, region string) []*autoscaling.Tag {
+	return aws.EC2Client{}.GenerateTags(tagList, asg_name, app, stack, ansibleTags, extraTags, ansibleExtraVars, region)
+}
+
+// GetAvailabilityZones returns zones in manifest or two zones of the region
+func (e EC2) GetAvailabilityZones(vpc string, azs []string) ([]string, error) {
+	if len(azs) > 0 {
+		return azs, nil
+	}
+	return []string{e.region + "a", e.region + "c"}, nil
+}
+
+func (e EC2) GetSubnets(vpc string, use_public_subnets bool, azs []string) ([]string, error) {
+	ret := []string{}
+	for _, az := range azs {
+		ret = append(ret, fmt.Sprintf("subnet-%s", az))
+	}
+	return ret, nil
+}
+
+func (e EC2) UpdateAutoScalingGroup(asg string, min, max, desired int64) error {
+	if e.recorder.Record("UpdateAutoScalingGroup", asg, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	group, ok := e.cloud.AutoScalingGroups[asg]
+	if !ok {
+		return fmt.Errorf("autoscaling group does not exist : %s", asg)
+	}
+
+	group.MinSize = sdk.Int64(min)
+	group.MaxSize = sdk.Int64(max)
+	group.DesiredCapacity = sdk.Int64(desired)
+	e.cloud.scale(group, e.region)
+
+	return nil
+}
+
+func (e EC2) UpdateAutoScalingGroupLaunchTemplate(asg, launch_template_name string, mixedInstancePolicyEnabled bool) error {
+	if e.recorder.Record("UpdateAutoScalingGroup", asg, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	group, ok := e.cloud.AutoScalingGroups[asg]
+	if !ok {
+		return fmt.Errorf("autoscaling group does not exist : %s", asg)
+	}
+
+	lt := &autoscaling.LaunchTemplateSpecification{LaunchTemplateName: sdk.String(launch_template_name)}
+	if mixedInstancePolicyEnabled {
+		group.MixedInstancesPolicy = &autoscaling.MixedInstancesPolicy{
+			LaunchTemplate: &autoscaling.LaunchTemplate{LaunchTemplateSpecification: lt},
+		}
+	} else {
+		group.LaunchTemplate = lt
+	}
+
+	return nil
+}
+
+// TerminateInstanceInAutoScalingGroup replaces the instance with a new one from the current launch template
+func (e EC2) TerminateInstanceInAutoScalingGroup(instanceId string) error {
+	if e.recorder.Record("TerminateInstanceInAutoScalingGroup", instanceId, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	for _, group := range e.cloud.AutoScalingGroups {
+		for i, instance := range group.Instances {
+			if *instance.InstanceId == instanceId {
+				delete(e.cloud.TargetHealth, instanceId)
+				group.Instances = append(group.Instances[:i], group.Instances[i+1:]...)
+				e.cloud.scale(group, e.region)
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("instance does not exist : %s", instanceId)
+}
+
+func (e EC2) CreateScalingPolicy(policy builder.ScalePolicy, asg_name string) (*string, error) {
+	if e.recorder.Record("PutScalingPolicy", policy.Name, nil) {
+		return sdk.String(fmt.Sprintf("dry-run:%s", policy.Name)), nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	if _, ok := e.cloud.AutoScalingGroups[asg_name]; !ok {
+		return nil, fmt.Errorf("autoscaling group does not exist : %s", asg_name)
+	}
+
+	arn := fmt.Sprintf("arn:aws:autoscaling:%s:000000000000:scalingPolicy:%s/%s", e.region, asg_name, policy.Name)
+	e.cloud.ScalingPolicies[arn] = asg_name
+
+	return sdk.String(arn), nil
+}
+
+func (e EC2) EnableMetrics(asg_name string) error {
+	if e.recorder.Record("EnableMetricsCollection", asg_name, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	e.cloud.MetricsEnabled[asg_name] = true
+
+	return nil
+}
+
+func (e EC2) TagAutoScalingGroup(asg_name string, tags map[string]string) error {
+	if e.recorder.Record("CreateOrUpdateTags", asg_name, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	group, ok := e.cloud.AutoScalingGroups[asg_name]
+	if !ok {
+		return fmt.Errorf("autoscaling group not found : %s", asg_name)
+	}
+
+	for k, v := range tags {
+		replaced := false
+		for _, t := range group.Tags {
+			if *t.Key == k {
+				t.Value = sdk.String(v)
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			group.Tags = append(group.Tags, &autoscaling.TagDescription{Key: sdk.String(k), Value: sdk.String(v)})
+		}
+	}
+
+	return nil
+}
+
+func (e EC2) GenerateLifecycleHooks(hooks builder.LifecycleHooks) []*autoscaling.LifecycleHookSpecification {
+	return aws.EC2Client{}.GenerateLifecycleHooks(hooks)
+}
+
+// scale launches or terminates instances to meet the desired capacity
+// New instances are in service at once with the current launch template.
+func (c *Cloud) scale(group *autoscaling.Group, region string) {
+	desired := int(*group.DesiredCapacity)
+
+	for len(group.Instances) > desired {
+		last := group.Instances[len(group.Instances)-1]
+		delete(c.TargetHealth, *last.InstanceId)
+		group.Instances = group.Instances[:len(group.Instances)-1]
+	}
+
+	lt := group.LaunchTemplate
+	if lt == nil && group.MixedInstancesPolicy != nil {
+		lt = group.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+	}
+
+	for len(group.Instances) < desired {
+		id := fmt.Sprintf("i-%017d", c.next())
+		group.Instances = append(group.Instances, &autoscaling.Instance{
+			InstanceId:     sdk.String(id),
+			LifecycleState: sdk.String("InService"),
+			HealthStatus:   sdk.String("Healthy"),
+			LaunchTemplate: &autoscaling.LaunchTemplateSpecification{LaunchTemplateName: lt.LaunchTemplateName},
+		})
+		c.TargetHealth[id] = c.DefaultTargetHealth
+		if state, ok := c.RegionTargetHealth[region]; ok {
+			c.TargetHealth[id] = state
+		}
+	}
+}
+
+// copyGroup copies autoscaling group so that callers cannot change the fake cloud
+func copyGroup(group *autoscaling.Group) *autoscaling.Group {
+	ret := *group
+	ret.Instances = []*autoscaling.Instance{}
+	for _, instance := range group.Instances {
+		i := *instance
+		ret.Instances = append(ret.Instances, &i)
+	}
+	return &ret
+}
+
+// ResolveAmi returns the newest image of the region which matches the selector
+func (e EC2) ResolveAmi(selector builder.AmiSelector) (string, error) {
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	if len(selector.SSMParameter) > 0 {
+		ami, ok := e.cloud.Parameters[selector.SSMParameter]
+		if !ok {
+			return "", &aws.LookupError{Resource: "ssm parameter", Name: selector.SSMParameter, Reason: "parameter does not exist"}
+		}
+		return ami, nil
+	}
+
+	var latest *Image
+	for i, image := range e.cloud.Images {
+		if image.Region != e.region || !matchImage(image, selector) {
+			continue
+		}
+
+		if latest == nil || image.CreationDate > latest.CreationDate {
+			latest = &e.cloud.Images[i]
+		}
+	}
+
+	if latest == nil {
+		return "", &aws.LookupError{Resource: "AMI", Name: selector.String(), Reason: "no image matches the selector"}
+	}
+
+	return latest.Id, nil
+}
+
+func matchImage(image Image, selector builder.AmiSelector) bool {
+	owner := image.Owner
+	if len(owner) == 0 {
+		owner = "self"
+	}
+
+	if !tool.IsStringInArray(owner, selector.GetOwners()) {
+		return false
+	}
+
+	if len(selector.Name) > 0 {
+		if matched, _ := path.Match(selector.Name, image.Name); !matched {
+			return false
+		}
+	}
+
+	for k, v := range selector.Tags {
+		if image.Tags[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckImage accepts any AMI so that deployments can use literal AMI IDs without registering images
+func (e EC2) CheckImage(ami string) error {
+	return nil
+}
+
+func (e EC2) CheckKeyPair(name string) error {
+	return nil
+}
+
+// GetInstanceTypeZones returns every zone of the region
+func (e EC2) GetInstanceTypeZones(instanceType string) ([]string, error) {
+	return []string{e.region + "a", e.region + "b", e.region + "c", e.region + "d"}, nil
+}
+
+// GetLaunchTemplateData returns AMI and instance type of the launch template
+// The fake cloud has only one version of each launch template.
+func (e EC2) GetLaunchTemplateData(name, version string) (*ec2.ResponseLaunchTemplateData, error) {
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	lt, ok := e.cloud.LaunchTemplates[name]
+	if !ok {
+		return nil, &aws.LookupError{Resource: "launch template", Name: name, Reason: "launch template does not exist"}
+	}
+
+	return &ec2.ResponseLaunchTemplateData{
+		ImageId:      sdk.String(lt.Ami),
+		InstanceType: sdk.String(lt.InstanceType),
+	}, nil
+}
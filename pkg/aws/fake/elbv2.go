@@ -0,0 +1,98 @@
+package fake
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	sdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+)
+
+// ELBV2 simulates target groups, target health and weights of listeners
+type ELBV2 struct {
+	cloud    *Cloud
+	region   string
+	recorder *aws.Recorder
+}
+
+// GetTargetGroupARNs returns arn of target groups
+// Target groups which are not added yet are created on demand.
+func (e ELBV2) GetTargetGroupARNs(target_groups []string) ([]*string, error) {
+	if len(target_groups) == 0 {
+		return nil, nil
+	}
+
+	ret := []*string{}
+	for _, name := range target_groups {
+		e.cloud.mu.Lock()
+		arn, ok := e.cloud.TargetGroups[name]
+		e.cloud.mu.Unlock()
+
+		if !ok {
+			arn = e.cloud.AddTargetGroup(e.region, name)
+		}
+		ret = append(ret, sdk.String(arn))
+	}
+
+	return ret, nil
+}
+
+func (e ELBV2) GetHostInTarget(group *autoscaling.Group, target_group_arn *string) ([]aws.HealthcheckHost, error) {
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	ret := []aws.HealthcheckHost{}
+	for _, instance := range group.Instances {
+		target_state, ok := e.cloud.TargetHealth[*instance.InstanceId]
+		if !ok {
+			target_state = tool.INITIAL_STATUS
+		}
+
+		ret = append(ret, aws.HealthcheckHost{
+			InstanceId:     *instance.InstanceId,
+			LifecycleState: *instance.LifecycleState,
+			TargetStatus:   target_state,
+			HealthStatus:   *instance.HealthStatus,
+			Healthy:        *instance.LifecycleState == "InService" && target_state == "healthy" && *instance.HealthStatus == "Healthy",
+		})
+	}
+
+	return ret, nil
+}
+
+func (e ELBV2) GetForwardWeights(arn string) (map[string]int64, error) {
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	weights, ok := e.cloud.Weights[arn]
+	if !ok {
+		return nil, fmt.Errorf("no forward action found : %s", arn)
+	}
+
+	ret := map[string]int64{}
+	for tg, weight := range weights {
+		ret[tg] = weight
+	}
+
+	return ret, nil
+}
+
+func (e ELBV2) ModifyForwardWeights(arn string, weights map[string]int64) error {
+	if e.recorder.Record("ModifyListener", arn, nil) {
+		return nil
+	}
+
+	e.cloud.mu.Lock()
+	defer e.cloud.mu.Unlock()
+
+	if _, ok := e.cloud.Weights[arn]; !ok {
+		return fmt.Errorf("no listener found : %s", arn)
+	}
+
+	// Target groups which are not in weights keep their weights
+	for tg, weight := range weights {
+		e.cloud.Weights[arn][tg] = weight
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package fake
+
+import (
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// IAM accepts every instance profile and denies actions in DeniedActions
+type IAM struct {
+	cloud *Cloud
+}
+
+func (i IAM) CheckInstanceProfile(name string) error {
+	return nil
+}
+
+// GetMissingPermissions returns actions in DeniedActions of the fake cloud
+func (i IAM) GetMissingPermissions(principal string, actions []string) ([]string, error) {
+	i.cloud.mu.Lock()
+	defer i.cloud.mu.Unlock()
+
+	missing := []string{}
+	for _, action := range actions {
+		if tool.IsStringInArray(action, i.cloud.DeniedActions) {
+			missing = append(missing, action)
+		}
+	}
+
+	return missing, nil
+}
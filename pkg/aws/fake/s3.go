@@ -0,0 +1,35 @@
+package fake
+
+import (
+	"fmt"
+)
+
+// S3 serves objects stored with PutObject
+type S3 struct {
+	cloud *Cloud
+}
+
+func (s S3) GetObject(bucket, key, version string) ([]byte, error) {
+	s.cloud.mu.Lock()
+	defer s.cloud.mu.Unlock()
+
+	body, ok := s.cloud.Objects[objectName(bucket, key, version)]
+	if !ok {
+		return nil, fmt.Errorf("object does not exist : %s", objectName(bucket, key, version))
+	}
+
+	return body, nil
+}
+
+func (s S3) HeadObject(bucket, key, version string) (int64, error) {
+	body, err := s.GetObject(bucket, key, version)
+	return int64(len(body)), err
+}
+
+// objectName returns the key of object in the fake cloud
+func objectName(bucket, key, version string) string {
+	if len(version) > 0 {
+		return fmt.Sprintf("s3://%s/%s?versionId=%s", bucket, key, version)
+	}
+	return fmt.Sprintf("s3://%s/%s", bucket, key)
+}
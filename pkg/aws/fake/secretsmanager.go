@@ -0,0 +1,22 @@
+package fake
+
+import (
+	"fmt"
+)
+
+// SecretsManager serves secrets stored in the fake cloud
+type SecretsManager struct {
+	cloud *Cloud
+}
+
+func (s SecretsManager) GetSecretValue(id string) (string, error) {
+	s.cloud.mu.Lock()
+	defer s.cloud.mu.Unlock()
+
+	value, ok := s.cloud.Secrets[id]
+	if !ok {
+		return "", fmt.Errorf("secret does not exist : %s", id)
+	}
+
+	return value, nil
+}
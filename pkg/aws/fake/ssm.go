@@ -0,0 +1,38 @@
+package fake
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	sdk "github.com/aws/aws-sdk-go/aws"
+)
+
+// SSM keeps commands sent to instances
+type SSM struct {
+	cloud    *Cloud
+	recorder *aws.Recorder
+}
+
+func (s SSM) SendCommand(target []*string, commands []*string) bool {
+	if s.recorder.Record("SendCommand", "AWS-RunShellScript", nil) {
+		return true
+	}
+
+	s.cloud.mu.Lock()
+	defer s.cloud.mu.Unlock()
+
+	s.cloud.Commands = append(s.cloud.Commands, sdk.StringValueSlice(commands))
+
+	return true
+}
+
+func (s SSM) GetParameter(name string) (string, error) {
+	s.cloud.mu.Lock()
+	defer s.cloud.mu.Unlock()
+
+	value, ok := s.cloud.Parameters[name]
+	if !ok {
+		return "", fmt.Errorf("parameter does not exist : %s", name)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,10 @@
+package fake
+
+// STS returns the identity of the fake account
+type STS struct {
+	cloud *Cloud
+}
+
+func (s STS) GetCallerIdentity() (string, error) {
+	return "arn:aws:iam::000000000000:user/goployer", nil
+}
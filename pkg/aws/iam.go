@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"sort"
+	"strings"
+)
+
+// IAMAPI is the interface of IAM operations which goployer uses
+type IAMAPI interface {
+	CheckInstanceProfile(name string) error
+	GetMissingPermissions(principal string, actions []string) ([]string, error)
+}
+
+type IAMClient struct {
+	Client *iam.IAM
+}
+
+func NewIAMClient(session *session.Session, region string, creds *credentials.Credentials) IAMClient {
+	return IAMClient{
+		Client: getIAMClientFn(session, region, creds),
+	}
+}
+
+func getIAMClientFn(session *session.Session, region string, creds *credentials.Credentials) *iam.IAM {
+	if creds == nil {
+		return iam.New(session, &aws.Config{Region: aws.String(region)})
+	}
+	return iam.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
+}
+
+// CheckInstanceProfile checks if the instance profile exists
+// IAM is global so that the result is the same in every region.
+func (i IAMClient) CheckInstanceProfile(name string) error {
+	input := &iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+	}
+
+	if _, err := i.Client.GetInstanceProfile(input); err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case iam.ErrCodeNoSuchEntityException:
+				return &LookupError{Resource: "instance profile", Name: name, Reason: "instance profile does not exist"}
+			}
+		}
+		return &LookupError{Resource: "instance profile", Name: name, Err: err}
+	}
+
+	return nil
+}
+
+// GetMissingPermissions returns actions which the principal is not allowed to call
+// principal is ARN of caller identity. Policies are simulated for all resources,
+// so actions allowed only for specific resources or with conditions are reported as missing.
+func (i IAMClient) GetMissingPermissions(principal string, actions []string) ([]string, error) {
+	// Root user can call every action, and it cannot be simulated
+	if strings.HasSuffix(principal, ":root") {
+		return nil, nil
+	}
+
+	source, err := i.policySourceArn(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(source),
+		ActionNames:     aws.StringSlice(actions),
+	}
+
+	missing := []string{}
+	err = i.Client.SimulatePrincipalPolicyPages(input, func(page *iam.SimulatePolicyResponse, lastPage bool) bool {
+		for _, result := range page.EvaluationResults {
+			if aws.StringValue(result.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+				missing = append(missing, aws.StringValue(result.EvalActionName))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, &LookupError{Resource: "policy simulation", Name: source, Err: err}
+	}
+
+	sort.Strings(missing)
+
+	return missing, nil
+}
+
+// policySourceArn returns ARN of the role or user of which policies are simulated
+// e.g. arn:aws:sts::123456789012:assumed-role/deploy/session is arn:aws:iam::123456789012:role/deploy
+func (i IAMClient) policySourceArn(principal string) (string, error) {
+	parts := strings.SplitN(principal, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" {
+		return principal, nil
+	}
+
+	resource := strings.Split(parts[5], "/")
+	if len(resource) < 2 || resource[0] != "assumed-role" {
+		return "", &LookupError{Resource: "policy simulation", Name: principal, Reason: "only users and roles can be simulated"}
+	}
+
+	// Path of the role is not in the ARN of session
+	result, err := i.Client.GetRole(&iam.GetRoleInput{RoleName: aws.String(resource[1])})
+	if err != nil {
+		return fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], parts[4], resource[1]), nil
+	}
+
+	return aws.StringValue(result.Role.Arn), nil
+}
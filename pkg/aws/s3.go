@@ -0,0 +1,130 @@
+package aws
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"io/ioutil"
+)
+
+// S3API is the interface of S3 operations which goployer uses
+type S3API interface {
+	GetObject(bucket, key, version string) ([]byte, error)
+	HeadObject(bucket, key, version string) (int64, error)
+}
+
+type S3Client struct {
+	Client  *s3.S3
+	Session *session.Session
+	Creds   *credentials.Credentials
+}
+
+func NewS3Client(session *session.Session, region string, creds *credentials.Credentials) S3Client {
+	return S3Client{
+		Client:  getS3ClientFn(session, region, creds),
+		Session: session,
+		Creds:   creds,
+	}
+}
+
+func getS3ClientFn(session *session.Session, region string, creds *credentials.Credentials) *s3.S3 {
+	if creds == nil {
+		return s3.New(session, &aws.Config{Region: aws.String(region)})
+	}
+	return s3.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
+}
+
+// clientForBucket returns the client of the region in which the bucket is
+// Buckets in other regions cannot be read with the client of the deploying region.
+func (s S3Client) clientForBucket(bucket string) (*s3.S3, error) {
+	region, err := s3manager.GetBucketRegionWithClient(aws.BackgroundContext(), s.Client, bucket)
+	if err != nil {
+		return nil, &LookupError{Resource: "s3 bucket", Name: bucket, Err: err}
+	}
+
+	if region == aws.StringValue(s.Client.Config.Region) {
+		return s.Client, nil
+	}
+
+	return getS3ClientFn(s.Session, region, s.Creds), nil
+}
+
+// GetObject returns the content of the object
+// The latest version is returned if version is empty.
+func (s S3Client) GetObject(bucket, key, version string) ([]byte, error) {
+	client, err := s.clientForBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+
+	if len(version) > 0 {
+		input.VersionId = aws.String(version)
+	}
+
+	result, err := client.GetObject(input)
+	if err != nil {
+		return nil, s3LookupError(bucket, key, version, err)
+	}
+	defer result.Body.Close()
+
+	body, err := ioutil.ReadAll(result.Body)
+	if err != nil {
+		return nil, s3LookupError(bucket, key, version, err)
+	}
+
+	return body, nil
+}
+
+// HeadObject checks if the object exists and returns its size
+func (s S3Client) HeadObject(bucket, key, version string) (int64, error) {
+	client, err := s.clientForBucket(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+
+	if len(version) > 0 {
+		input.VersionId = aws.String(version)
+	}
+
+	result, err := client.HeadObject(input)
+	if err != nil {
+		return 0, s3LookupError(bucket, key, version, err)
+	}
+
+	return aws.Int64Value(result.ContentLength), nil
+}
+
+// s3LookupError makes the error of S3 object readable
+func s3LookupError(bucket, key, version string, err error) error {
+	name := fmt.Sprintf("s3://%s/%s", bucket, key)
+	if len(version) > 0 {
+		name = fmt.Sprintf("%s?versionId=%s", name, version)
+	}
+
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchBucket:
+			return &LookupError{Resource: "s3 object", Name: name, Reason: "bucket does not exist"}
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return &LookupError{Resource: "s3 object", Name: name, Reason: "object does not exist"}
+		case "Forbidden", "AccessDenied":
+			return &LookupError{Resource: "s3 object", Name: name, Reason: "access is denied"}
+		}
+	}
+
+	return &LookupError{Resource: "s3 object", Name: name, Err: err}
+}
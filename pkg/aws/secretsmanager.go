@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// SecretsManagerAPI is the interface of secrets manager operations which goployer uses
+type SecretsManagerAPI interface {
+	GetSecretValue(id string) (string, error)
+}
+
+type SecretsManagerClient struct {
+	Client *secretsmanager.SecretsManager
+}
+
+func NewSecretsManagerClient(session *session.Session, region string, creds *credentials.Credentials) SecretsManagerClient {
+	return SecretsManagerClient{
+		Client: getSecretsManagerClientFn(session, region, creds),
+	}
+}
+
+func getSecretsManagerClientFn(session *session.Session, region string, creds *credentials.Credentials) *secretsmanager.SecretsManager {
+	if creds == nil {
+		return secretsmanager.New(session, &aws.Config{Region: aws.String(region)})
+	}
+	return secretsmanager.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
+}
+
+// GetSecretValue returns the current value of the secret
+// id can be the name or ARN of the secret.
+func (s SecretsManagerClient) GetSecretValue(id string) (string, error) {
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	}
+
+	result, err := s.Client.GetSecretValue(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case secretsmanager.ErrCodeResourceNotFoundException:
+				return "", &LookupError{Resource: "secret", Name: id, Reason: "secret does not exist"}
+			case secretsmanager.ErrCodeDecryptionFailure:
+				return "", &LookupError{Resource: "secret", Name: id, Reason: "secret cannot be decrypted"}
+			}
+		}
+		return "", &LookupError{Resource: "secret", Name: id, Err: err}
+	}
+
+	if result.SecretString != nil {
+		return aws.StringValue(result.SecretString), nil
+	}
+
+	return string(result.SecretBinary), nil
+}
@@ -9,8 +9,15 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// SSMAPI is the interface of systems manager operations which goployer uses
+type SSMAPI interface {
+	SendCommand(target []*string, commands []*string) bool
+	GetParameter(name string) (string, error)
+}
+
 type SSMClient struct {
-	Client *ssm.SSM
+	Client   *ssm.SSM
+	Recorder *Recorder
 }
 
 func NewSSMClient(session *session.Session, region string, creds *credentials.Credentials) SSMClient {
@@ -38,6 +45,10 @@ func (s SSMClient) SendCommand(target []*string, commands []*string) bool {
 		},
 	}
 
+	if s.Recorder.Record("SendCommand", "AWS-RunShellScript", input) {
+		return true
+	}
+
 	_, err := s.Client.SendCommand(input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
@@ -63,3 +74,26 @@ func (s SSMClient) SendCommand(target []*string, commands []*string) bool {
 
 	return true
 }
+
+// GetParameter returns the decrypted value of the parameter
+func (s SSMClient) GetParameter(name string) (string, error) {
+	input := &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	}
+
+	result, err := s.Client.GetParameter(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case ssm.ErrCodeParameterNotFound:
+				return "", &LookupError{Resource: "ssm parameter", Name: name, Reason: "parameter does not exist"}
+			case ssm.ErrCodeParameterVersionNotFound:
+				return "", &LookupError{Resource: "ssm parameter", Name: name, Reason: "version of parameter does not exist"}
+			}
+		}
+		return "", &LookupError{Resource: "ssm parameter", Name: name, Err: err}
+	}
+
+	return aws.StringValue(result.Parameter.Value), nil
+}
@@ -0,0 +1,41 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// STSAPI is the interface of STS operations which goployer uses
+type STSAPI interface {
+	GetCallerIdentity() (string, error)
+}
+
+type STSClient struct {
+	Client *sts.STS
+}
+
+func NewSTSClient(session *session.Session, region string, creds *credentials.Credentials) STSClient {
+	return STSClient{
+		Client: getSTSClientFn(session, region, creds),
+	}
+}
+
+func getSTSClientFn(session *session.Session, region string, creds *credentials.Credentials) *sts.STS {
+	if creds == nil {
+		return sts.New(session, &aws.Config{Region: aws.String(region)})
+	}
+	return sts.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
+}
+
+// GetCallerIdentity returns ARN of the principal whose credentials are used
+// It fails if the assume role cannot be assumed.
+func (s STSClient) GetCallerIdentity() (string, error) {
+	result, err := s.Client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", &LookupError{Resource: "caller identity", Name: aws.StringValue(s.Client.Config.Region), Err: err}
+	}
+
+	return aws.StringValue(result.Arn), nil
+}
@@ -0,0 +1,178 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	AMI_ID_PREFIX       = "ami-"
+	AMI_SSM_PREFIXES    = []string{"resolve:ssm:", "ssm:"}
+	AMI_SELECTOR_NAME   = "name"
+	AMI_SELECTOR_OWNER  = "owner"
+	AMI_SELECTOR_TAG    = "tag:"
+	DEFAULT_AMI_OWNERS  = []string{"self"}
+	availableAmiOptions = []string{AMI_SELECTOR_NAME, AMI_SELECTOR_OWNER, AMI_SELECTOR_TAG + "<key>"}
+)
+
+// AmiSelector finds the AMI in each region instead of the literal AMI ID
+// The newest image which matches name, owners and tags is selected,
+// or the AMI ID is read from SSM parameter like the public parameters of Amazon Linux.
+type AmiSelector struct {
+	Name         string            `yaml:"name"`
+	Owners       []string          `yaml:"owners"`
+	Tags         map[string]string `yaml:"tags"`
+	SSMParameter string            `yaml:"ssm_parameter"`
+}
+
+// IsAmiId checks if the value is the literal AMI ID
+func IsAmiId(ami string) bool {
+	return strings.HasPrefix(ami, AMI_ID_PREFIX)
+}
+
+// ParseAmiSelector parses the AMI selector of command line
+// e.g. name=hello-*,owner=self,tag:Env=prod or ssm:/aws/service/ami-amazon-linux-latest/amzn2-ami-hvm-x86_64-gp2
+func ParseAmiSelector(value string) (AmiSelector, error) {
+	selector := AmiSelector{}
+	for _, prefix := range AMI_SSM_PREFIXES {
+		if strings.HasPrefix(value, prefix) {
+			selector.SSMParameter = strings.TrimPrefix(value, prefix)
+			return selector, selector.Validate()
+		}
+	}
+
+	for _, option := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(option), "=", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return selector, fmt.Errorf("wrong format of ami selector : %s, available options: %s", option, strings.Join(availableAmiOptions, ", "))
+		}
+
+		switch {
+		case kv[0] == AMI_SELECTOR_NAME:
+			selector.Name = kv[1]
+		case kv[0] == AMI_SELECTOR_OWNER:
+			selector.Owners = append(selector.Owners, kv[1])
+		case strings.HasPrefix(kv[0], AMI_SELECTOR_TAG) && len(kv[0]) > len(AMI_SELECTOR_TAG):
+			if selector.Tags == nil {
+				selector.Tags = map[string]string{}
+			}
+			selector.Tags[strings.TrimPrefix(kv[0], AMI_SELECTOR_TAG)] = kv[1]
+		default:
+			return selector, fmt.Errorf("not available option of ami selector : %s, available options: %s", kv[0], strings.Join(availableAmiOptions, ", "))
+		}
+	}
+
+	return selector, selector.Validate()
+}
+
+// Validate checks if the selector can find only one kind of image
+func (s AmiSelector) Validate() error {
+	if len(s.SSMParameter) > 0 {
+		if len(s.Name) > 0 || len(s.Owners) > 0 || len(s.Tags) > 0 {
+			return fmt.Errorf("ssm parameter of ami selector cannot be used with other filters : %s", s.SSMParameter)
+		}
+		return nil
+	}
+
+	if len(s.Name) == 0 && len(s.Tags) == 0 {
+		return fmt.Errorf("ami selector needs name or tags to find the image")
+	}
+
+	return nil
+}
+
+// GetOwners returns owners of the image
+// Only images of the account are selected by default.
+func (s AmiSelector) GetOwners() []string {
+	if len(s.Owners) == 0 {
+		return DEFAULT_AMI_OWNERS
+	}
+
+	return s.Owners
+}
+
+func (s AmiSelector) String() string {
+	if len(s.SSMParameter) > 0 {
+		return fmt.Sprintf("ssm:%s", s.SSMParameter)
+	}
+
+	options := []string{}
+	if len(s.Name) > 0 {
+		options = append(options, fmt.Sprintf("%s=%s", AMI_SELECTOR_NAME, s.Name))
+	}
+
+	for _, owner := range s.GetOwners() {
+		options = append(options, fmt.Sprintf("%s=%s", AMI_SELECTOR_OWNER, owner))
+	}
+
+	keys := []string{}
+	for k := range s.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		options = append(options, fmt.Sprintf("%s%s=%s", AMI_SELECTOR_TAG, k, s.Tags[k]))
+	}
+
+	return strings.Join(options, ",")
+}
+
+// GetAmi returns the literal AMI ID or the selector of the region
+// --ami of command line overrides the manifest, and the region overrides the stack.
+func GetAmi(config Config, stack Stack, region RegionConfig) (string, *AmiSelector, error) {
+	if len(config.Ami) > 0 {
+		if IsAmiId(config.Ami) {
+			return config.Ami, nil, nil
+		}
+
+		selector, err := ParseAmiSelector(config.Ami)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "", &selector, nil
+	}
+
+	if len(region.AmiId) > 0 {
+		return region.AmiId, nil, nil
+	}
+
+	if region.AmiSelector != nil {
+		return "", region.AmiSelector, nil
+	}
+
+	if stack.AmiSelector != nil {
+		return "", stack.AmiSelector, nil
+	}
+
+	return "", nil, fmt.Errorf("you have to specify at least one ami id or ami selector in %s", region.Region)
+}
+
+// checkAmi checks AMI of every region in the stack
+func checkAmi(config Config, stack Stack) error {
+	if stack.AmiSelector != nil {
+		if err := stack.AmiSelector.Validate(); err != nil {
+			return fmt.Errorf("ami selector of %s : %s", stack.Stack, err.Error())
+		}
+	}
+
+	for _, region := range stack.Regions {
+		if len(region.AmiId) > 0 && region.AmiSelector != nil {
+			return fmt.Errorf("ami_id and ami_selector cannot be used at the same time in %s of %s", region.Region, stack.Stack)
+		}
+
+		if region.AmiSelector != nil {
+			if err := region.AmiSelector.Validate(); err != nil {
+				return fmt.Errorf("ami selector of %s in %s : %s", stack.Stack, region.Region, err.Error())
+			}
+		}
+
+		if _, _, err := GetAmi(config, stack, region); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
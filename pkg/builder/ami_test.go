@@ -0,0 +1,72 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAmiSelector(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  AmiSelector
+		err   string
+	}{
+		{
+			name:  "name",
+			value: "name=hello-*",
+			want:  AmiSelector{Name: "hello-*"},
+		},
+		{
+			name:  "name, owners and tags",
+			value: "name=hello-*, owner=self,owner=123456789012,tag:Env=prod",
+			want:  AmiSelector{Name: "hello-*", Owners: []string{"self", "123456789012"}, Tags: map[string]string{"Env": "prod"}},
+		},
+		{
+			name:  "tags only",
+			value: "tag:App=hello,tag:Env=prod",
+			want:  AmiSelector{Tags: map[string]string{"App": "hello", "Env": "prod"}},
+		},
+		{
+			name:  "value with equal sign",
+			value: "tag:Query=a=b",
+			want:  AmiSelector{Tags: map[string]string{"Query": "a=b"}},
+		},
+		{
+			name:  "ssm parameter",
+			value: "ssm:/aws/service/ami-amazon-linux-latest/amzn2-ami-hvm-x86_64-gp2",
+			want:  AmiSelector{SSMParameter: "/aws/service/ami-amazon-linux-latest/amzn2-ami-hvm-x86_64-gp2"},
+		},
+		{
+			name:  "ssm parameter of CloudFormation style",
+			value: "resolve:ssm:/golden/ami",
+			want:  AmiSelector{SSMParameter: "/golden/ami"},
+		},
+		{name: "owner only", value: "owner=self", err: "needs name or tags"},
+		{name: "option without value", value: "name=", err: "wrong format of ami selector"},
+		{name: "option without equal sign", value: "hello", err: "wrong format of ami selector"},
+		{name: "tag without key", value: "tag:=prod", err: "not available option of ami selector : tag:"},
+		{name: "unknown option", value: "arch=x86_64", err: "not available option of ami selector : arch"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseAmiSelector(test.value)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ParseAmiSelector(%q) error = %v, want %q", test.value, err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseAmiSelector(%q) error = %v", test.value, err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseAmiSelector(%q) = %+v, want %+v", test.value, got, test.want)
+			}
+		})
+	}
+}
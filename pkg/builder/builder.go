@@ -1,47 +1,79 @@
 package builder
 
 import (
-	"encoding/base64"
-	"flag"
 	"fmt"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 	Logger "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var (
-	NO_MANIFEST_EXISTS               = "Manifest file does not exist"
-	DFEAULT_SPOT_ALLOCATION_STRATEGY = "lowest-price"
-	DEFAULT_DEPLOYMENT_TIMEOUT       = 60 * time.Minute
-	DEFAULT_POLLING_INTERVAL         = 60 * time.Second
-	MIN_POLLING_INTERVAL             = 5 * time.Second
-	availableBlockTypes              = []string{"io1", "gp2", "st1", "sc1"}
+	NO_MANIFEST_EXISTS                = "Manifest file does not exist"
+	DFEAULT_SPOT_ALLOCATION_STRATEGY  = "lowest-price"
+	DEFAULT_DEPLOYMENT_TIMEOUT        = 60 * time.Minute
+	DEFAULT_POLLING_INTERVAL          = 60 * time.Second
+	MIN_POLLING_INTERVAL              = 5 * time.Second
+	availableBlockTypes               = []string{"io1", "gp2", "st1", "sc1"}
+	REPLACEMENT_TYPE_BLUEGREEN        = "BlueGreen"
+	REPLACEMENT_TYPE_ROLLING          = "Rolling"
+	REPLACEMENT_TYPE_CANARY           = "Canary"
+	availableReplacementTypes         = []string{REPLACEMENT_TYPE_BLUEGREEN, REPLACEMENT_TYPE_ROLLING, REPLACEMENT_TYPE_CANARY}
+	DEFAULT_ROLLING_BATCH_SIZE        = int64(1)
+	DEFAULT_CANARY_STAGES             = []int64{10, 50, 100}
+	DEFAULT_CANARY_BAKE_TIME          = 5 * time.Minute
+	DEFAULT_TRAFFIC_SHIFTING_STEPS    = []int64{10, 50, 100}
+	DEFAULT_TRAFFIC_SHIFTING_INTERVAL = 60 * time.Second
+	availableComparisonOperators      = []string{"GreaterThanOrEqualToThreshold", "GreaterThanThreshold", "LessThanThreshold", "LessThanOrEqualToThreshold"}
+	INTERRUPT_ACTION_ROLLBACK         = "rollback"
+	INTERRUPT_ACTION_ABANDON          = "abandon"
+	availableInterruptActions         = []string{INTERRUPT_ACTION_ROLLBACK, INTERRUPT_ACTION_ABANDON}
+	REGION_FAILURE_POLICY_STOP        = "stop"
+	REGION_FAILURE_POLICY_CONTINUE    = "continue"
+	availableRegionFailurePolicies    = []string{REGION_FAILURE_POLICY_STOP, REGION_FAILURE_POLICY_CONTINUE}
+	USERDATA_TYPE_LOCAL               = "local"
+	USERDATA_TYPE_S3                  = "s3"
+	USERDATA_TYPE_SSM                 = "ssm"
+	USERDATA_TYPE_SECRETSMANAGER      = "secretsmanager"
+	USERDATA_TYPE_INLINE              = "inline"
+	availableUserdataTypes            = []string{USERDATA_TYPE_LOCAL, USERDATA_TYPE_S3, USERDATA_TYPE_SSM, USERDATA_TYPE_SECRETSMANAGER, USERDATA_TYPE_INLINE}
+	MAX_USERDATA_SIZE                 = 16 * 1024
+	OUTPUT_FORMAT_TABLE               = "table"
+	OUTPUT_FORMAT_JSON                = "json"
+	availableOutputFormats            = []string{OUTPUT_FORMAT_TABLE, OUTPUT_FORMAT_JSON}
 )
 
 type UserdataProvider interface {
-	Provide() string
+	Provide(vars UserdataVars) (string, error)
 }
 
 type LocalProvider struct {
-	Path string
+	Path     string
+	Template bool
+	Vars     map[string]string
 }
 
-type S3Provider struct {
-	Path string
+// EncodedProvider provides userdata which is already encoded with base64
+type EncodedProvider struct {
+	Userdata string
 }
 
 type Builder struct {
-	Config       Config       // Config from command
-	AwsConfig    AWSConfig    // Common Config
-	MetricConfig MetricConfig // Configuration for metrics
-	Stacks       []Stack      // Stack Config
+	Config            Config               // Config from command
+	AwsConfig         AWSConfig            // Common Config
+	MetricConfig      MetricConfig         // Configuration for metrics
+	Stacks            []Stack              // Stack Config
+	Sources           ManifestSources      // Files from which fields of stacks come
+	UserdataBootstrap UserdataBootstrapper // Clients to check userdata in AWS
 }
 
 type Config struct {
 	Manifest              string
+	Values                string
 	Ami                   string
 	Env                   string
 	Stack                 string
@@ -60,13 +92,31 @@ type Config struct {
 	ReleaseNotesBase64    string
 	ForceManifestCapacity bool
 	PollingInterval       time.Duration
+	DryRun                bool
+	OnInterrupt           string
+	WaitForLock           time.Duration
+	StateFile             string
+	ParallelStacks        bool
+	RegionConcurrency     int
+	RegionFailurePolicy   string
+	SkipPreflight         bool
+	SkipPermissionCheck   bool
+	Output                string
+	Since                 string
+	Until                 string
 }
 
 type YamlConfig struct {
-	Name     string   `yaml:"name"`
-	Userdata Userdata `yaml:"userdata"`
-	Tags     []string `yaml:"tags"`
-	Stacks   []Stack  `yaml:"stacks"`
+	Name      string            `yaml:"name"`
+	Include   []string          `yaml:"include"`
+	Variables map[string]string `yaml:"variables"`
+	Userdata  Userdata          `yaml:"userdata"`
+	Tags      []string          `yaml:"tags"`
+	Stacks    []Stack           `yaml:"stacks"`
+
+	// Policies and alarms which stacks refer to with YAML anchors
+	Autoscaling []ScalePolicy  `yaml:"autoscaling"`
+	Alarms      []AlarmConfigs `yaml:"alarms"`
 }
 
 type AWSConfig struct {
@@ -76,8 +126,13 @@ type AWSConfig struct {
 }
 
 type Userdata struct {
-	Type string `yaml:"type"`
-	Path string `yaml:"path"`
+	Type        string            `yaml:"type"`
+	Path        string            `yaml:"path"`
+	Content     string            `yaml:"content"`
+	ContentType string            `yaml:"content_type"`
+	Template    bool              `yaml:"template"`
+	Vars        map[string]string `yaml:"vars"`
+	Parts       []Userdata        `yaml:"parts"`
 }
 
 type ScalePolicy struct {
@@ -104,6 +159,9 @@ type Stack struct {
 	Account               string                `yaml:"account"`
 	Env                   string                `yaml:"env"`
 	ReplacementType       string                `yaml:"replacement_type"`
+	RollingUpdate         RollingUpdate         `yaml:"rolling_update"`
+	Canary                Canary                `yaml:"canary"`
+	TrafficShifting       TrafficShifting       `yaml:"traffic_shifting"`
 	Userdata              Userdata              `yaml:"userdata"`
 	IamInstanceProfile    string                `yaml:"iam_instance_profile"`
 	AnsibleTags           string                `yaml:"ansible_tags"`
@@ -118,7 +176,40 @@ type Stack struct {
 	LifecycleCallbacks    LifecycleCallbacks    `yaml:"lifecycle_callbacks"`
 	LifecycleHooks        LifecycleHooks        `yaml:"lifecycle_hooks"`
 	Regions               []RegionConfig        `yaml:"regions"`
-	PollingInterval       time.Duration			`yaml:"polling_interval"`
+	PollingInterval       time.Duration         `yaml:"polling_interval"`
+	DependsOn             []string              `yaml:"depends_on"`
+	AmiSelector           *AmiSelector          `yaml:"ami_selector"`
+	Extends               string                `yaml:"extends"`
+}
+
+type RollingUpdate struct {
+	BatchSize string `yaml:"batch_size"`
+}
+
+type Canary struct {
+	Stages   []int64        `yaml:"stages"`
+	BakeTime time.Duration  `yaml:"bake_time"`
+	Alarms   []string       `yaml:"alarms"`
+	Metrics  []CanaryMetric `yaml:"metrics"`
+}
+
+// CanaryMetric is the metric of canary autoscaling group to analyze
+// Analysis fails if any datapoint meets the comparison with threshold.
+type CanaryMetric struct {
+	Name       string  `yaml:"name"`
+	Namespace  string  `yaml:"namespace"`
+	Metric     string  `yaml:"metric"`
+	Statistic  string  `yaml:"statistic"`
+	Comparison string  `yaml:"comparison"`
+	Threshold  float64 `yaml:"threshold"`
+	Period     int64   `yaml:"period"`
+}
+
+// TrafficShifting shifts traffic from the previous target group to the new one in steps
+type TrafficShifting struct {
+	Enabled  bool          `yaml:"enabled"`
+	Steps    []int64       `yaml:"steps"`
+	Interval time.Duration `yaml:"interval"`
 }
 
 type LifecycleHooks struct {
@@ -167,18 +258,21 @@ type LifecycleCallbacks struct {
 }
 
 type RegionConfig struct {
-	Region                 string   `yaml:"region"`
-	UsePublicSubnets       bool     `yaml:"use_public_subnets"`
-	InstanceType           string   `yaml:"instance_type"`
-	SshKey                 string   `yaml:"ssh_key"`
-	AmiId                  string   `yaml:"ami_id"`
-	VPC                    string   `yaml:"vpc"`
-	SecurityGroups         []string `yaml:"security_groups"`
-	HealthcheckLB          string   `yaml:"healthcheck_load_balancer"`
-	HealthcheckTargetGroup string   `yaml:"healthcheck_target_group"`
-	TargetGroups           []string `yaml:"target_groups"`
-	LoadBalancers          []string `yaml:"loadbalancers"`
-	AvailabilityZones      []string `yaml:"availability_zones"`
+	Region                 string       `yaml:"region"`
+	UsePublicSubnets       bool         `yaml:"use_public_subnets"`
+	InstanceType           string       `yaml:"instance_type"`
+	SshKey                 string       `yaml:"ssh_key"`
+	AmiId                  string       `yaml:"ami_id"`
+	AmiSelector            *AmiSelector `yaml:"ami_selector"`
+	VPC                    string       `yaml:"vpc"`
+	SecurityGroups         []string     `yaml:"security_groups"`
+	HealthcheckLB          string       `yaml:"healthcheck_load_balancer"`
+	HealthcheckTargetGroup string       `yaml:"healthcheck_target_group"`
+	TargetGroups           []string     `yaml:"target_groups"`
+	LoadBalancers          []string     `yaml:"loadbalancers"`
+	AvailabilityZones      []string     `yaml:"availability_zones"`
+	ListenerRule           string       `yaml:"listener_rule"`
+	WeightedTargetGroups   []string     `yaml:"weighted_target_groups"`
 }
 
 type Capacity struct {
@@ -187,32 +281,116 @@ type Capacity struct {
 	Desired int64 `yaml:"desired"`
 }
 
-func (l LocalProvider) Provide() string {
+// GetBatchSize returns the number of instances to replace at once
+// batch_size could be either the count of instances(ex. 2) or the percentage of desired capacity(ex. 25%)
+func (r RollingUpdate) GetBatchSize(desired int64) (int64, error) {
+	if len(r.BatchSize) == 0 {
+		return DEFAULT_ROLLING_BATCH_SIZE, nil
+	}
+
+	var size int64
+	if strings.HasSuffix(r.BatchSize, "%") {
+		percent, err := strconv.ParseInt(strings.TrimSuffix(r.BatchSize, "%"), 10, 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, fmt.Errorf("batch_size percentage should be between 1%% and 100%% : %s", r.BatchSize)
+		}
+		size = (desired*percent + 99) / 100
+	} else {
+		count, err := strconv.ParseInt(r.BatchSize, 10, 64)
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("batch_size should be a positive number or percentage : %s", r.BatchSize)
+		}
+		size = count
+	}
+
+	if size < DEFAULT_ROLLING_BATCH_SIZE {
+		size = DEFAULT_ROLLING_BATCH_SIZE
+	}
+
+	return size, nil
+}
+
+// GetStages returns percentages of capacity for each canary stage
+func (c Canary) GetStages() []int64 {
+	if len(c.Stages) == 0 {
+		return DEFAULT_CANARY_STAGES
+	}
+	return c.Stages
+}
+
+// GetBakeTime returns the time to hold each canary stage for analysis
+func (c Canary) GetBakeTime() time.Duration {
+	if c.BakeTime == 0 {
+		return DEFAULT_CANARY_BAKE_TIME
+	}
+	return c.BakeTime
+}
+
+// GetStageCapacity returns the capacity of the canary stage based on the target capacity
+func (c Canary) GetStageCapacity(stage int, target Capacity) Capacity {
+	percent := c.GetStages()[stage]
+	if percent >= 100 {
+		return target
+	}
+
+	desired := (target.Desired*percent + 99) / 100
+	if desired < 1 {
+		desired = 1
+	}
+
+	min := target.Min
+	if min > desired {
+		min = desired
+	}
+
+	max := target.Max
+	if max < desired {
+		max = desired
+	}
+
+	return Capacity{Min: min, Max: max, Desired: desired}
+}
+
+// GetSteps returns percentages of traffic to the new target group for each step
+func (t TrafficShifting) GetSteps() []int64 {
+	if len(t.Steps) == 0 {
+		return DEFAULT_TRAFFIC_SHIFTING_STEPS
+	}
+	return t.Steps
+}
+
+// GetInterval returns the time to wait between steps
+func (t TrafficShifting) GetInterval() time.Duration {
+	if t.Interval == 0 {
+		return DEFAULT_TRAFFIC_SHIFTING_INTERVAL
+	}
+	return t.Interval
+}
+
+func (l LocalProvider) Provide(vars UserdataVars) (string, error) {
 	if l.Path == "" {
-		tool.ErrorLogging("Please specify userdata script path")
+		return "", fmt.Errorf("please specify userdata script path")
 	}
 	if !tool.FileExists(l.Path) {
-		tool.ErrorLogging(fmt.Sprintf("File does not exist in %s", l.Path))
+		return "", fmt.Errorf("file does not exist in %s", l.Path)
 	}
 
 	userdata, err := ioutil.ReadFile(l.Path)
 	if err != nil {
-		tool.ErrorLogging("Error reading userdata file")
+		return "", fmt.Errorf("error reading userdata file : %s", err.Error())
 	}
 
-	return base64.StdEncoding.EncodeToString(userdata)
+	return renderUserdata(l.Path, userdata, l.Template, l.Vars, vars)
 }
 
-func (s S3Provider) Provide() string {
-	return ""
+// Provide returns the userdata as it is because it was already rendered
+func (e EncodedProvider) Provide(vars UserdataVars) (string, error) {
+	return e.Userdata, nil
 }
 
-func NewBuilder() (Builder, error) {
+func NewBuilder(config Config) (Builder, error) {
 	builder := Builder{}
 
-	// Parsing Argument
-	config := argumentParsing()
-
 	//Check manifest file
 	if len(config.Manifest) == 0 || !tool.FileExists(config.Manifest) {
 		return builder, fmt.Errorf(NO_MANIFEST_EXISTS)
@@ -221,15 +399,19 @@ func NewBuilder() (Builder, error) {
 	// Set config
 	builder.Config = config
 
-	return builder.SetStacks(), nil
+	return builder.SetStacks()
 }
 
 // SetStacks set stack information
-func (b Builder) SetStacks() Builder {
+func (b Builder) SetStacks() (Builder, error) {
 
-	awsConfig, Stacks := parsingManifestFile(b.Config.Manifest)
+	awsConfig, Stacks, sources, err := parsingManifestFile(b.Config.Manifest, b.Config.ValuesFiles())
+	if err != nil {
+		return b, err
+	}
 
 	b.AwsConfig = awsConfig
+	b.Sources = sources
 
 	if len(b.Config.AssumeRole) > 0 {
 		for i, _ := range Stacks {
@@ -239,11 +421,14 @@ func (b Builder) SetStacks() Builder {
 
 	b.Stacks = Stacks
 
+	// Default values are from the first stack if many stacks are deployed
 	var deployStack Stack
-	for _, stack := range Stacks {
-		if b.Config.Stack == stack.Stack {
-			deployStack = stack
-			break
+	if names := b.Config.StackNames(); len(names) > 0 {
+		for _, stack := range Stacks {
+			if names[0] == stack.Stack {
+				deployStack = stack
+				break
+			}
 		}
 	}
 
@@ -259,7 +444,7 @@ func (b Builder) SetStacks() Builder {
 		}
 	}
 
-	return b
+	return b, nil
 }
 
 // Validation Check
@@ -268,14 +453,28 @@ func (b Builder) CheckValidation() error {
 	target_region := b.Config.Region
 
 	// Check stack
-	if len(b.Config.Stack) == 0 {
+	if len(b.Config.StackNames()) == 0 {
 		return fmt.Errorf("you should choose at least one stack.")
 	}
 
+	if _, err := b.GetTargetStacks(); err != nil {
+		return err
+	}
+
+	if err := checkDependencies(b.Stacks); err != nil {
+		return err
+	}
+
 	// Global AMI check
-	if len(target_region) == 0 && len(target_ami) != 0 && strings.HasPrefix(target_ami, "ami-") {
-		// One ami id cannot be used in different regions
-		return fmt.Errorf("one ami id cannot be used in different regions : %s", target_ami)
+	if len(target_region) == 0 && len(target_ami) != 0 && IsAmiId(target_ami) {
+		// One ami id cannot be used in different regions, but the selector is resolved in each region
+		return fmt.Errorf("one ami id cannot be used in different regions, use ami selector instead : %s", target_ami)
+	}
+
+	if len(target_ami) != 0 && !IsAmiId(target_ami) {
+		if _, err := ParseAmiSelector(target_ami); err != nil {
+			return err
+		}
 	}
 
 	// check metric configuration file if metric feature is enabled
@@ -288,152 +487,282 @@ func (b Builder) CheckValidation() error {
 		return fmt.Errorf("you cannot specify the release-notes and release-notes-base64 at the same time")
 	}
 
+	// check action on interrupt
+	if len(b.Config.OnInterrupt) > 0 && !tool.IsStringInArray(b.Config.OnInterrupt, availableInterruptActions) {
+		return fmt.Errorf("not available action on interrupt : %s", b.Config.OnInterrupt)
+	}
+
+	// check deployment across regions
+	if b.Config.RegionConcurrency < 0 {
+		return fmt.Errorf("region concurrency cannot be negative : %d", b.Config.RegionConcurrency)
+	}
+
+	if len(b.Config.RegionFailurePolicy) > 0 && !tool.IsStringInArray(b.Config.RegionFailurePolicy, availableRegionFailurePolicies) {
+		return fmt.Errorf("not available region failure policy : %s", b.Config.RegionFailurePolicy)
+	}
+
+	if len(b.Config.Output) > 0 && !tool.IsStringInArray(b.Config.Output, availableOutputFormats) {
+		return fmt.Errorf("not available output format : %s, available formats: %s", b.Config.Output, strings.Join(availableOutputFormats, ", "))
+	}
+
+	if _, _, err := b.Config.HistoryRange(); err != nil {
+		return err
+	}
+
 	// check validations in each stack
 	for _, stack := range b.Stacks {
-		if stack.Stack != b.Config.Stack {
+		if !tool.IsStringInArray(stack.Stack, b.Config.StackNames()) {
 			continue
 		}
 
-		// Check AMI
-		// Check Autoscaling and Alarm setting
-		if len(stack.Autoscaling) != 0 && len(stack.Alarms) != 0 {
-			policies := []string{}
-			for _, scaling := range stack.Autoscaling {
-				if len(scaling.Name) == 0 {
-					return fmt.Errorf("autoscaling policy doesn't have a name.")
-				}
-				policies = append(policies, scaling.Name)
+		if err := b.checkStack(stack); err != nil {
+			return b.Sources.StackError(stack.Stack, err)
+		}
+	}
+
+	if b.MetricConfig.Enabled {
+		if len(b.MetricConfig.Region) <= 0 {
+			return fmt.Errorf("you do not specify the region for metrics")
+		}
+
+		if len(b.MetricConfig.Storage.Name) <= 0 {
+			return fmt.Errorf("you do not specify the name of storage for metrics")
+		}
+	}
+
+	if b.Config.PollingInterval < MIN_POLLING_INTERVAL {
+		return fmt.Errorf("polling interval cannot be smaller than %.0f sec", MIN_POLLING_INTERVAL.Seconds())
+	}
+	if b.Config.PollingInterval >= b.Config.Timeout {
+		return fmt.Errorf("polling interval should be lower than %.0f min", b.Config.Timeout.Minutes())
+	}
+
+	return nil
+}
+
+// checkStack checks configurations of the stack
+func (b Builder) checkStack(stack Stack) error {
+	// Check values of enum fields
+	if err := checkEnums(reflect.ValueOf(stack), ""); err != nil {
+		return err
+	}
+
+	// Check userdata template and userdata in S3
+	if err := b.checkUserdata(stack); err != nil {
+		return err
+	}
+
+	// Check replacement type
+	if len(stack.ReplacementType) > 0 && !tool.IsStringInArray(stack.ReplacementType, availableReplacementTypes) {
+		return fmt.Errorf("not available replacement type : %s", stack.ReplacementType)
+	}
+
+	if stack.ReplacementType == REPLACEMENT_TYPE_ROLLING {
+		if _, err := stack.RollingUpdate.GetBatchSize(stack.Capacity.Desired); err != nil {
+			return err
+		}
+	}
+
+	if stack.ReplacementType == REPLACEMENT_TYPE_CANARY {
+		if err := checkCanaryValidation(stack.Canary, b.Config.Timeout); err != nil {
+			return err
+		}
+	}
+
+	if stack.TrafficShifting.Enabled {
+		if err := checkTrafficShiftingValidation(stack); err != nil {
+			return err
+		}
+	}
+
+	// Check AMI
+	// Check Autoscaling and Alarm setting
+	if len(stack.Autoscaling) != 0 && len(stack.Alarms) != 0 {
+		policies := []string{}
+		for _, scaling := range stack.Autoscaling {
+			if len(scaling.Name) == 0 {
+				return fmt.Errorf("autoscaling policy doesn't have a name.")
 			}
-			for _, alarm := range stack.Alarms {
-				for _, action := range alarm.AlarmActions {
-					if !tool.IsStringInArray(action, policies) {
-						return fmt.Errorf("no scaling action exists : %s", action)
-					}
+			policies = append(policies, scaling.Name)
+		}
+		for _, alarm := range stack.Alarms {
+			for _, action := range alarm.AlarmActions {
+				if !tool.IsStringInArray(action, policies) {
+					return fmt.Errorf("no scaling action exists : %s", action)
 				}
 			}
 		}
+	}
+
+	// Check Spot Options
+	if len(stack.InstanceMarketOptions.MarketType) != 0 {
+		if stack.InstanceMarketOptions.MarketType != "spot" {
+			return fmt.Errorf("no valid market type : %s", stack.InstanceMarketOptions.MarketType)
+		}
+
+		if stack.InstanceMarketOptions.SpotOptions.BlockDurationMinutes%60 != 0 || stack.InstanceMarketOptions.SpotOptions.BlockDurationMinutes > 360 {
+			return fmt.Errorf("block_duration_minutes should be one of [ 60, 120, 180, 240, 300, 360 ]")
+		}
 
-		// Check Spot Options
-		if len(stack.InstanceMarketOptions.MarketType) != 0 {
-			if stack.InstanceMarketOptions.MarketType != "spot" {
-				return fmt.Errorf("no valid market type : %s", stack.InstanceMarketOptions.MarketType)
+		if stack.InstanceMarketOptions.SpotOptions.SpotInstanceType == "persistent" && stack.InstanceMarketOptions.SpotOptions.InstanceInterruptionBehavior == "terminate" {
+			return fmt.Errorf("persistent type is not allowed with termiante behavior.")
+		}
+	}
+
+	// Check block device setting
+	if len(stack.BlockDevices) > 0 {
+		dNames := []string{}
+		for _, block := range stack.BlockDevices {
+			if len(block.DeviceName) == 0 {
+				return fmt.Errorf("name of device is required.")
 			}
 
-			if stack.InstanceMarketOptions.SpotOptions.BlockDurationMinutes%60 != 0 || stack.InstanceMarketOptions.SpotOptions.BlockDurationMinutes > 360 {
-				return fmt.Errorf("block_duration_minutes should be one of [ 60, 120, 180, 240, 300, 360 ]")
+			if !tool.IsStringInArray(block.VolumeType, availableBlockTypes) {
+				return fmt.Errorf("not available volume type : %s", block.VolumeType)
 			}
 
-			if stack.InstanceMarketOptions.SpotOptions.SpotInstanceType == "persistent" && stack.InstanceMarketOptions.SpotOptions.InstanceInterruptionBehavior == "terminate" {
-				return fmt.Errorf("persistent type is not allowed with termiante behavior.")
+			if block.VolumeType == "st1" && block.VolumeSize < 500 {
+				return fmt.Errorf("volume size of st1 type should be larger than 500GiB")
 			}
-		}
 
-		// Check block device setting
-		if len(stack.BlockDevices) > 0 {
-			dNames := []string{}
-			for _, block := range stack.BlockDevices {
-				if len(block.DeviceName) == 0 {
-					return fmt.Errorf("name of device is required.")
-				}
+			if tool.IsStringInArray(block.DeviceName, dNames) {
+				return fmt.Errorf("device names are duplicated : %s", block.DeviceName)
+			} else {
+				dNames = append(dNames, block.DeviceName)
+			}
+		}
+	}
 
-				if !tool.IsStringInArray(block.VolumeType, availableBlockTypes) {
-					return fmt.Errorf("not available volume type : %s", block.VolumeType)
+	if &stack.LifecycleHooks != nil {
+		if len(stack.LifecycleHooks.LaunchTransition) > 0 {
+			for _, l := range stack.LifecycleHooks.LaunchTransition {
+				if len(l.NotificationTargetARN) > 0 && len(l.RoleARN) == 0 {
+					return fmt.Errorf("role_arn is needed if `notification_target_arn` is not empty : %s", l.LifecycleHookName)
 				}
 
-				if block.VolumeType == "st1" && block.VolumeSize < 500 {
-					return fmt.Errorf("volume size of st1 type should be larger than 500GiB")
+				if len(l.RoleARN) > 0 && len(l.NotificationTargetARN) == 0 {
+					return fmt.Errorf("notification_target_arn is needed if `role_arn` is not empty  : %s", l.LifecycleHookName)
 				}
 
-				if tool.IsStringInArray(block.DeviceName, dNames) {
-					return fmt.Errorf("device names are duplicated : %s", block.DeviceName)
-				} else {
-					dNames = append(dNames, block.DeviceName)
+				if l.HeartbeatTimeout == 0 {
+					Logger.Warnf("you didn't specify the heartbeat timeout. you might have to wait too long time.")
 				}
 			}
 		}
 
-		if &stack.LifecycleHooks != nil {
-			if len(stack.LifecycleHooks.LaunchTransition) > 0 {
-				for _, l := range stack.LifecycleHooks.LaunchTransition {
-					if len(l.NotificationTargetARN) > 0 && len(l.RoleARN) == 0 {
-						return fmt.Errorf("role_arn is needed if `notification_target_arn` is not empty : %s", l.LifecycleHookName)
-					}
+		if len(stack.LifecycleHooks.TerminateTransition) > 0 {
+			for _, l := range stack.LifecycleHooks.TerminateTransition {
+				if len(l.NotificationTargetARN) > 0 && len(l.RoleARN) == 0 {
+					return fmt.Errorf("role_arn is needed if `notification_target_arn` is not empty : %s", l.LifecycleHookName)
+				}
 
-					if len(l.RoleARN) > 0 && len(l.NotificationTargetARN) == 0 {
-						return fmt.Errorf("notification_target_arn is needed if `role_arn` is not empty  : %s", l.LifecycleHookName)
-					}
+				if len(l.RoleARN) > 0 && len(l.NotificationTargetARN) == 0 {
+					return fmt.Errorf("notification_target_arn is needed if `role_arn` is not empty  : %s", l.LifecycleHookName)
+				}
 
-					if l.HeartbeatTimeout == 0 {
-						Logger.Warnf("you didn't specify the heartbeat timeout. you might have to wait too long time.")
-					}
+				if l.HeartbeatTimeout == 0 {
+					Logger.Warnf("you didn't specify the heartbeat timeout. you might have to wait too long time.")
 				}
 			}
+		}
+	}
+
+	//Check ami id
+	if err := checkAmi(b.Config, stack); err != nil {
+		return err
+	}
 
-			if len(stack.LifecycleHooks.TerminateTransition) > 0 {
-				for _, l := range stack.LifecycleHooks.TerminateTransition {
-					if len(l.NotificationTargetARN) > 0 && len(l.RoleARN) == 0 {
-						return fmt.Errorf("role_arn is needed if `notification_target_arn` is not empty : %s", l.LifecycleHookName)
-					}
+	for _, region := range stack.Regions {
+		//Check instance type
+		if len(region.InstanceType) == 0 {
+			return fmt.Errorf("you have to specify the instance type.")
+		}
+	}
 
-					if len(l.RoleARN) > 0 && len(l.NotificationTargetARN) == 0 {
-						return fmt.Errorf("notification_target_arn is needed if `role_arn` is not empty  : %s", l.LifecycleHookName)
-					}
+	// check mixed instances policy
+	if stack.MixedInstancesPolicy.Enabled {
+		if len(stack.MixedInstancesPolicy.SpotAllocationStrategy) == 0 {
+			stack.MixedInstancesPolicy.SpotAllocationStrategy = DFEAULT_SPOT_ALLOCATION_STRATEGY
+		}
 
-					if l.HeartbeatTimeout == 0 {
-						Logger.Warnf("you didn't specify the heartbeat timeout. you might have to wait too long time.")
-					}
-				}
-			}
+		if stack.MixedInstancesPolicy.SpotAllocationStrategy != "lowest-price" && stack.MixedInstancesPolicy.SpotInstancePools > 0 {
+			return fmt.Errorf("you can only set spot_instance_pools with lowest-price spot_allocation_strategy")
 		}
 
-		for _, region := range stack.Regions {
-			//Check ami id
-			if len(target_ami) == 0 && len(region.AmiId) == 0 {
-				return fmt.Errorf("you have to specify at least one ami id.")
-			}
+		if len(stack.MixedInstancesPolicy.Override) <= 0 {
+			return fmt.Errorf("you have to set at least one instance type to use in override")
+		}
+	}
 
-			//Check instance type
-			if len(region.InstanceType) == 0 {
-				return fmt.Errorf("you have to specify the instance type.")
-			}
+	return nil
+}
+
+// checkCanaryValidation checks canary stages and analysis settings
+func checkCanaryValidation(canary Canary, timeout time.Duration) error {
+	stages := canary.GetStages()
+	prev := int64(0)
+	for _, stage := range stages {
+		if stage <= prev || stage > 100 {
+			return fmt.Errorf("canary stages should be increasing percentages between 1 and 100 : %v", stages)
 		}
+		prev = stage
+	}
 
-		// check mixed instances policy
-		if stack.MixedInstancesPolicy.Enabled {
-			if len(stack.MixedInstancesPolicy.SpotAllocationStrategy) == 0 {
-				stack.MixedInstancesPolicy.SpotAllocationStrategy = DFEAULT_SPOT_ALLOCATION_STRATEGY
-			}
+	if stages[len(stages)-1] != 100 {
+		return fmt.Errorf("the last canary stage should be 100 : %v", stages)
+	}
 
-			if stack.MixedInstancesPolicy.SpotAllocationStrategy != "lowest-price" && stack.MixedInstancesPolicy.SpotInstancePools > 0 {
-				return fmt.Errorf("you can only set spot_instance_pools with lowest-price spot_allocation_strategy")
-			}
+	if canary.GetBakeTime()*time.Duration(len(stages)) >= timeout {
+		return fmt.Errorf("total bake time of canary stages should be lower than timeout %.0f min", timeout.Minutes())
+	}
 
-			if len(stack.MixedInstancesPolicy.Override) <= 0 {
-				return fmt.Errorf("you have to set at least one instance type to use in override")
-			}
+	for _, m := range canary.Metrics {
+		if len(m.Metric) == 0 || len(m.Namespace) == 0 || len(m.Statistic) == 0 {
+			return fmt.Errorf("namespace, metric and statistic are required in canary metric : %s", m.Name)
+		}
+
+		if !tool.IsStringInArray(m.Comparison, availableComparisonOperators) {
+			return fmt.Errorf("not available comparison operator in canary metric : %s", m.Comparison)
 		}
 	}
 
-	if len(b.MetricConfig.Region) <= 0 {
-		return fmt.Errorf("you do not specify the region for metrics")
+	return nil
+}
+
+// checkTrafficShiftingValidation checks weighted target groups and steps
+func checkTrafficShiftingValidation(stack Stack) error {
+	if len(stack.ReplacementType) > 0 && stack.ReplacementType != REPLACEMENT_TYPE_BLUEGREEN {
+		return fmt.Errorf("traffic shifting is only available with %s replacement type", REPLACEMENT_TYPE_BLUEGREEN)
 	}
 
-	if len(b.MetricConfig.Storage.Name) <= 0 {
-		return fmt.Errorf("you do not specify the name of storage for metrics")
+	steps := stack.TrafficShifting.GetSteps()
+	prev := int64(0)
+	for _, step := range steps {
+		if step <= prev || step > 100 {
+			return fmt.Errorf("traffic shifting steps should be increasing percentages between 1 and 100 : %v", steps)
+		}
+		prev = step
 	}
 
-	if b.Config.PollingInterval < MIN_POLLING_INTERVAL {
-		return fmt.Errorf("polling interval cannot be smaller than %.0f sec", MIN_POLLING_INTERVAL.Seconds())
+	if steps[len(steps)-1] != 100 {
+		return fmt.Errorf("the last traffic shifting step should be 100 : %v", steps)
 	}
-	if b.Config.PollingInterval >= b.Config.Timeout {
-		return fmt.Errorf("polling interval should be lower than %.0f min", b.Config.Timeout.Minutes())
+
+	for _, region := range stack.Regions {
+		if len(region.ListenerRule) == 0 {
+			return fmt.Errorf("listener_rule is required for traffic shifting : %s", region.Region)
+		}
+
+		if len(region.WeightedTargetGroups) != 2 {
+			return fmt.Errorf("weighted_target_groups should have two target groups for blue and green : %s", region.Region)
+		}
 	}
 
 	return nil
 }
 
 // Print Summary
-func (b Builder) MakeSummary(target_stack string) string {
+func (b Builder) MakeSummary(target_stacks []string) string {
 	summary := []string{}
 	formatting := `
 ============================================================
@@ -450,9 +779,11 @@ Stack
 ============================================================`
 	summary = append(summary, fmt.Sprintf(formatting, b.AwsConfig.Name, b.Config.Env, b.Config.Timeout.Minutes(), b.Config.PollingInterval.Seconds(), b.Config.AssumeRole, b.Config.ExtraTags))
 
-	for _, stack := range b.Stacks {
-		if stack.Stack == target_stack {
-			summary = append(summary, printEnvironment(stack))
+	for _, target_stack := range target_stacks {
+		for _, stack := range b.Stacks {
+			if stack.Stack == target_stack {
+				summary = append(summary, printEnvironment(stack))
+			}
 		}
 	}
 
@@ -493,17 +824,18 @@ MixedInstancesPolicy
 }
 
 // Parsing Manifest File
-func parsingManifestFile(manifest string) (AWSConfig, []Stack) {
+// Included files, overlay files of values and extends of stacks are resolved before parsing.
+func parsingManifestFile(manifest string, values []string) (AWSConfig, []Stack, ManifestSources, error) {
 	yamlConfig := YamlConfig{}
-	yamlFile, err := ioutil.ReadFile(manifest)
+	yamlFile, sources, err := readManifest(manifest, values)
 	if err != nil {
-		Logger.Errorf("Error reading YAML file: %s\n", err)
-		return AWSConfig{}, nil
+		return AWSConfig{}, nil, nil, err
 	}
 
+	// Unknown fields are checked in each file, so only types of substituted values can be wrong here.
 	err = yaml.Unmarshal(yamlFile, &yamlConfig)
 	if err != nil {
-		tool.FatalError(err)
+		return AWSConfig{}, nil, nil, mergedError(manifest, err)
 	}
 
 	awsConfig := AWSConfig{
@@ -514,61 +846,18 @@ func parsingManifestFile(manifest string) (AWSConfig, []Stack) {
 
 	Stacks := yamlConfig.Stacks
 
-	return awsConfig, Stacks
-}
-
-// Parsing Config from command
-func argumentParsing() Config {
-	manifest := flag.String("manifest", "", "The manifest configuration file to use.")
-	ami := flag.String("ami", "", "The AMI to use for the servers.")
-	env := flag.String("env", "", "The environment that is being deployed into.")
-	stack := flag.String("stack", "", "An ordered, comma-delimited list of stacks that should be deployed.")
-	assumeRole := flag.String("assume-role", "", "The Role ARN to assume into")
-	timeout := flag.Duration("timeout", DEFAULT_DEPLOYMENT_TIMEOUT, "Time to wait for deploy to finish before timing out (default 60m)")
-	region := flag.String("region", "", "The region to deploy into, if undefined, then the deployment will run against all regions for the given environment.")
-	confirm := flag.Bool("confirm", true, "Suppress confirmation prompt")
-	slackOff := flag.Bool("slack-off", false, "Turn off slack alarm")
-	logLevel := flag.String("log-level", "info", "log level")
-	extraTags := flag.String("extra-tags", "", "Extra tags to add to autoscaling group tags")
-	ansibleExtraVars := flag.String("ansible-extra-vars", "", "Extra variables for ansible")
-	overrideInstanceType := flag.String("override-instance-type", "", "Instance Type to override")
-	disableMetrics := flag.Bool("disable-metrics", false, "Disable gathering metrics")
-	releaseNotes := flag.String("release-notes", "", "Release note for the current deployment")
-	releaseNotesBase64 := flag.String("release-notes-base64", "", "base64 encoded string of release note for the current deployment")
-	forceManifestCapacity := flag.Bool("force-manifest-capacity", false, "Force-apply the capacity of instances in the manifest file")
-	pollingInterval := flag.Duration("polling-interval", 0, "Time to interval for polling health check (default 60s)")
-
-	flag.Parse()
-
-	config := Config{
-		Manifest:              *manifest,
-		Ami:                   *ami,
-		Env:                   *env,
-		Stack:                 *stack,
-		Region:                *region,
-		AssumeRole:            *assumeRole,
-		Timeout:               *timeout,
-		StartTimestamp:        time.Now().Unix(),
-		Confirm:               *confirm,
-		SlackOff:              *slackOff,
-		LogLevel:              *logLevel,
-		ExtraTags:             *extraTags,
-		AnsibleExtraVars:      *ansibleExtraVars,
-		OverrideInstanceType:  *overrideInstanceType,
-		DisableMetrics:        *disableMetrics,
-		ReleaseNotes:          *releaseNotes,
-		ReleaseNotesBase64:    *releaseNotesBase64,
-		ForceManifestCapacity: *forceManifestCapacity,
-		PollingInterval:       *pollingInterval,
-	}
-
-	return config
+	return awsConfig, Stacks, sources, nil
 }
 
 // Set Userdata provider
 func SetUserdataProvider(userdata Userdata, default_userdata Userdata) UserdataProvider {
 
 	//Set default if no userdata exists in the stack
+	// Parts of the manifest are not used if the stack has userdata of its own type
+	if len(userdata.Parts) == 0 && userdata.Type == "" {
+		userdata.Parts = default_userdata.Parts
+	}
+
 	if userdata.Type == "" {
 		userdata.Type = default_userdata.Type
 	}
@@ -577,11 +866,13 @@ func SetUserdataProvider(userdata Userdata, default_userdata Userdata) UserdataP
 		userdata.Path = default_userdata.Path
 	}
 
-	if userdata.Type == "s3" {
-		return S3Provider{Path: userdata.Path}
+	if userdata.Content == "" {
+		userdata.Content = default_userdata.Content
 	}
 
-	return LocalProvider{
-		Path: userdata.Path,
-	}
+	// Variables of the stack override those of the manifest
+	userdata.Vars = mergeUserdataVars(default_userdata.Vars, userdata.Vars)
+	userdata.Template = userdata.Template || default_userdata.Template
+
+	return newUserdataProvider(userdata)
 }
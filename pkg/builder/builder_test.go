@@ -0,0 +1,48 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetBatchSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize string
+		desired   int64
+		want      int64
+		err       string
+	}{
+		{name: "default batch size", desired: 4, want: DEFAULT_ROLLING_BATCH_SIZE},
+		{name: "count of instances", batchSize: "2", desired: 4, want: 2},
+		{name: "count larger than desired", batchSize: "10", desired: 4, want: 10},
+		{name: "percentage of desired", batchSize: "25%", desired: 8, want: 2},
+		{name: "percentage is rounded up", batchSize: "30%", desired: 4, want: 2},
+		{name: "percentage of small fleet is at least one", batchSize: "10%", desired: 0, want: 1},
+		{name: "whole fleet", batchSize: "100%", desired: 3, want: 3},
+		{name: "zero percentage", batchSize: "0%", desired: 4, err: "between 1% and 100%"},
+		{name: "percentage over 100", batchSize: "150%", desired: 4, err: "between 1% and 100%"},
+		{name: "zero count", batchSize: "0", desired: 4, err: "positive number or percentage"},
+		{name: "not a number", batchSize: "half", desired: 4, err: "positive number or percentage"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := RollingUpdate{BatchSize: test.batchSize}.GetBatchSize(test.desired)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("GetBatchSize(%d) error = %v, want %q", test.desired, err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("GetBatchSize(%d) error = %v", test.desired, err)
+			}
+
+			if got != test.want {
+				t.Errorf("GetBatchSize(%d) = %d, want %d", test.desired, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"flag"
+	"time"
+)
+
+// NewConfig returns config with default values
+// Fields which are not bound to the flags of command keep these values.
+func NewConfig() Config {
+	return Config{
+		Timeout:             DEFAULT_DEPLOYMENT_TIMEOUT,
+		StartTimestamp:      time.Now().Unix(),
+		Confirm:             true,
+		LogLevel:            "info",
+		OnInterrupt:         INTERRUPT_ACTION_ROLLBACK,
+		RegionFailurePolicy: REGION_FAILURE_POLICY_STOP,
+	}
+}
+
+// AddStackFlags adds flags to select the manifest and stack
+func AddStackFlags(fs *flag.FlagSet, c *Config) {
+	fs.StringVar(&c.Manifest, "manifest", "", "The manifest configuration file to use.")
+	fs.StringVar(&c.Values, "values", "", "A comma-delimited list of files to overlay on the manifest in order.")
+	fs.StringVar(&c.Env, "env", "", "The environment that is being deployed into.")
+	fs.StringVar(&c.Stack, "stack", "", "An ordered, comma-delimited list of stacks that should be deployed.")
+	fs.StringVar(&c.AssumeRole, "assume-role", "", "The Role ARN to assume into")
+	fs.StringVar(&c.Region, "region", "", "The region to deploy into, if undefined, then the deployment will run against all regions for the given environment.")
+	fs.StringVar(&c.LogLevel, "log-level", "info", "log level")
+}
+
+// AddExecutionFlags adds flags to control the process which changes resources
+func AddExecutionFlags(fs *flag.FlagSet, c *Config) {
+	fs.DurationVar(&c.Timeout, "timeout", DEFAULT_DEPLOYMENT_TIMEOUT, "Time to wait for deploy to finish before timing out")
+	fs.BoolVar(&c.Confirm, "confirm", true, "Suppress confirmation prompt")
+	fs.BoolVar(&c.SlackOff, "slack-off", false, "Turn off slack alarm")
+	fs.BoolVar(&c.DisableMetrics, "disable-metrics", false, "Disable gathering metrics")
+	fs.DurationVar(&c.PollingInterval, "polling-interval", 0, "Time to interval for polling health check (default 60s)")
+	fs.DurationVar(&c.WaitForLock, "wait-for-lock", 0, "Time to wait for the deployment lock held by other process (default fail at once)")
+}
+
+// AddDeploymentFlags adds flags which are applied to the new version
+func AddDeploymentFlags(fs *flag.FlagSet, c *Config) {
+	fs.StringVar(&c.Ami, "ami", "", "The AMI ID or selector to use for the servers.")
+	fs.StringVar(&c.ExtraTags, "extra-tags", "", "Extra tags to add to autoscaling group tags")
+	fs.StringVar(&c.AnsibleExtraVars, "ansible-extra-vars", "", "Extra variables for ansible")
+	fs.StringVar(&c.OverrideInstanceType, "override-instance-type", "", "Instance Type to override")
+	fs.StringVar(&c.ReleaseNotes, "release-notes", "", "Release note for the current deployment")
+	fs.StringVar(&c.ReleaseNotesBase64, "release-notes-base64", "", "base64 encoded string of release note for the current deployment")
+	fs.BoolVar(&c.ForceManifestCapacity, "force-manifest-capacity", false, "Force-apply the capacity of instances in the manifest file")
+}
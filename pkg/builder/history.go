@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	HISTORY_DATE_FORMAT = "2006-01-02"
+)
+
+// HistoryRange returns the range of start time of deployments which history shows
+// Dates without time are in KST like timestamps of the metrics table, and until includes the whole day.
+// Zero time means the range is open on that side.
+func (c Config) HistoryRange() (time.Time, time.Time, error) {
+	since, err := parseHistoryTime(c.Since, false)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("wrong format of since : %s", err.Error())
+	}
+
+	until, err := parseHistoryTime(c.Until, true)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("wrong format of until : %s", err.Error())
+	}
+
+	if !since.IsZero() && !until.IsZero() && since.After(until) {
+		return time.Time{}, time.Time{}, fmt.Errorf("since is later than until : %s > %s", c.Since, c.Until)
+	}
+
+	return since, until, nil
+}
+
+// parseHistoryTime parses a date like 2020-07-01 or RFC3339 timestamp
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(HISTORY_DATE_FORMAT, value, KstLocation())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s should be %s or RFC3339", value, HISTORY_DATE_FORMAT)
+	}
+
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+
+	return t, nil
+}
+
+// KstLocation returns the time zone of timestamps in the metrics table
+// Fixed offset is used if time zone database is not installed.
+func KstLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Seoul")
+	if err != nil {
+		return time.FixedZone("KST", 9*60*60)
+	}
+
+	return loc
+}
@@ -0,0 +1,86 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		endOfDay bool
+		want     string
+		err      string
+	}{
+		{name: "empty value is open range", value: ""},
+		{name: "date is the start of day in KST", value: "2020-07-01", want: "2020-06-30T15:00:00Z"},
+		{name: "until includes the whole day in KST", value: "2020-07-01", endOfDay: true, want: "2020-07-01T14:59:59Z"},
+		{name: "RFC3339 is used as it is", value: "2020-07-01T10:00:00+09:00", endOfDay: true, want: "2020-07-01T01:00:00Z"},
+		{name: "wrong format", value: "07/01/2020", err: "should be 2006-01-02 or RFC3339"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseHistoryTime(test.value, test.endOfDay)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("parseHistoryTime(%q) error = %v, want %q", test.value, err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseHistoryTime(%q) error = %v", test.value, err)
+			}
+
+			if len(test.want) == 0 {
+				if !got.IsZero() {
+					t.Errorf("parseHistoryTime(%q) = %s, want zero time", test.value, got)
+				}
+				return
+			}
+
+			if utc := got.UTC().Format(time.RFC3339); utc != test.want {
+				t.Errorf("parseHistoryTime(%q) = %s, want %s", test.value, utc, test.want)
+			}
+		})
+	}
+}
+
+func TestHistoryRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		since string
+		until string
+		err   string
+	}{
+		{name: "open range"},
+		{name: "same day", since: "2020-07-01", until: "2020-07-01"},
+		{name: "since only", since: "2020-07-01"},
+		{name: "since is later than until", since: "2020-07-02", until: "2020-07-01", err: "since is later than until"},
+		{name: "wrong since", since: "yesterday", err: "wrong format of since"},
+		{name: "wrong until", until: "today", err: "wrong format of until"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			since, until, err := Config{Since: test.since, Until: test.until}.HistoryRange()
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("HistoryRange() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("HistoryRange() error = %v", err)
+			}
+
+			if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+				t.Errorf("HistoryRange() = %s, %s, want since before until", since, until)
+			}
+		})
+	}
+}
@@ -0,0 +1,549 @@
+package builder
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	MANIFEST_MERGE_KEYS = []string{"stack", "region", "name"}
+
+	// Variables are not substituted in scripts so that ${VAR} of shell is kept as it is
+	VARIABLE_EXCLUDED_FIELDS = []string{"variables", "content", "lifecycle_callbacks"}
+
+	// ${VAR} or ${VAR:-default}, and $${VAR} is written as it is
+	variablePattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+)
+
+// ValuesFiles returns overlay files passed from command line in order
+func (c Config) ValuesFiles() []string {
+	files := []string{}
+	for _, file := range strings.Split(c.Values, ",") {
+		file = strings.TrimSpace(file)
+		if len(file) > 0 {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// ManifestSources keeps files from which fields of each stack come
+// e.g. sources["artp"]["regions"] = "configs/base.yaml"
+type ManifestSources map[string]map[string]string
+
+// set records the file of the field in the stack
+func (s ManifestSources) set(stack, field, file string) {
+	if _, ok := s[stack]; !ok {
+		s[stack] = map[string]string{}
+	}
+	s[stack][field] = file
+}
+
+// StackError adds files from which fields of the stack come to the error
+// Nothing is added if every field comes from the same file.
+func (s ManifestSources) StackError(stack string, err error) error {
+	fields := s[stack]
+
+	files := []string{}
+	for _, file := range fields {
+		if !tool.IsStringInArray(file, files) {
+			files = append(files, file)
+		}
+	}
+
+	if len(files) <= 1 {
+		return err
+	}
+
+	keys := []string{}
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s : %s", k, fields[k]))
+	}
+
+	return fmt.Errorf("%s\nfields of stack %s come from\n  %s", err.Error(), stack, strings.Join(lines, "\n  "))
+}
+
+// readManifest returns the manifest in which includes, overlay files and extends of stacks are resolved
+// Included files are merged before the file which includes them, and overlay files are merged last.
+// Variables of later files override those of earlier files, and environment variables are used
+// only if the variable is not defined in any file. Variables are substituted in values after parsing
+// so that comments and scripts are not changed and values cannot add fields.
+func readManifest(manifest string, values []string) ([]byte, ManifestSources, error) {
+	files, err := expandIncludes(manifest, []string{}, []string{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, value := range values {
+		if files, err = expandIncludes(value, []string{}, files); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	raws := [][]byte{}
+	for _, file := range files {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading YAML file : %s", err.Error())
+		}
+		raws = append(raws, raw)
+	}
+
+	variables := map[string]string{}
+	for i, raw := range raws {
+		if err := collectVariables(files[i], raw, variables); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sources := ManifestSources{}
+	var merged interface{}
+	for i, raw := range raws {
+		// Each file is parsed alone so that the error shows the line of the file
+		// Unknown fields are not allowed so that typos are not ignored silently.
+		// Types of values with variables are checked after substitution.
+		if err := ignoreVariableErrors(yaml.UnmarshalStrict(raw, &YamlConfig{})); err != nil {
+			return nil, nil, strictError(files[i], raw, err)
+		}
+
+		var doc interface{}
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return nil, nil, fmt.Errorf("error parsing YAML file %s : %s", files[i], err.Error())
+		}
+
+		doc, err = substituteDocument(files[i], doc, variables)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, stack := range stackItems(doc) {
+			for field := range stack {
+				sources.set(stack["stack"].(string), fmt.Sprint(field), files[i])
+			}
+		}
+
+		merged = mergeValues(merged, doc)
+	}
+
+	if err := resolveExtends(merged, sources); err != nil {
+		return nil, nil, err
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, sources, nil
+}
+
+// expandIncludes returns files in the order of merge
+// Files included by the file come first, and a file which is already in the list is not merged again.
+func expandIncludes(file string, chain []string, files []string) ([]string, error) {
+	for i, f := range chain {
+		if f == file {
+			return nil, fmt.Errorf("circular include exists : %s", strings.Join(append(chain[i:], file), " -> "))
+		}
+	}
+
+	if tool.IsStringInArray(file, files) {
+		return files, nil
+	}
+
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading YAML file : %s", err.Error())
+	}
+
+	doc := struct {
+		Include []string `yaml:"include"`
+	}{}
+
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing include of %s : %s", file, err.Error())
+	}
+
+	// Path of included file is relative to the file which includes it
+	chain = append(append([]string{}, chain...), file)
+	for _, include := range doc.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(file), include)
+		}
+
+		if files, err = expandIncludes(include, chain, files); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(files, file), nil
+}
+
+// stackItems returns stacks of the manifest which have the name
+func stackItems(doc interface{}) []map[interface{}]interface{} {
+	m, ok := doc.(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+
+	items, ok := m["stacks"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	stacks := []map[interface{}]interface{}{}
+	for _, item := range items {
+		stack, ok := item.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, ok := stack["stack"].(string); ok {
+			stacks = append(stacks, stack)
+		}
+	}
+
+	return stacks
+}
+
+// resolveExtends makes stacks inherit fields of the stack which they extend
+// Fields of the stack override those of the base. Maps like capacity are merged,
+// and lists like regions are replaced as a whole.
+func resolveExtends(doc interface{}, sources ManifestSources) error {
+	stacks := map[string]map[interface{}]interface{}{}
+	for _, stack := range stackItems(doc) {
+		stacks[stack["stack"].(string)] = stack
+	}
+
+	resolved := []string{}
+	var resolve func(name string, chain []string) error
+	resolve = func(name string, chain []string) error {
+		if tool.IsStringInArray(name, resolved) {
+			return nil
+		}
+
+		for i, c := range chain {
+			if c == name {
+				return fmt.Errorf("circular extends exists : %s", strings.Join(append(chain[i:], name), " -> "))
+			}
+		}
+
+		stack := stacks[name]
+		base, ok := stack["extends"].(string)
+		if !ok || len(base) == 0 {
+			resolved = append(resolved, name)
+			return nil
+		}
+
+		if _, ok := stacks[base]; !ok {
+			return fmt.Errorf("%s extends the stack which does not exist : %s", name, base)
+		}
+
+		if err := resolve(base, append(chain, name)); err != nil {
+			return err
+		}
+
+		for field, value := range stacks[base] {
+			if _, ok := stack[field]; ok {
+				stack[field] = extendValues(value, stack[field])
+				continue
+			}
+
+			stack[field] = copyValue(value)
+			if file, ok := sources[base][fmt.Sprint(field)]; ok {
+				sources.set(name, fmt.Sprint(field), fmt.Sprintf("%s (extends %s)", file, base))
+			}
+		}
+		stack["stack"] = name
+		stack["extends"] = base
+		resolved = append(resolved, name)
+
+		return nil
+	}
+
+	for _, stack := range stackItems(doc) {
+		if err := resolve(stack["stack"].(string), []string{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extendValues merges maps of the base and the stack recursively
+// Other values of the stack replace those of the base.
+func extendValues(base, value interface{}) interface{} {
+	b, ok := base.(map[interface{}]interface{})
+	if !ok {
+		return value
+	}
+
+	v, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return value
+	}
+
+	merged := copyValue(b).(map[interface{}]interface{})
+	for k, item := range v {
+		merged[k] = extendValues(merged[k], item)
+	}
+
+	return merged
+}
+
+// copyValue deep-copies the value so that stacks extending the same base do not share it
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[interface{}]interface{}{}
+		for k, item := range v {
+			m[k] = copyValue(item)
+		}
+		return m
+	case []interface{}:
+		l := []interface{}{}
+		for _, item := range v {
+			l = append(l, copyValue(item))
+		}
+		return l
+	}
+
+	return value
+}
+
+// collectVariables reads variables section of the file
+// Values of variables can refer to environment variables.
+func collectVariables(file string, raw []byte, variables map[string]string) error {
+	doc := struct {
+		Variables map[string]string `yaml:"variables"`
+	}{}
+
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("error parsing variables of %s : %s", file, err.Error())
+	}
+
+	for name, value := range doc.Variables {
+		undefined := []string{}
+		expanded := substituteString(value, map[string]string{}, &undefined)
+		if err := undefinedError(file, undefined); err != nil {
+			return err
+		}
+		variables[name] = expanded
+	}
+
+	return nil
+}
+
+// substituteDocument replaces ${VAR} in values of the parsed file with the variable or environment variable
+// It fails if a variable is not defined and has no default value.
+func substituteDocument(file string, doc interface{}, variables map[string]string) (interface{}, error) {
+	undefined := []string{}
+	substituted := substituteValue(doc, variables, &undefined)
+
+	if err := undefinedError(file, undefined); err != nil {
+		return nil, err
+	}
+
+	return substituted, nil
+}
+
+// substituteValue substitutes strings in the value recursively
+// Fields of VARIABLE_EXCLUDED_FIELDS are kept as they are.
+func substituteValue(value interface{}, variables map[string]string, undefined *[]string) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for k, item := range v {
+			if key, ok := k.(string); ok && tool.IsStringInArray(key, VARIABLE_EXCLUDED_FIELDS) {
+				continue
+			}
+			v[k] = substituteValue(item, variables, undefined)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = substituteValue(item, variables, undefined)
+		}
+		return v
+	case string:
+		substituted := substituteString(v, variables, undefined)
+
+		// A value which is only a variable like min: ${capacity} has the type of the substituted value
+		if loc := variablePattern.FindStringIndex(v); loc != nil && loc[0] == 0 && loc[1] == len(v) && v != "$${" {
+			return scalarValue(substituted)
+		}
+		return substituted
+	}
+
+	return value
+}
+
+// scalarValue returns the value parsed as YAML scalar like 3 or true
+// Values which are not scalars or change when they are written again like 1.10 are kept as strings.
+func scalarValue(value string) interface{} {
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return value
+	}
+
+	switch parsed.(type) {
+	case int, int64, uint64, float64, bool:
+		out, err := yaml.Marshal(parsed)
+		if err == nil && strings.TrimSpace(string(out)) == value {
+			return parsed
+		}
+	}
+
+	return value
+}
+
+// substituteString replaces ${VAR} with the variable or environment variable, and $${ with ${
+// Names of variables which are not defined and have no default value are added to undefined.
+func substituteString(value string, variables map[string]string, undefined *[]string) string {
+	return variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+
+		groups := variablePattern.FindStringSubmatch(match)
+		name := groups[1]
+		if value, ok := variables[name]; ok {
+			return value
+		}
+
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+
+		if strings.Contains(match, ":-") {
+			return groups[2]
+		}
+
+		if !tool.IsStringInArray(name, *undefined) {
+			*undefined = append(*undefined, name)
+		}
+		return match
+	})
+}
+
+// undefinedError returns the error of variables which are not defined
+func undefinedError(file string, undefined []string) error {
+	if len(undefined) == 0 {
+		return nil
+	}
+
+	sort.Strings(undefined)
+	return fmt.Errorf("variables are not defined in %s : %s", file, strings.Join(undefined, ", "))
+}
+
+// ignoreVariableErrors removes type errors of values which have variables like min: ${capacity}
+// Those values are checked after substitution.
+func ignoreVariableErrors(err error) error {
+	typeError, ok := err.(*yaml.TypeError)
+	if !ok {
+		return err
+	}
+
+	errors := []string{}
+	for _, e := range typeError.Errors {
+		if matched := yamlValuePattern.FindStringSubmatch(e); matched != nil && strings.Contains(matched[1], "${") {
+			continue
+		}
+		errors = append(errors, e)
+	}
+
+	if len(errors) == 0 {
+		return nil
+	}
+
+	return &yaml.TypeError{Errors: errors}
+}
+
+// mergeValues deep-merges the overlay into the base
+// Maps are merged recursively, and lists of which items have the same key like stack or region are
+// merged item by item. Other values of the overlay replace those of the base.
+func mergeValues(base, overlay interface{}) interface{} {
+	if base == nil {
+		return overlay
+	}
+
+	switch o := overlay.(type) {
+	case map[interface{}]interface{}:
+		b, ok := base.(map[interface{}]interface{})
+		if !ok {
+			return overlay
+		}
+
+		for k, v := range o {
+			b[k] = mergeValues(b[k], v)
+		}
+		return b
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return overlay
+		}
+
+		key := mergeKey(b, o)
+		if len(key) == 0 {
+			return overlay
+		}
+
+		for _, item := range o {
+			found := false
+			for i, baseItem := range b {
+				if baseItem.(map[interface{}]interface{})[key] == item.(map[interface{}]interface{})[key] {
+					b[i] = mergeValues(baseItem, item)
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				b = append(b, item)
+			}
+		}
+		return b
+	}
+
+	return overlay
+}
+
+// mergeKey returns the key with which items of lists are matched
+// Every item of both lists should be a map which has the key of string.
+func mergeKey(base, overlay []interface{}) string {
+	items := append(append([]interface{}{}, base...), overlay...)
+	for _, key := range MANIFEST_MERGE_KEYS {
+		matched := len(items) > 0
+		for _, item := range items {
+			m, ok := item.(map[interface{}]interface{})
+			if !ok {
+				matched = false
+				break
+			}
+
+			if _, ok := m[key].(string); !ok {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return key
+		}
+	}
+
+	return ""
+}
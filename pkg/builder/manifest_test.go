@@ -0,0 +1,297 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// parseYaml parses the document of test case
+func parseYaml(t *testing.T, data string) interface{} {
+	t.Helper()
+
+	var doc interface{}
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("wrong YAML of test case : %v", err)
+	}
+	return doc
+}
+
+func TestMergeValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+	}{
+		{
+			name:    "maps are merged recursively",
+			base:    "capacity: {min: 1, max: 2}\nenv: dev",
+			overlay: "capacity: {max: 4}",
+			want:    "capacity: {min: 1, max: 4}\nenv: dev",
+		},
+		{
+			name:    "stacks are merged by name of stack",
+			base:    "stacks: [{stack: a, env: dev}, {stack: b, env: dev}]",
+			overlay: "stacks: [{stack: b, env: prod}, {stack: c, env: prod}]",
+			want:    "stacks: [{stack: a, env: dev}, {stack: b, env: prod}, {stack: c, env: prod}]",
+		},
+		{
+			name:    "regions are merged by region",
+			base:    "regions: [{region: us-east-1, instance_type: t3.small}]",
+			overlay: "regions: [{region: us-east-1, ssh_key: key}]",
+			want:    "regions: [{region: us-east-1, instance_type: t3.small, ssh_key: key}]",
+		},
+		{
+			name:    "lists without key are replaced",
+			base:    "tags: [a=1, b=2]",
+			overlay: "tags: [c=3]",
+			want:    "tags: [c=3]",
+		},
+		{
+			name:    "scalar replaces map",
+			base:    "userdata: {type: local}",
+			overlay: "userdata: none",
+			want:    "userdata: none",
+		},
+		{
+			name:    "empty base",
+			base:    "",
+			overlay: "name: hello",
+			want:    "name: hello",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := mergeValues(parseYaml(t, test.base), parseYaml(t, test.overlay))
+			if want := parseYaml(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergeValues() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMergeKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+	}{
+		{name: "stack", base: "[{stack: a}]", overlay: "[{stack: b}]", want: "stack"},
+		{name: "region", base: "[{region: us-east-1}]", overlay: "[{region: eu-west-1}]", want: "region"},
+		{name: "name", base: "[{name: cpu}]", overlay: "[{name: memory}]", want: "name"},
+		{name: "stack is preferred", base: "[{stack: a, name: x}]", overlay: "[{stack: b, name: y}]", want: "stack"},
+		{name: "some items do not have the key", base: "[{stack: a}]", overlay: "[{env: dev}]"},
+		{name: "items are not maps", base: "[a, b]", overlay: "[c]"},
+		{name: "key is not string", base: "[{name: 1}]", overlay: "[{name: 2}]"},
+		{name: "empty lists", base: "[]", overlay: "[]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			base, _ := parseYaml(t, test.base).([]interface{})
+			overlay, _ := parseYaml(t, test.overlay).([]interface{})
+			if got := mergeKey(base, overlay); got != test.want {
+				t.Errorf("mergeKey() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSubstituteDocument(t *testing.T) {
+	os.Setenv("GOPLOYER_TEST_ENV", "from-env")
+	defer os.Unsetenv("GOPLOYER_TEST_ENV")
+
+	variables := map[string]string{
+		"env":      "prod",
+		"capacity": "3",
+		"version":  "1.10",
+		"injected": "x\nextra: true",
+	}
+
+	tests := []struct {
+		name string
+		doc  string
+		want string
+		err  string
+	}{
+		{name: "variable", doc: "env: ${env}", want: "env: prod"},
+		{name: "variable in text", doc: "name: hello-${env}-app", want: "name: hello-prod-app"},
+		{name: "environment variable", doc: "owner: ${GOPLOYER_TEST_ENV}", want: "owner: from-env"},
+		{name: "default value", doc: "owner: ${OWNER:-devops}", want: "owner: devops"},
+		{name: "empty default value", doc: "owner: ${OWNER:-}", want: "owner: ''"},
+		{name: "defined variable wins over default", doc: "env: ${env:-dev}", want: "env: prod"},
+		{name: "escaped variable", doc: "command: echo $${HOME}", want: "command: echo ${HOME}"},
+		{name: "number keeps its type", doc: "min: ${capacity}", want: "min: 3"},
+		{name: "number which changes when written is string", doc: "version: ${version}", want: "version: '1.10'"},
+		{name: "value cannot add fields", doc: "env: ${injected}", want: "env: \"x\\nextra: true\""},
+		{name: "lists", doc: "tags: ['env=${env}']", want: "tags: [env=prod]"},
+		{name: "keys are not substituted", doc: "${env}: value", want: "${env}: value"},
+		{name: "inline userdata is kept", doc: "userdata: {content: 'echo ${HOME}'}", want: "userdata: {content: 'echo ${HOME}'}"},
+		{name: "lifecycle callbacks are kept", doc: "lifecycle_callbacks: {pre_terminate_past_clusters: ['echo ${PID}']}", want: "lifecycle_callbacks: {pre_terminate_past_clusters: ['echo ${PID}']}"},
+		{name: "undefined variables", doc: "env: ${B_UNDEFINED}-${A_UNDEFINED}", err: "variables are not defined in test.yaml : A_UNDEFINED, B_UNDEFINED"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := substituteDocument("test.yaml", parseYaml(t, test.doc), variables)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("substituteDocument() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("substituteDocument() error = %v", err)
+			}
+
+			if want := parseYaml(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("substituteDocument() = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestResolveExtends(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+		err  string
+	}{
+		{
+			name: "fields of base are inherited",
+			doc:  "stacks: [{stack: base, env: dev, account: dev}, {stack: app, extends: base, env: prod}]",
+			want: "stacks: [{stack: base, env: dev, account: dev}, {stack: app, extends: base, env: prod, account: dev}]",
+		},
+		{
+			name: "maps are merged and lists are replaced",
+			doc:  "stacks: [{stack: base, capacity: {min: 1, max: 2}, regions: [{region: a}, {region: b}]}, {stack: app, extends: base, capacity: {max: 4}, regions: [{region: c}]}]",
+			want: "stacks: [{stack: base, capacity: {min: 1, max: 2}, regions: [{region: a}, {region: b}]}, {stack: app, extends: base, capacity: {min: 1, max: 4}, regions: [{region: c}]}]",
+		},
+		{
+			name: "chain of extends",
+			doc:  "stacks: [{stack: c, extends: b}, {stack: b, extends: a, env: b}, {stack: a, env: a, account: a}]",
+			want: "stacks: [{stack: c, extends: b, env: b, account: a}, {stack: b, extends: a, env: b, account: a}, {stack: a, env: a, account: a}]",
+		},
+		{
+			name: "circular extends",
+			doc:  "stacks: [{stack: a, extends: b}, {stack: b, extends: a}]",
+			err:  "circular extends exists : a -> b -> a",
+		},
+		{
+			name: "stack extends itself",
+			doc:  "stacks: [{stack: a, extends: a}]",
+			err:  "circular extends exists : a -> a",
+		},
+		{
+			name: "base does not exist",
+			doc:  "stacks: [{stack: a, extends: missing}]",
+			err:  "a extends the stack which does not exist : missing",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := parseYaml(t, test.doc)
+			err := resolveExtends(doc, ManifestSources{})
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("resolveExtends() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("resolveExtends() error = %v", err)
+			}
+
+			if want := parseYaml(t, test.want); !reflect.DeepEqual(doc, want) {
+				t.Errorf("resolveExtends() = %v, want %v", doc, want)
+			}
+		})
+	}
+}
+
+func TestReadManifestWithIncludesAndOverlays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goployer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"base.yaml":     "stacks:\n  - stack: base\n    env: dev\n    capacity: {min: 1, max: 2, desired: 1}\n",
+		"hello.yaml":    "include: [base.yaml]\nname: hello\nstacks:\n  - stack: app\n    extends: base\n",
+		"prod.yaml":     "stacks:\n  - stack: base\n    capacity: {max: 8}\n",
+		"circular.yaml": "include: [loop.yaml]\nname: hello\n",
+		"loop.yaml":     "include: [circular.yaml]\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name     string
+		manifest string
+		values   []string
+		want     Capacity
+		err      string
+	}{
+		{name: "included base", manifest: "hello.yaml", want: Capacity{Min: 1, Max: 2, Desired: 1}},
+		{name: "overlay is applied before extends", manifest: "hello.yaml", values: []string{path("prod.yaml")}, want: Capacity{Min: 1, Max: 8, Desired: 1}},
+		{name: "circular include", manifest: "circular.yaml", err: "circular include exists"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, sources, err := readManifest(path(test.manifest), test.values)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("readManifest() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readManifest() error = %v", err)
+			}
+
+			manifest := YamlConfig{}
+			if err := yaml.Unmarshal(data, &manifest); err != nil {
+				t.Fatal(err)
+			}
+
+			found := false
+			for _, stack := range manifest.Stacks {
+				if stack.Stack != "app" {
+					continue
+				}
+
+				found = true
+				if stack.Capacity != test.want {
+					t.Errorf("capacity of app = %+v, want %+v", stack.Capacity, test.want)
+				}
+			}
+
+			if !found {
+				t.Fatalf("stacks = %+v, want app", manifest.Stacks)
+			}
+
+			if want := path("base.yaml") + " (extends base)"; sources["app"]["env"] != want {
+				t.Errorf("source of env in app = %q, want %q", sources["app"]["env"], want)
+			}
+		})
+	}
+}
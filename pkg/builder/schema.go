@@ -0,0 +1,270 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	SCHEMA_DRAFT                      = "http://json-schema.org/draft-07/schema#"
+	availableMarketTypes              = []string{"spot"}
+	availableSpotAllocationStrategies = []string{"lowest-price", "capacity-optimized"}
+	availableSpotInstanceTypes        = []string{"one-time", "persistent"}
+	availableInterruptionBehaviors    = []string{"terminate", "stop", "hibernate"}
+	availableLifecycleDefaultResults  = []string{"CONTINUE", "ABANDON"}
+	availableAdjustmentTypes          = []string{"ChangeInCapacity", "ExactCapacity", "PercentChangeInCapacity"}
+	availableStatistics               = []string{"SampleCount", "Average", "Sum", "Minimum", "Maximum"}
+
+	// schemaEnums are values which fields of manifest can have
+	// Validation of manifest checks the same values so that the schema does not go out of date.
+	schemaEnums = map[string][]string{
+		"Stack.ReplacementType":                       availableReplacementTypes,
+		"Userdata.Type":                               availableUserdataTypes,
+		"InstanceMarketOptions.MarketType":            availableMarketTypes,
+		"SpotOptions.SpotInstanceType":                availableSpotInstanceTypes,
+		"SpotOptions.InstanceInterruptionBehavior":    availableInterruptionBehaviors,
+		"MixedInstancesPolicy.SpotAllocationStrategy": availableSpotAllocationStrategies,
+		"BlockDevice.VolumeType":                      availableBlockTypes,
+		"ScalePolicy.AdjustmentType":                  availableAdjustmentTypes,
+		"AlarmConfigs.Statistic":                      availableStatistics,
+		"AlarmConfigs.Comparison":                     availableComparisonOperators,
+		"CanaryMetric.Statistic":                      availableStatistics,
+		"CanaryMetric.Comparison":                     availableComparisonOperators,
+		"LifecycleHookSpecification.DefaultResult":    availableLifecycleDefaultResults,
+	}
+
+	// Fields other than string can be written as ${VAR} of manifest variables
+	schemaVariablePattern = `^.*\$\{[A-Za-z_][A-Za-z0-9_]*(:-[^}]*)?\}.*$`
+
+	yamlErrorPattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+	yamlFieldPattern = regexp.MustCompile("field (\\S+) not found")
+	yamlValuePattern = regexp.MustCompile("`([^`]*)`")
+)
+
+// GenerateSchema returns JSON schema of manifest generated from the structs of manifest
+// Editors can use it to autocomplete and check manifest files.
+func GenerateSchema() ([]byte, error) {
+	g := schemaGenerator{definitions: map[string]interface{}{}}
+
+	schema := g.structSchema(reflect.TypeOf(YamlConfig{}))
+	schema["$schema"] = SCHEMA_DRAFT
+	schema["title"] = "goployer manifest"
+	schema["definitions"] = g.definitions
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+type schemaGenerator struct {
+	definitions map[string]interface{}
+}
+
+// typeSchema returns the schema of the type
+// Structs are defined once in definitions and referred so that recursive types like userdata parts work.
+func (g schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]interface{}{
+			"description": "duration like 60s or 5m",
+			"type":        []string{"string", "integer"},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			g.definitions[t.Name()] = map[string]interface{}{}
+			g.definitions[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": fmt.Sprintf("#/definitions/%s", t.Name())}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return variableSchema("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return variableSchema("integer")
+	case reflect.Float32, reflect.Float64:
+		return variableSchema("number")
+	}
+
+	return map[string]interface{}{}
+}
+
+// structSchema returns the schema of struct which does not allow unknown fields
+func (g schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := yamlFieldName(field)
+		if len(name) == 0 {
+			continue
+		}
+
+		property := g.typeSchema(field.Type)
+		if enum, ok := schemaEnums[fmt.Sprintf("%s.%s", t.Name(), field.Name)]; ok {
+			property["enum"] = enum
+		}
+		properties[name] = property
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// variableSchema allows ${VAR} in place of the value of type
+func variableSchema(kind string) map[string]interface{} {
+	return map[string]interface{}{
+		"anyOf": []map[string]interface{}{
+			{"type": kind},
+			{"type": "string", "pattern": schemaVariablePattern},
+		},
+	}
+}
+
+// yamlFieldName returns the key of field in manifest
+// yaml package uses the lowercased name of field if it has no tag.
+func yamlFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	if len(name) == 0 {
+		return strings.ToLower(field.Name)
+	}
+
+	return name
+}
+
+// checkEnums checks that fields of the value have one of available values
+func checkEnums(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return checkEnums(v.Elem(), path)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkEnums(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := yamlFieldName(t.Field(i))
+			if len(name) == 0 {
+				continue
+			}
+
+			fieldPath := name
+			if len(path) > 0 {
+				fieldPath = fmt.Sprintf("%s.%s", path, name)
+			}
+
+			enum, ok := schemaEnums[fmt.Sprintf("%s.%s", t.Name(), t.Field(i).Name)]
+			if value := v.Field(i); ok && value.Kind() == reflect.String {
+				if value.Len() > 0 && !tool.IsStringInArray(value.String(), enum) {
+					return fmt.Errorf("not available value of %s : %s, available values: %s", fieldPath, value.String(), strings.Join(enum, ", "))
+				}
+				continue
+			}
+
+			if err := checkEnums(v.Field(i), fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// strictError adds the file, line and column to errors of strict decoding
+// e.g. configs/hello.yaml:190:5: field extra_vars not found in type builder.Stack
+func strictError(file string, data []byte, err error) error {
+	typeError, ok := err.(*yaml.TypeError)
+	if !ok {
+		return fmt.Errorf("error parsing YAML file %s : %s", file, err.Error())
+	}
+
+	lines := strings.Split(string(data), "\n")
+	messages := []string{}
+	for _, e := range typeError.Errors {
+		matched := yamlErrorPattern.FindStringSubmatch(e)
+		if matched == nil {
+			messages = append(messages, fmt.Sprintf("%s: %s", file, e))
+			continue
+		}
+
+		line, _ := strconv.Atoi(matched[1])
+		messages = append(messages, fmt.Sprintf("%s:%d:%d: %s", file, line, errorColumn(lines, line, matched[2]), matched[2]))
+	}
+
+	return fmt.Errorf("error parsing YAML file %s :\n  %s", file, strings.Join(messages, "\n  "))
+}
+
+// mergedError returns errors of the merged manifest without lines
+// Lines of the merged manifest are not the same with lines of any file.
+func mergedError(file string, err error) error {
+	typeError, ok := err.(*yaml.TypeError)
+	if !ok {
+		return fmt.Errorf("error parsing YAML file %s : %s", file, err.Error())
+	}
+
+	messages := []string{}
+	for _, e := range typeError.Errors {
+		if matched := yamlErrorPattern.FindStringSubmatch(e); matched != nil {
+			e = matched[2]
+		}
+
+		// The same value substituted in many fields makes the same errors
+		if !tool.IsStringInArray(e, messages) {
+			messages = append(messages, e)
+		}
+	}
+
+	return fmt.Errorf("error parsing YAML file %s after variables and overlays are applied :\n  %s", file, strings.Join(messages, "\n  "))
+}
+
+// errorColumn finds the column of the unknown field or wrong value in the line
+func errorColumn(lines []string, line int, message string) int {
+	if line < 1 || line > len(lines) {
+		return 1
+	}
+	text := lines[line-1]
+
+	if matched := yamlFieldPattern.FindStringSubmatch(message); matched != nil {
+		if i := strings.Index(text, matched[1]); i >= 0 {
+			return i + 1
+		}
+	}
+
+	if matched := yamlValuePattern.FindStringSubmatch(message); matched != nil {
+		if colon := strings.Index(text, ":"); colon >= 0 {
+			if i := strings.Index(text[colon:], matched[1]); i >= 0 {
+				return colon + i + 1
+			}
+		}
+	}
+
+	return len(text) - len(strings.TrimLeft(text, " -")) + 1
+}
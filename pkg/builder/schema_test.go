@@ -0,0 +1,114 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestErrorColumn(t *testing.T) {
+	lines := []string{
+		"name: hello",
+		"stacks:",
+		"  - stack: artd",
+		"    extra_vars: 1",
+		"    capacity:",
+		"      min: abc",
+	}
+
+	tests := []struct {
+		name    string
+		line    int
+		message string
+		want    int
+	}{
+		{name: "unknown field", line: 4, message: "field extra_vars not found in type builder.Stack", want: 5},
+		{name: "wrong value", line: 6, message: "cannot unmarshal !!str `abc` into int64", want: 12},
+		{name: "value is not found", line: 6, message: "cannot unmarshal !!str `xyz` into int64", want: 7},
+		{name: "item of list", line: 3, message: "unknown error", want: 5},
+		{name: "line out of range", line: 10, message: "field extra_vars not found in type builder.Stack", want: 1},
+		{name: "line zero", line: 0, message: "unknown error", want: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := errorColumn(lines, test.line, test.message); got != test.want {
+				t.Errorf("errorColumn(%d, %q) = %d, want %d", test.line, test.message, got, test.want)
+			}
+		})
+	}
+}
+
+func TestStrictError(t *testing.T) {
+	data := "name: hello\nstacks:\n  - stack: artd\n    extra_vars: 1\n"
+	err := yaml.UnmarshalStrict([]byte(data), &YamlConfig{})
+
+	want := "hello.yaml:4:5: field extra_vars not found in type builder.Stack"
+	if got := strictError("hello.yaml", []byte(data), err); got == nil || !strings.Contains(got.Error(), want) {
+		t.Errorf("strictError() = %v, want %q", got, want)
+	}
+}
+
+func TestMergedError(t *testing.T) {
+	data := "stacks:\n  - stack: artd\n    capacity: {min: abc, max: abc}\n"
+	err := yaml.Unmarshal([]byte(data), &YamlConfig{})
+
+	got := mergedError("hello.yaml", err)
+	if got == nil {
+		t.Fatal("mergedError() = nil, want error")
+	}
+
+	if strings.Contains(got.Error(), "line") || strings.Count(got.Error(), "`abc`") != 1 {
+		t.Errorf("mergedError() = %q, want one error without line", got.Error())
+	}
+}
+
+func TestCheckEnums(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest YamlConfig
+		err      string
+	}{
+		{
+			name:     "available values",
+			manifest: YamlConfig{Stacks: []Stack{{Stack: "artd", ReplacementType: REPLACEMENT_TYPE_BLUEGREEN}}},
+		},
+		{
+			name:     "empty values are not checked",
+			manifest: YamlConfig{Stacks: []Stack{{Stack: "artd"}}},
+		},
+		{
+			name:     "field of stack",
+			manifest: YamlConfig{Stacks: []Stack{{Stack: "artd", ReplacementType: "Recreate"}}},
+			err:      "not available value of stacks[0].replacement_type : Recreate",
+		},
+		{
+			name:     "field of top level",
+			manifest: YamlConfig{Userdata: Userdata{Type: "ftp"}},
+			err:      "not available value of userdata.type : ftp",
+		},
+		{
+			name:     "field in list of stack",
+			manifest: YamlConfig{Stacks: []Stack{{Stack: "a"}, {Stack: "b", BlockDevices: []BlockDevice{{DeviceName: "/dev/xvda", VolumeType: "ssd"}}}}},
+			err:      "not available value of stacks[1].block_devices[0].volume_type : ssd",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkEnums(reflect.ValueOf(test.manifest), "")
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Fatalf("checkEnums() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("checkEnums() error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"strings"
+)
+
+// StackNames returns stacks passed from command line in order
+func (c Config) StackNames() []string {
+	names := []string{}
+	for _, name := range strings.Split(c.Stack, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) > 0 && !tool.IsStringInArray(name, names) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// GetTargetStacks returns stacks to deploy in the order of command line
+func (b Builder) GetTargetStacks() ([]Stack, error) {
+	stacks := []Stack{}
+	for _, name := range b.Config.StackNames() {
+		found := false
+		for _, stack := range b.Stacks {
+			if stack.Stack == name {
+				stacks = append(stacks, stack)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("no stack exists in manifest : %s", name)
+		}
+	}
+
+	return stacks, nil
+}
+
+// MakeWaves groups stacks into ordered waves of deployment
+// Stacks of a wave start only after every stack of previous waves is healthy.
+// Without parallel option, each wave has one stack in the order of command line unless depends_on requires otherwise.
+// With parallel option, stacks which do not depend on each other are in the same wave.
+func MakeWaves(stacks []Stack, parallel bool) ([][]Stack, error) {
+	names := []string{}
+	for _, stack := range stacks {
+		names = append(names, stack.Stack)
+	}
+
+	// Dependencies on stacks which are not deployed together are already satisfied
+	levels := map[string]int{}
+	placed := []string{}
+	for len(placed) < len(stacks) {
+		progress := false
+		for _, stack := range stacks {
+			if tool.IsStringInArray(stack.Stack, placed) {
+				continue
+			}
+
+			level := 0
+			ready := true
+			for _, dep := range stack.DependsOn {
+				if !tool.IsStringInArray(dep, names) {
+					continue
+				}
+
+				if !tool.IsStringInArray(dep, placed) {
+					ready = false
+					break
+				}
+
+				if levels[dep]+1 > level {
+					level = levels[dep] + 1
+				}
+			}
+
+			if !ready {
+				continue
+			}
+
+			levels[stack.Stack] = level
+			placed = append(placed, stack.Stack)
+			progress = true
+
+			// Keep the order of command line as much as possible
+			if !parallel {
+				break
+			}
+		}
+
+		if !progress {
+			return nil, fmt.Errorf("circular dependency exists in depends_on of stacks : %s", strings.Join(names, ", "))
+		}
+	}
+
+	waves := [][]Stack{}
+	if !parallel {
+		for _, name := range placed {
+			for _, stack := range stacks {
+				if stack.Stack == name {
+					waves = append(waves, []Stack{stack})
+				}
+			}
+		}
+		return waves, nil
+	}
+
+	for _, stack := range stacks {
+		for len(waves) <= levels[stack.Stack] {
+			waves = append(waves, []Stack{})
+		}
+		waves[levels[stack.Stack]] = append(waves[levels[stack.Stack]], stack)
+	}
+
+	return waves, nil
+}
+
+// checkDependencies checks if depends_on of stacks refers to other stacks in manifest
+func checkDependencies(stacks []Stack) error {
+	names := []string{}
+	for _, stack := range stacks {
+		names = append(names, stack.Stack)
+	}
+
+	for _, stack := range stacks {
+		for _, dep := range stack.DependsOn {
+			if dep == stack.Stack {
+				return fmt.Errorf("stack cannot depend on itself : %s", stack.Stack)
+			}
+
+			if !tool.IsStringInArray(dep, names) {
+				return fmt.Errorf("%s depends on the stack which does not exist : %s", stack.Stack, dep)
+			}
+		}
+	}
+
+	if _, err := MakeWaves(stacks, true); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMakeWaves(t *testing.T) {
+	tests := []struct {
+		name     string
+		stacks   []Stack
+		parallel bool
+		want     [][]string
+		err      string
+	}{
+		{
+			name:   "order of command line without dependencies",
+			stacks: []Stack{{Stack: "b"}, {Stack: "a"}},
+			want:   [][]string{{"b"}, {"a"}},
+		},
+		{
+			name:   "dependency is deployed first",
+			stacks: []Stack{{Stack: "api", DependsOn: []string{"db"}}, {Stack: "db"}},
+			want:   [][]string{{"db"}, {"api"}},
+		},
+		{
+			name:   "dependency which is not deployed together is ignored",
+			stacks: []Stack{{Stack: "api", DependsOn: []string{"db"}}},
+			want:   [][]string{{"api"}},
+		},
+		{
+			name:     "independent stacks are in the same wave",
+			stacks:   []Stack{{Stack: "a"}, {Stack: "b"}},
+			parallel: true,
+			want:     [][]string{{"a", "b"}},
+		},
+		{
+			name: "parallel waves follow the longest dependency",
+			stacks: []Stack{
+				{Stack: "web", DependsOn: []string{"api"}},
+				{Stack: "api", DependsOn: []string{"db"}},
+				{Stack: "db"},
+				{Stack: "batch", DependsOn: []string{"db"}},
+			},
+			parallel: true,
+			want:     [][]string{{"db"}, {"api", "batch"}, {"web"}},
+		},
+		{
+			name:   "circular dependency",
+			stacks: []Stack{{Stack: "a", DependsOn: []string{"b"}}, {Stack: "b", DependsOn: []string{"a"}}},
+			err:    "circular dependency",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			waves, err := MakeWaves(test.stacks, test.parallel)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("MakeWaves() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("MakeWaves() error = %v", err)
+			}
+
+			got := [][]string{}
+			for _, wave := range waves {
+				names := []string{}
+				for _, stack := range wave {
+					names = append(names, stack.Stack)
+				}
+				got = append(got, names)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("MakeWaves() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// UserdataVars is the data with which userdata template is rendered
+// Variables of manifest are in Vars, e.g. {{ .Vars.port }}.
+type UserdataVars struct {
+	App              string
+	Stack            string
+	Env              string
+	Region           string
+	Version          int
+	AutoscalingGroup string
+	LaunchTemplate   string
+	Ami              string
+	InstanceType     string
+	AnsibleTags      string
+	AnsibleExtraVars string
+	ExtraTags        string
+	Vars             map[string]string
+}
+
+// NewUserdataVars returns variables of the stack in the region
+// Names of autoscaling group and launch template are filled by deployer,
+// and the selector is shown as AMI until deployer resolves it.
+func NewUserdataVars(app string, config Config, stack Stack, region RegionConfig) UserdataVars {
+	ami, selector, _ := GetAmi(config, stack, region)
+	if selector != nil {
+		ami = selector.String()
+	}
+
+	instanceType := region.InstanceType
+	if len(config.OverrideInstanceType) > 0 {
+		instanceType = config.OverrideInstanceType
+	}
+
+	return UserdataVars{
+		App:              app,
+		Stack:            stack.Stack,
+		Env:              stack.Env,
+		Region:           region.Region,
+		Ami:              ami,
+		InstanceType:     instanceType,
+		AnsibleTags:      stack.AnsibleTags,
+		AnsibleExtraVars: config.AnsibleExtraVars,
+		ExtraTags:        config.ExtraTags,
+	}
+}
+
+// S3ObjectAPI reads userdata from S3
+// It is implemented by the S3 client of aws package.
+type S3ObjectAPI interface {
+	GetObject(bucket, key, version string) ([]byte, error)
+	HeadObject(bucket, key, version string) (int64, error)
+}
+
+// ParameterAPI reads userdata from SSM parameter store
+type ParameterAPI interface {
+	GetParameter(name string) (string, error)
+}
+
+// SecretAPI reads userdata from secrets manager
+type SecretAPI interface {
+	GetSecretValue(id string) (string, error)
+}
+
+// UserdataClients are clients of the region with which userdata is read from AWS
+type UserdataClients struct {
+	S3             S3ObjectAPI
+	SSM            ParameterAPI
+	SecretsManager SecretAPI
+}
+
+// UserdataBootstrapper creates clients with the region and assume role of the stack
+type UserdataBootstrapper func(region string, assume_role string) UserdataClients
+
+// newUserdataProvider returns the provider of userdata type
+// Parts inherit template option and variables of the userdata.
+func newUserdataProvider(userdata Userdata) UserdataProvider {
+	if len(userdata.Parts) > 0 {
+		parts := []UserdataPart{}
+		for _, part := range userdata.Parts {
+			part.Vars = mergeUserdataVars(userdata.Vars, part.Vars)
+			part.Template = part.Template || userdata.Template
+			parts = append(parts, UserdataPart{
+				ContentType: part.ContentType,
+				Provider:    newUserdataProvider(part),
+			})
+		}
+
+		return MultipartProvider{Parts: parts}
+	}
+
+	switch userdata.Type {
+	case USERDATA_TYPE_S3:
+		return S3Provider{Path: userdata.Path, Template: userdata.Template, Vars: userdata.Vars}
+	case USERDATA_TYPE_SSM:
+		return SSMProvider{Name: userdata.Path, Template: userdata.Template, Vars: userdata.Vars}
+	case USERDATA_TYPE_SECRETSMANAGER:
+		return SecretsManagerProvider{SecretId: userdata.Path, Template: userdata.Template, Vars: userdata.Vars}
+	case USERDATA_TYPE_INLINE:
+		return InlineProvider{Content: userdata.Content, Template: userdata.Template, Vars: userdata.Vars}
+	}
+
+	return LocalProvider{
+		Path:     userdata.Path,
+		Template: userdata.Template,
+		Vars:     userdata.Vars,
+	}
+}
+
+// mergeUserdataVars returns variables of base overridden by those of override
+func mergeUserdataVars(base, override map[string]string) map[string]string {
+	vars := map[string]string{}
+	for k, v := range base {
+		vars[k] = v
+	}
+	for k, v := range override {
+		vars[k] = v
+	}
+
+	return vars
+}
+
+// WithUserdataClients returns the provider which reads userdata with the clients
+// Providers which do not read userdata from AWS are returned as they are.
+func WithUserdataClients(provider UserdataProvider, clients UserdataClients) UserdataProvider {
+	switch p := provider.(type) {
+	case S3Provider:
+		p.Client = clients.S3
+		return p
+	case SSMProvider:
+		p.Client = clients.SSM
+		return p
+	case SecretsManagerProvider:
+		p.Client = clients.SecretsManager
+		return p
+	case MultipartProvider:
+		parts := []UserdataPart{}
+		for _, part := range p.Parts {
+			part.Provider = WithUserdataClients(part.Provider, clients)
+			parts = append(parts, part)
+		}
+		p.Parts = parts
+		return p
+	}
+
+	return provider
+}
+
+// S3Provider provides userdata stored in S3
+// Path is s3://bucket/key with optional ?versionId=<version>.
+// Client should be set with the client of the deploying region before Provide is called.
+type S3Provider struct {
+	Path     string
+	Client   S3ObjectAPI
+	Template bool
+	Vars     map[string]string
+}
+
+func (s S3Provider) Provide(vars UserdataVars) (string, error) {
+	bucket, key, version, err := ParseS3Path(s.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if s.Client == nil {
+		return "", fmt.Errorf("no client to read userdata from %s", s.Path)
+	}
+
+	userdata, err := s.Client.GetObject(bucket, key, version)
+	if err != nil {
+		return "", fmt.Errorf("error reading userdata from s3 : %s", err.Error())
+	}
+
+	return renderUserdata(s.Path, userdata, s.Template, s.Vars, vars)
+}
+
+// Check checks that the object exists and is not too large without downloading it
+// Templates cannot be checked without rendering so that Provide should be used for them.
+func (s S3Provider) Check() error {
+	bucket, key, version, err := ParseS3Path(s.Path)
+	if err != nil {
+		return err
+	}
+
+	if s.Client == nil {
+		return fmt.Errorf("no client to read userdata from %s", s.Path)
+	}
+
+	size, err := s.Client.HeadObject(bucket, key, version)
+	if err != nil {
+		return fmt.Errorf("error reading userdata from s3 : %s", err.Error())
+	}
+
+	if size > int64(MAX_USERDATA_SIZE) {
+		return fmt.Errorf("userdata is larger than %d bytes : %s(%d bytes)", MAX_USERDATA_SIZE, s.Path, size)
+	}
+
+	return nil
+}
+
+// SSMProvider provides userdata stored in SSM parameter store
+// SecureString parameters are decrypted.
+type SSMProvider struct {
+	Name     string
+	Client   ParameterAPI
+	Template bool
+	Vars     map[string]string
+}
+
+func (s SSMProvider) Provide(vars UserdataVars) (string, error) {
+	if len(s.Name) == 0 {
+		return "", fmt.Errorf("please specify the name of parameter for userdata")
+	}
+
+	if s.Client == nil {
+		return "", fmt.Errorf("no client to read userdata from parameter %s", s.Name)
+	}
+
+	userdata, err := s.Client.GetParameter(s.Name)
+	if err != nil {
+		return "", fmt.Errorf("error reading userdata from parameter store : %s", err.Error())
+	}
+
+	return renderUserdata(s.Name, []byte(userdata), s.Template, s.Vars, vars)
+}
+
+// SecretsManagerProvider provides userdata stored in secrets manager
+// SecretId can be the name or ARN of the secret.
+type SecretsManagerProvider struct {
+	SecretId string
+	Client   SecretAPI
+	Template bool
+	Vars     map[string]string
+}
+
+func (s SecretsManagerProvider) Provide(vars UserdataVars) (string, error) {
+	if len(s.SecretId) == 0 {
+		return "", fmt.Errorf("please specify the secret id for userdata")
+	}
+
+	if s.Client == nil {
+		return "", fmt.Errorf("no client to read userdata from secret %s", s.SecretId)
+	}
+
+	userdata, err := s.Client.GetSecretValue(s.SecretId)
+	if err != nil {
+		return "", fmt.Errorf("error reading userdata from secrets manager : %s", err.Error())
+	}
+
+	return renderUserdata(s.SecretId, []byte(userdata), s.Template, s.Vars, vars)
+}
+
+// InlineProvider provides the script written in the manifest
+type InlineProvider struct {
+	Content  string
+	Template bool
+	Vars     map[string]string
+}
+
+func (i InlineProvider) Provide(vars UserdataVars) (string, error) {
+	if len(i.Content) == 0 {
+		return "", fmt.Errorf("please specify the content of inline userdata")
+	}
+
+	return renderUserdata("inline", []byte(i.Content), i.Template, i.Vars, vars)
+}
+
+// UserdataPart is a part of multipart userdata
+// ContentType is detected from the first line of the part if it is empty.
+type UserdataPart struct {
+	ContentType string
+	Provider    UserdataProvider
+}
+
+// MultipartProvider composes parts into multipart MIME userdata which cloud-init runs in order
+type MultipartProvider struct {
+	Parts []UserdataPart
+}
+
+func (m MultipartProvider) Provide(vars UserdataVars) (string, error) {
+	buf := bytes.Buffer{}
+	writer := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=\"%s\"\r\nMIME-Version: 1.0\r\n\r\n", writer.Boundary())
+	for i, part := range m.Parts {
+		encoded, err := part.Provider.Provide(vars)
+		if err != nil {
+			return "", fmt.Errorf("part %d of userdata : %s", i+1, err.Error())
+		}
+
+		content, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("part %d of userdata is not encoded with base64 : %s", i+1, err.Error())
+		}
+
+		contentType := part.ContentType
+		if len(contentType) == 0 {
+			contentType = detectContentType(content)
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", fmt.Sprintf("%s; charset=\"utf-8\"", contentType))
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Transfer-Encoding", "8bit")
+
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := w.Write(content); err != nil {
+			return "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	return encodeUserdata("multipart", buf.Bytes())
+}
+
+// detectContentType returns the content type of cloud-init from the first line of userdata
+func detectContentType(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte("#cloud-config")):
+		return "text/cloud-config"
+	case bytes.HasPrefix(content, []byte("#cloud-boothook")):
+		return "text/cloud-boothook"
+	case bytes.HasPrefix(content, []byte("#include")):
+		return "text/x-include-url"
+	}
+
+	return "text/x-shellscript"
+}
+
+// renderUserdata renders the template of userdata and encodes it with base64
+// Rendering fails if the template refers to a variable which is not defined.
+func renderUserdata(name string, userdata []byte, isTemplate bool, manifestVars map[string]string, vars UserdataVars) (string, error) {
+	if isTemplate {
+		vars.Vars = manifestVars
+
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(userdata))
+		if err != nil {
+			return "", fmt.Errorf("userdata template is not valid : %s", err.Error())
+		}
+
+		buf := bytes.Buffer{}
+		if err := tmpl.Execute(&buf, vars); err != nil {
+			return "", fmt.Errorf("failed to render userdata template : %s", err.Error())
+		}
+		userdata = buf.Bytes()
+	}
+
+	return encodeUserdata(name, userdata)
+}
+
+// encodeUserdata encodes userdata with base64
+// EC2 rejects userdata larger than 16KB before base64 encoding.
+func encodeUserdata(name string, userdata []byte) (string, error) {
+	if len(userdata) > MAX_USERDATA_SIZE {
+		return "", fmt.Errorf("userdata is larger than %d bytes : %s(%d bytes)", MAX_USERDATA_SIZE, name, len(userdata))
+	}
+
+	return base64.StdEncoding.EncodeToString(userdata), nil
+}
+
+// ParseS3Path returns bucket, key and version of the path like s3://bucket/key?versionId=<version>
+func ParseS3Path(path string) (string, string, string, error) {
+	if !strings.HasPrefix(path, "s3://") {
+		return "", "", "", fmt.Errorf("path of s3 userdata should be s3://<bucket>/<key> : %s", path)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", "", "", fmt.Errorf("path of s3 userdata is not valid : %s", err.Error())
+	}
+
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if len(bucket) == 0 || len(key) == 0 {
+		return "", "", "", fmt.Errorf("path of s3 userdata should be s3://<bucket>/<key> : %s", path)
+	}
+
+	return bucket, key, u.Query().Get("versionId"), nil
+}
+
+// checkUserdata checks userdata of the stack in every target region
+// Userdata is read and rendered with variables of the region if clients are given.
+// Otherwise only local templates are rendered.
+func (b Builder) checkUserdata(stack Stack) error {
+	for _, userdata := range []Userdata{b.AwsConfig.Userdata, stack.Userdata} {
+		if err := checkUserdataType(userdata, false); err != nil {
+			return fmt.Errorf("userdata of %s : %s", stack.Stack, err.Error())
+		}
+	}
+
+	provider := SetUserdataProvider(stack.Userdata, b.AwsConfig.Userdata)
+	if err := checkUserdataPaths(provider); err != nil {
+		return fmt.Errorf("userdata of %s : %s", stack.Stack, err.Error())
+	}
+
+	if b.UserdataBootstrap == nil && !hasLocalTemplate(provider) {
+		return nil
+	}
+
+	for _, region := range stack.Regions {
+		if b.Config.Region != "" && b.Config.Region != region.Region {
+			continue
+		}
+
+		p := provider
+		if b.UserdataBootstrap != nil {
+			p = WithUserdataClients(provider, b.UserdataBootstrap(region.Region, stack.AssumeRole))
+		} else if _, ok := provider.(LocalProvider); !ok {
+			continue
+		}
+
+		// Userdata in S3 which is not a template does not need to be downloaded
+		if s3, ok := p.(S3Provider); ok && !s3.Template {
+			if err := s3.Check(); err != nil {
+				return fmt.Errorf("userdata of %s cannot be read in %s : %s", stack.Stack, region.Region, err.Error())
+			}
+			continue
+		}
+
+		if _, err := p.Provide(NewUserdataVars(b.AwsConfig.Name, b.Config, stack, region)); err != nil {
+			return fmt.Errorf("userdata of %s cannot be rendered in %s : %s", stack.Stack, region.Region, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// checkUserdataType checks type of userdata and its parts
+func checkUserdataType(userdata Userdata, isPart bool) error {
+	if len(userdata.Type) > 0 && !tool.IsStringInArray(userdata.Type, availableUserdataTypes) {
+		return fmt.Errorf("type is not supported : %s, available types: %s", userdata.Type, strings.Join(availableUserdataTypes, ", "))
+	}
+
+	if isPart && len(userdata.Parts) > 0 {
+		return fmt.Errorf("part of userdata cannot have parts")
+	}
+
+	for _, part := range userdata.Parts {
+		if err := checkUserdataType(part, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkUserdataPaths checks that S3 paths of userdata can be parsed
+func checkUserdataPaths(provider UserdataProvider) error {
+	switch p := provider.(type) {
+	case S3Provider:
+		if _, _, _, err := ParseS3Path(p.Path); err != nil {
+			return err
+		}
+	case MultipartProvider:
+		for _, part := range p.Parts {
+			if err := checkUserdataPaths(part.Provider); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasLocalTemplate checks if the userdata is a local template which can be rendered without clients
+func hasLocalTemplate(provider UserdataProvider) bool {
+	p, ok := provider.(LocalProvider)
+	return ok && p.Template
+}
@@ -0,0 +1,326 @@
+package builder
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubS3 serves sizes of objects and counts downloads
+type stubS3 struct {
+	sizes     map[string]int64
+	downloads *int
+}
+
+func (s stubS3) GetObject(bucket, key, version string) ([]byte, error) {
+	*s.downloads++
+	size, ok := s.sizes[bucket+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("object does not exist")
+	}
+	return make([]byte, size), nil
+}
+
+func (s stubS3) HeadObject(bucket, key, version string) (int64, error) {
+	size, ok := s.sizes[bucket+"/"+key]
+	if !ok {
+		return 0, fmt.Errorf("object does not exist")
+	}
+	return size, nil
+}
+
+// stubParameters serves values of SSM parameters
+type stubParameters map[string]string
+
+func (s stubParameters) GetParameter(name string) (string, error) {
+	value, ok := s[name]
+	if !ok {
+		return "", fmt.Errorf("parameter does not exist")
+	}
+	return value, nil
+}
+
+// stubSecrets serves values of secrets
+type stubSecrets map[string]string
+
+func (s stubSecrets) GetSecretValue(id string) (string, error) {
+	value, ok := s[id]
+	if !ok {
+		return "", fmt.Errorf("secret does not exist")
+	}
+	return value, nil
+}
+
+// decodeUserdata decodes userdata encoded by providers
+func decodeUserdata(t *testing.T, encoded string) string {
+	t.Helper()
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("userdata is not encoded with base64 : %v", err)
+	}
+	return string(decoded)
+}
+
+func TestRenderUserdata(t *testing.T) {
+	vars := UserdataVars{App: "hello", Stack: "artd", Region: "ap-northeast-2", Version: 3}
+
+	tests := []struct {
+		name     string
+		userdata string
+		template bool
+		vars     map[string]string
+		want     string
+		err      string
+	}{
+		{name: "script", userdata: "#!/bin/bash\necho {{ .App }}", want: "#!/bin/bash\necho {{ .App }}"},
+		{name: "template", userdata: "echo {{ .App }}-{{ .Stack }} {{ .Region }} v{{ .Version }}", template: true, want: "echo hello-artd ap-northeast-2 v3"},
+		{name: "variables of manifest", userdata: "PORT={{ .Vars.port }}", template: true, vars: map[string]string{"port": "8080"}, want: "PORT=8080"},
+		{name: "undefined variable of manifest", userdata: "PORT={{ .Vars.port }}", template: true, vars: map[string]string{}, err: "failed to render userdata template"},
+		{name: "undefined field", userdata: "{{ .Unknown }}", template: true, err: "failed to render userdata template"},
+		{name: "wrong template", userdata: "{{ .App", template: true, err: "userdata template is not valid"},
+		{name: "large script", userdata: strings.Repeat("a", MAX_USERDATA_SIZE+1), err: "userdata is larger than"},
+		{name: "large after rendering", userdata: "{{ .Vars.body }}", template: true, vars: map[string]string{"body": strings.Repeat("a", MAX_USERDATA_SIZE)}, want: strings.Repeat("a", MAX_USERDATA_SIZE)},
+		{name: "too large after rendering", userdata: "echo {{ .Vars.body }}", template: true, vars: map[string]string{"body": strings.Repeat("a", MAX_USERDATA_SIZE)}, err: "userdata is larger than 16384 bytes : test(16389 bytes)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := renderUserdata("test", []byte(test.userdata), test.template, test.vars, vars)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("renderUserdata() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("renderUserdata() error = %v", err)
+			}
+
+			if decoded := decodeUserdata(t, got); decoded != test.want {
+				t.Errorf("renderUserdata() = %q, want %q", decoded, test.want)
+			}
+		})
+	}
+}
+
+func TestUserdataProviders(t *testing.T) {
+	clients := UserdataClients{
+		SSM:            stubParameters{"/hello/userdata": "echo {{ .Vars.env }} from ssm"},
+		SecretsManager: stubSecrets{"hello-userdata": "echo {{ .Vars.env }} from secret"},
+	}
+	vars := map[string]string{"env": "dev"}
+
+	tests := []struct {
+		name     string
+		userdata Userdata
+		clients  UserdataClients
+		want     string
+		err      string
+	}{
+		{name: "ssm", userdata: Userdata{Type: USERDATA_TYPE_SSM, Path: "/hello/userdata", Template: true, Vars: vars}, clients: clients, want: "echo dev from ssm"},
+		{name: "ssm without template", userdata: Userdata{Type: USERDATA_TYPE_SSM, Path: "/hello/userdata"}, clients: clients, want: "echo {{ .Vars.env }} from ssm"},
+		{name: "missing parameter", userdata: Userdata{Type: USERDATA_TYPE_SSM, Path: "/hello/missing"}, clients: clients, err: "error reading userdata from parameter store : parameter does not exist"},
+		{name: "ssm without name", userdata: Userdata{Type: USERDATA_TYPE_SSM}, clients: clients, err: "please specify the name of parameter"},
+		{name: "ssm without client", userdata: Userdata{Type: USERDATA_TYPE_SSM, Path: "/hello/userdata"}, err: "no client to read userdata from parameter /hello/userdata"},
+		{name: "secrets manager", userdata: Userdata{Type: USERDATA_TYPE_SECRETSMANAGER, Path: "hello-userdata", Template: true, Vars: vars}, clients: clients, want: "echo dev from secret"},
+		{name: "missing secret", userdata: Userdata{Type: USERDATA_TYPE_SECRETSMANAGER, Path: "missing"}, clients: clients, err: "error reading userdata from secrets manager : secret does not exist"},
+		{name: "secrets manager without id", userdata: Userdata{Type: USERDATA_TYPE_SECRETSMANAGER}, clients: clients, err: "please specify the secret id"},
+		{name: "secrets manager without client", userdata: Userdata{Type: USERDATA_TYPE_SECRETSMANAGER, Path: "hello-userdata"}, err: "no client to read userdata from secret hello-userdata"},
+		{name: "inline", userdata: Userdata{Type: USERDATA_TYPE_INLINE, Content: "echo {{ .Vars.env }} inline", Template: true, Vars: vars}, want: "echo dev inline"},
+		{name: "inline without content", userdata: Userdata{Type: USERDATA_TYPE_INLINE}, err: "please specify the content of inline userdata"},
+		{name: "undefined variable", userdata: Userdata{Type: USERDATA_TYPE_INLINE, Content: "echo {{ .Vars.port }}", Template: true, Vars: vars}, err: "failed to render userdata template"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := WithUserdataClients(newUserdataProvider(test.userdata), test.clients)
+			got, err := provider.Provide(UserdataVars{App: "hello"})
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Provide() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Provide() error = %v", err)
+			}
+
+			if decoded := decodeUserdata(t, got); decoded != test.want {
+				t.Errorf("Provide() = %q, want %q", decoded, test.want)
+			}
+		})
+	}
+}
+
+func TestNewUserdataProviderMergesVarsIntoParts(t *testing.T) {
+	provider := newUserdataProvider(Userdata{
+		Template: true,
+		Vars:     map[string]string{"env": "dev", "port": "8080"},
+		Parts: []Userdata{
+			{Type: USERDATA_TYPE_INLINE, Content: "a", Vars: map[string]string{"port": "9090"}},
+			{Type: USERDATA_TYPE_SSM, Path: "/hello/userdata"},
+		},
+	})
+
+	parts, ok := provider.(MultipartProvider)
+	if !ok || len(parts.Parts) != 2 {
+		t.Fatalf("provider = %#v, want multipart with 2 parts", provider)
+	}
+
+	inline, ok := parts.Parts[0].Provider.(InlineProvider)
+	if !ok {
+		t.Fatalf("provider of part 1 = %#v, want inline", parts.Parts[0].Provider)
+	}
+
+	if want := map[string]string{"env": "dev", "port": "9090"}; !inline.Template || !reflect.DeepEqual(inline.Vars, want) {
+		t.Errorf("part 1 = template %v with %v, want template with %v", inline.Template, inline.Vars, want)
+	}
+
+	ssm, ok := parts.Parts[1].Provider.(SSMProvider)
+	if !ok {
+		t.Fatalf("provider of part 2 = %#v, want ssm", parts.Parts[1].Provider)
+	}
+
+	if want := map[string]string{"env": "dev", "port": "8080"}; !ssm.Template || !reflect.DeepEqual(ssm.Vars, want) {
+		t.Errorf("part 2 = template %v with %v, want template with %v", ssm.Template, ssm.Vars, want)
+	}
+}
+
+func TestMultipartProvider(t *testing.T) {
+	clients := UserdataClients{SSM: stubParameters{"/hello/config": "#cloud-config\npackages: [{{ .Vars.package }}]"}}
+
+	tests := []struct {
+		name  string
+		parts []Userdata
+		want  []string
+		types []string
+		err   string
+	}{
+		{
+			name: "parts in order",
+			parts: []Userdata{
+				{Type: USERDATA_TYPE_SSM, Path: "/hello/config", Template: true, Vars: map[string]string{"package": "nginx"}},
+				{Type: USERDATA_TYPE_INLINE, Content: "#!/bin/bash\necho {{ .App }}", Template: true},
+				{Type: USERDATA_TYPE_INLINE, Content: "#cloud-boothook\necho boot"},
+				{Type: USERDATA_TYPE_INLINE, Content: "#include\nhttps://example.com/userdata"},
+				{Type: USERDATA_TYPE_INLINE, Content: "echo typed", ContentType: "text/x-shellscript-per-boot"},
+			},
+			want:  []string{"#cloud-config\npackages: [nginx]", "#!/bin/bash\necho hello", "#cloud-boothook\necho boot", "#include\nhttps://example.com/userdata", "echo typed"},
+			types: []string{"text/cloud-config", "text/x-shellscript", "text/cloud-boothook", "text/x-include-url", "text/x-shellscript-per-boot"},
+		},
+		{
+			name:  "error of part",
+			parts: []Userdata{{Type: USERDATA_TYPE_INLINE, Content: "a"}, {Type: USERDATA_TYPE_INLINE}},
+			err:   "part 2 of userdata : please specify the content of inline userdata",
+		},
+		{
+			name: "parts are too large together",
+			parts: []Userdata{
+				{Type: USERDATA_TYPE_INLINE, Content: strings.Repeat("a", MAX_USERDATA_SIZE/2)},
+				{Type: USERDATA_TYPE_INLINE, Content: strings.Repeat("b", MAX_USERDATA_SIZE/2)},
+			},
+			err: "userdata is larger than 16384 bytes : multipart",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := WithUserdataClients(newUserdataProvider(Userdata{Parts: test.parts}), clients)
+			got, err := provider.Provide(UserdataVars{App: "hello"})
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Provide() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Provide() error = %v", err)
+			}
+
+			message, err := mail.ReadMessage(strings.NewReader(decodeUserdata(t, got)))
+			if err != nil {
+				t.Fatalf("userdata is not MIME message : %v", err)
+			}
+
+			mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/mixed" {
+				t.Fatalf("content type of userdata = %q, want multipart/mixed", message.Header.Get("Content-Type"))
+			}
+
+			reader := multipart.NewReader(message.Body, params["boundary"])
+			contents, types := []string{}, []string{}
+			for {
+				part, err := reader.NextPart()
+				if err != nil {
+					break
+				}
+
+				content, err := ioutil.ReadAll(part)
+				if err != nil {
+					t.Fatal(err)
+				}
+				contents = append(contents, string(content))
+
+				contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+				types = append(types, contentType)
+			}
+
+			if !reflect.DeepEqual(contents, test.want) {
+				t.Errorf("parts = %q, want %q", contents, test.want)
+			}
+
+			if !reflect.DeepEqual(types, test.types) {
+				t.Errorf("content types = %v, want %v", types, test.types)
+			}
+		})
+	}
+}
+
+func TestS3ProviderCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "object exists", path: "s3://bucket/userdata.sh"},
+		{name: "object does not exist", path: "s3://bucket/missing.sh", want: "object does not exist"},
+		{name: "object is too large", path: "s3://bucket/large.sh", want: "userdata is larger than"},
+		{name: "wrong path", path: "bucket/userdata.sh", want: "should be s3://"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			downloads := 0
+			client := stubS3{
+				sizes: map[string]int64{
+					"bucket/userdata.sh": 100,
+					"bucket/large.sh":    int64(MAX_USERDATA_SIZE) + 1,
+				},
+				downloads: &downloads,
+			}
+
+			err := S3Provider{Path: test.path, Client: client}.Check()
+			if len(test.want) == 0 && err != nil {
+				t.Fatalf("Check() = %v, want nil", err)
+			}
+
+			if len(test.want) > 0 && (err == nil || !strings.Contains(err.Error(), test.want)) {
+				t.Fatalf("Check() = %v, want error with %q", err, test.want)
+			}
+
+			if downloads > 0 {
+				t.Errorf("object is downloaded %d times, want none", downloads)
+			}
+		})
+	}
+}
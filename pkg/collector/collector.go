@@ -11,15 +11,29 @@ import (
 	"time"
 )
 
+// DeploymentRecord is the deployment information stamped in metric storage
+type DeploymentRecord struct {
+	Identifier string
+	Status     string
+	Stack      builder.Stack
+	Config     builder.Config
+	Userdata   string
+}
+
 type Collector struct {
 	MetricConfig builder.MetricConfig
 	MetricClient aws.MetricClient
 }
 
 func NewCollector(mc builder.MetricConfig, assumeRole string) Collector {
+	return NewCollectorWithBootstrap(mc, assumeRole, aws.BootstrapMetricService)
+}
+
+// NewCollectorWithBootstrap creates a collector of which metric client is created by the bootstrapper
+func NewCollectorWithBootstrap(mc builder.MetricConfig, assumeRole string, bootstrap aws.MetricBootstrapper) Collector {
 	return Collector{
 		MetricConfig: mc,
-		MetricClient: aws.BootstrapMetricService(mc.Region, assumeRole),
+		MetricClient: bootstrap(mc.Region, assumeRole),
 	}
 }
 
@@ -35,6 +49,19 @@ func (c Collector) CheckStorage(logger *Logger.Logger) error {
 
 		if isExist {
 			logger.Infof("you already had a table : %s", c.MetricConfig.Storage.Name)
+
+			// Tables created by older versions do not have the index of deployment history
+			hasIndex, err := c.MetricClient.DynamoDBService.CheckIndexExists(c.MetricConfig.Storage.Name, aws.HISTORY_INDEX_NAME)
+			if err != nil {
+				return err
+			}
+
+			if !hasIndex {
+				if err := c.MetricClient.DynamoDBService.CreateHistoryIndex(c.MetricConfig.Storage.Name); err != nil {
+					return err
+				}
+				logger.Infof("index of deployment history is being created : %s", aws.HISTORY_INDEX_NAME)
+			}
 		} else {
 			logger.Infof("you don't have a table : %s", c.MetricConfig.Storage.Name)
 			if err := c.MetricClient.DynamoDBService.CreateTable(c.MetricConfig.Storage.Name); err != nil {
@@ -73,7 +100,23 @@ func (c Collector) StampDeployment(stack builder.Stack, config builder.Config, t
 	}
 	configString := string(configJson)
 
-	if err := c.MetricClient.DynamoDBService.MakeRecord(stackString, configString, tagString, asg, c.MetricConfig.Storage.Name, status, additionalFields); err != nil {
+	// Fields with which deployment history is looked up
+	fields := map[string]string{
+		"stack_name":  stack.Stack,
+		"env":         stack.Env,
+		"deployed_by": DeploymentUser(),
+	}
+
+	// Key of the history index cannot be empty
+	if app := tagsMap["app"]; len(app) > 0 {
+		fields["app"] = app
+	}
+
+	for k, v := range additionalFields {
+		fields[k] = v
+	}
+
+	if err := c.MetricClient.DynamoDBService.MakeRecord(stackString, configString, tagString, asg, c.MetricConfig.Storage.Name, status, fields); err != nil {
 		return err
 	}
 
@@ -109,3 +152,39 @@ func (c Collector) GetAdditionalMetric(asg string) (map[string]string, error) {
 
 	return ret, nil
 }
+
+// GetDeploymentRecord returns the stamped deployment of the autoscaling group
+// If there is no record, then nil is returned.
+func (c Collector) GetDeploymentRecord(asg string) (*DeploymentRecord, error) {
+	item, err := c.MetricClient.DynamoDBService.GetSingleItem(asg, c.MetricConfig.Storage.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(item) == 0 {
+		return nil, nil
+	}
+
+	record := DeploymentRecord{Identifier: asg}
+	if v, ok := item["deployment_status"]; ok && v.S != nil {
+		record.Status = *v.S
+	}
+
+	if v, ok := item["userdata"]; ok && v.S != nil {
+		record.Userdata = *v.S
+	}
+
+	if v, ok := item["stack"]; ok && v.S != nil {
+		if err := json.Unmarshal([]byte(*v.S), &record.Stack); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := item["config"]; ok && v.S != nil {
+		if err := json.Unmarshal([]byte(*v.S), &record.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"time"
+)
+
+var (
+	// Timestamps at which deployment finishes with each status
+	finishedTimestampKeys = []string{"deployed_date_kst", "rolled_back_date_kst", "abandoned_date_kst"}
+)
+
+// HistoryRecord is a past deployment of an autoscaling group
+// Duration is empty if the deployment has not finished yet.
+type HistoryRecord struct {
+	Identifier   string     `json:"identifier"`
+	App          string     `json:"app"`
+	Stack        string     `json:"stack"`
+	Env          string     `json:"env"`
+	Region       string     `json:"region,omitempty"`
+	Status       string     `json:"status"`
+	Ami          string     `json:"ami,omitempty"`
+	ReleaseNotes string     `json:"release_notes,omitempty"`
+	DeployedBy   string     `json:"deployed_by,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	Duration     string     `json:"duration,omitempty"`
+}
+
+// HistoryQuery selects deployments of the application
+// Empty stacks or region mean every stack or region, and zero time means the range is open on that side.
+type HistoryQuery struct {
+	App    string
+	Stacks []string
+	Region string
+	Since  time.Time
+	Until  time.Time
+}
+
+// GetHistory returns deployments which match the query, latest first
+// Deployments stamped before the history index was created are not included.
+func (c Collector) GetHistory(query HistoryQuery) ([]HistoryRecord, error) {
+	hasIndex, err := c.MetricClient.DynamoDBService.CheckIndexExists(c.MetricConfig.Storage.Name, aws.HISTORY_INDEX_NAME)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasIndex {
+		return nil, fmt.Errorf("%s has no index of deployment history, it is created at the next deployment : %s", c.MetricConfig.Storage.Name, aws.HISTORY_INDEX_NAME)
+	}
+
+	items, err := c.MetricClient.DynamoDBService.QueryHistory(query.App, kstTimestamp(query.Since), kstTimestamp(query.Until), c.MetricConfig.Storage.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []HistoryRecord{}
+	for _, item := range items {
+		record, err := makeHistoryRecord(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(query.Stacks) > 0 && !tool.IsStringInArray(record.Stack, query.Stacks) {
+			continue
+		}
+
+		if len(query.Region) > 0 && record.Region != query.Region {
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// makeHistoryRecord converts the item of metrics table to the record
+func makeHistoryRecord(item map[string]*dynamodb.AttributeValue) (HistoryRecord, error) {
+	value := func(key string) string {
+		if v, ok := item[key]; ok && v.S != nil {
+			return *v.S
+		}
+		return ""
+	}
+
+	record := HistoryRecord{
+		Identifier:   value("identifier"),
+		App:          value("app"),
+		Stack:        value("stack_name"),
+		Env:          value("env"),
+		Region:       value("region"),
+		Status:       value("deployment_status"),
+		Ami:          value("ami"),
+		ReleaseNotes: value("release-notes"),
+		DeployedBy:   value("deployed_by"),
+	}
+
+	if encoded := value("release-notes-base64"); len(record.ReleaseNotes) == 0 && len(encoded) > 0 {
+		notes, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return record, fmt.Errorf("release notes of %s are not encoded with base64 : %s", record.Identifier, err.Error())
+		}
+		record.ReleaseNotes = string(notes)
+	}
+
+	started, err := time.Parse(time.RFC3339, value("start_date_kst"))
+	if err != nil {
+		return record, fmt.Errorf("wrong start date of %s : %s", record.Identifier, err.Error())
+	}
+	record.StartedAt = started
+
+	for _, key := range finishedTimestampKeys {
+		if len(value(key)) == 0 {
+			continue
+		}
+
+		finished, err := time.Parse(time.RFC3339, value(key))
+		if err != nil {
+			return record, fmt.Errorf("wrong %s of %s : %s", key, record.Identifier, err.Error())
+		}
+
+		record.FinishedAt = &finished
+		record.Duration = finished.Sub(started).Round(time.Second).String()
+		break
+	}
+
+	return record, nil
+}
+
+// kstTimestamp formats the time like timestamps of the metrics table
+func kstTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.In(builder.KstLocation()).Format(time.RFC3339)
+}
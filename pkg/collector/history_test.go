@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	sdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// historyItem makes an item of metrics table from string attributes
+func historyItem(attributes map[string]string) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{}
+	for k, v := range attributes {
+		item[k] = &dynamodb.AttributeValue{S: sdk.String(v)}
+	}
+	return item
+}
+
+func TestMakeHistoryRecord(t *testing.T) {
+	base := map[string]string{
+		"identifier":        "hello-dev_apnortheast2-v001",
+		"app":               "hello",
+		"stack_name":        "artd",
+		"env":               "dev",
+		"region":            "ap-northeast-2",
+		"deployment_status": "deployed",
+		"start_date_kst":    "2020-07-01T10:00:00+09:00",
+	}
+
+	with := func(attributes map[string]string) map[string]string {
+		merged := map[string]string{}
+		for k, v := range base {
+			merged[k] = v
+		}
+		for k, v := range attributes {
+			merged[k] = v
+		}
+		return merged
+	}
+
+	tests := []struct {
+		name         string
+		attributes   map[string]string
+		duration     string
+		releaseNotes string
+		err          string
+	}{
+		{
+			name:       "deployment in progress",
+			attributes: base,
+		},
+		{
+			name:       "deployed",
+			attributes: with(map[string]string{"deployed_date_kst": "2020-07-01T10:05:30+09:00"}),
+			duration:   "5m30s",
+		},
+		{
+			name:       "rolled back",
+			attributes: with(map[string]string{"rolled_back_date_kst": "2020-07-01T10:01:00+09:00"}),
+			duration:   "1m0s",
+		},
+		{
+			name:         "release notes",
+			attributes:   with(map[string]string{"release-notes": "fix bug\nsecond line"}),
+			releaseNotes: "fix bug\nsecond line",
+		},
+		{
+			name:         "release notes encoded with base64",
+			attributes:   with(map[string]string{"release-notes-base64": base64.StdEncoding.EncodeToString([]byte("encoded notes"))}),
+			releaseNotes: "encoded notes",
+		},
+		{
+			name:       "broken release notes",
+			attributes: with(map[string]string{"release-notes-base64": "%%%"}),
+			err:        "not encoded with base64",
+		},
+		{
+			name:       "wrong start date",
+			attributes: with(map[string]string{"start_date_kst": "2020-07-01"}),
+			err:        "wrong start date of hello-dev_apnortheast2-v001",
+		},
+		{
+			name:       "wrong finished date",
+			attributes: with(map[string]string{"deployed_date_kst": "yesterday"}),
+			err:        "wrong deployed_date_kst of hello-dev_apnortheast2-v001",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			record, err := makeHistoryRecord(historyItem(test.attributes))
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("makeHistoryRecord() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("makeHistoryRecord() error = %v", err)
+			}
+
+			if record.Identifier != base["identifier"] || record.Stack != "artd" || record.Status != "deployed" || record.Region != "ap-northeast-2" {
+				t.Errorf("makeHistoryRecord() = %+v, want fields of the item", record)
+			}
+
+			if record.Duration != test.duration {
+				t.Errorf("duration = %q, want %q", record.Duration, test.duration)
+			}
+
+			if (record.FinishedAt != nil) != (len(test.duration) > 0) {
+				t.Errorf("finished at = %v, want it only if the deployment finished", record.FinishedAt)
+			}
+
+			if record.ReleaseNotes != test.releaseNotes {
+				t.Errorf("release notes = %q, want %q", record.ReleaseNotes, test.releaseNotes)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	Logger "github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+var (
+	LOCK_KEY_PREFIX       = "lock:"
+	DEFAULT_LOCK_TTL      = 2 * time.Minute
+	DEFAULT_LOCK_INTERVAL = 10 * time.Second
+)
+
+// Lock is a deployment lock of autoscaling groups which have the same prefix
+// It is renewed in background until it is released.
+type Lock struct {
+	Prefix string
+	Holder string
+	ttl    time.Duration
+	stop   chan struct{}
+	lost   chan struct{}
+	err    error
+}
+
+// Lost returns a channel which is closed when the lock cannot be renewed before it expires
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns the reason why the lock is lost
+func (l *Lock) Err() error {
+	select {
+	case <-l.lost:
+		return l.err
+	default:
+		return nil
+	}
+}
+
+// LockedError is returned when other process holds the lock
+type LockedError struct {
+	Prefix string
+	Holder string
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("deployment of %s is locked by %s, run `goployer unlock` if the lock is left by a dead process", e.Prefix, e.Holder)
+}
+
+// NewLockHolder returns the description of this process which is stored in the lock
+func NewLockHolder() string {
+	return fmt.Sprintf("%s(pid:%d, started:%s)", DeploymentUser(), os.Getpid(), time.Now().Format(time.RFC3339))
+}
+
+// DeploymentUser returns the user and host who runs goployer like ubuntu@ip-10-0-0-1
+func DeploymentUser() string {
+	host, _ := os.Hostname()
+	user := os.Getenv("USER")
+	if len(user) == 0 {
+		user = "unknown"
+	}
+
+	return fmt.Sprintf("%s@%s", user, host)
+}
+
+// lockKey returns the identifier of lock item in the metric table
+func lockKey(prefix string) string {
+	return LOCK_KEY_PREFIX + prefix
+}
+
+// AcquireLock takes the deployment lock of the prefix
+// If other process holds the lock, it retries until wait duration passes.
+func (c Collector) AcquireLock(ctx context.Context, prefix, holder string, wait time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		ttl := DEFAULT_LOCK_TTL
+		ok, err := c.MetricClient.DynamoDBService.AcquireLock(lockKey(prefix), holder, c.MetricConfig.Storage.Name, ttl)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			lock := &Lock{Prefix: prefix, Holder: holder, ttl: ttl, stop: make(chan struct{}), lost: make(chan struct{})}
+			go c.heartbeat(lock)
+			return lock, nil
+		}
+
+		current, err := c.GetLockHolder(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			return nil, &LockedError{Prefix: prefix, Holder: current}
+		}
+
+		Logger.Infof("waiting for the lock of %s held by %s", prefix, current)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(DEFAULT_LOCK_INTERVAL):
+		}
+	}
+}
+
+// heartbeat renews the lock so that it is not expired while deployment is running
+// A failure is retried at the next tick, but if the lock would expire before that, the lock is lost
+// so that deployment stops before other process takes the lock.
+func (c Collector) heartbeat(lock *Lock) {
+	interval := lock.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			err := c.MetricClient.DynamoDBService.RenewLock(lockKey(lock.Prefix), lock.Holder, c.MetricConfig.Storage.Name, lock.ttl)
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+
+			if time.Since(renewed)+interval < lock.ttl {
+				Logger.Warnf("failed to renew the lock of %s, retrying : %s", lock.Prefix, err.Error())
+				continue
+			}
+
+			lock.err = fmt.Errorf("failed to renew the lock of %s : %s", lock.Prefix, err.Error())
+			close(lock.lost)
+			return
+		}
+	}
+}
+
+// ReleaseLock stops renewing the lock and deletes it
+func (c Collector) ReleaseLock(lock *Lock) error {
+	close(lock.stop)
+	return c.MetricClient.DynamoDBService.ReleaseLock(lockKey(lock.Prefix), lock.Holder, c.MetricConfig.Storage.Name)
+}
+
+// ForceReleaseLock deletes the lock of the prefix regardless of the holder
+func (c Collector) ForceReleaseLock(prefix string) error {
+	return c.MetricClient.DynamoDBService.ReleaseLock(lockKey(prefix), "", c.MetricConfig.Storage.Name)
+}
+
+// GetLockHolder returns the holder of the lock
+// If nobody holds the lock, then empty string is returned.
+func (c Collector) GetLockHolder(prefix string) (string, error) {
+	item, err := c.MetricClient.DynamoDBService.GetSingleItem(lockKey(prefix), c.MetricConfig.Storage.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if v, ok := item["lock_holder"]; ok && v.S != nil {
+		return *v.S, nil
+	}
+
+	return "", nil
+}
@@ -1,6 +1,7 @@
 package deployer
 
 import (
+	"context"
 	"fmt"
 	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
@@ -13,311 +14,310 @@ type BlueGreen struct {
 	Deployer
 }
 
-func NewBlueGrean(mode string, logger *Logger.Logger, awsConfig builder.AWSConfig, stack builder.Stack) BlueGreen {
-	awsClients := []aws.AWSClient{}
-	for _, region := range stack.Regions {
-		awsClients = append(awsClients, aws.BootstrapServices(region.Region, stack.AssumeRole))
-	}
+func NewBlueGrean(mode string, logger *Logger.Logger, awsConfig builder.AWSConfig, stack builder.Stack, awsClients []aws.AWSClient) BlueGreen {
 	return BlueGreen{
 		Deployer{
-			Mode:          mode,
-			Logger:        logger,
-			AwsConfig:     awsConfig,
-			AWSClients:    awsClients,
-			AsgNames:      map[string]string{},
-			PrevAsgs:      map[string][]string{},
-			PrevInstances: map[string][]string{},
-			Stack:         stack,
+			Mode:           mode,
+			Logger:         logger,
+			AwsConfig:      awsConfig,
+			AWSClients:     awsClients,
+			AsgNames:       map[string]string{},
+			PrevAsgs:       map[string][]string{},
+			PrevInstances:  map[string][]string{},
+			PrevCapacity:   map[string]builder.Capacity{},
+			TargetCapacity: map[string]builder.Capacity{},
+			Traffic:        map[string]*TrafficState{},
+			Stack:          stack,
+			status:         newRegionStatus(),
 		},
 	}
 }
 
-// Deploy function
-func (b BlueGreen) Deploy(config builder.Config) {
+// Deploy creates a new version in every region of the stack
+// Regions are deployed at the same time and failures are handled with the region failure policy.
+func (b BlueGreen) Deploy(ctx context.Context, config builder.Config) error {
+	return b.deployWithRollback(ctx, config, func(region builder.RegionConfig) error {
+		return b.rollbackRegion(config, region)
+	})
+}
+
+// deployWithRollback creates a new version in every region of the stack
+// With the continue policy, failed regions are rolled back alone with rollback.
+func (b BlueGreen) deployWithRollback(ctx context.Context, config builder.Config, rollback func(region builder.RegionConfig) error) error {
 	b.Logger.Info("Deploy Mode is " + b.Mode)
 
 	//Get LocalFileProvider
-	b.LocalProvider = builder.SetUserdataProvider(b.Stack.Userdata, b.AwsConfig.Userdata)
-
-	// Make Frigga
-	frigga := tool.Frigga{}
-	for _, region := range b.Stack.Regions {
-		//Region check
-		//If region id is passed from command line, then deployer will deploy in that region only.
-		if config.Region != "" && config.Region != region.Region {
-			b.Logger.Debug("This region is skipped by user : " + region.Region)
-			continue
-		}
+	if b.LocalProvider == nil {
+		b.LocalProvider = builder.SetUserdataProvider(b.Stack.Userdata, b.AwsConfig.Userdata)
+	}
 
-		//Setup frigga with prefix
-		frigga.Prefix = tool.BuildPrefixName(b.AwsConfig.Name, b.Stack.Env, region.Region)
+	return b.deployRegions(ctx, config, func(ctx context.Context, region builder.RegionConfig) error {
+		return b.deployRegion(ctx, config, region)
+	}, rollback)
+}
 
-		//select client
-		client, err := selectClientFromList(b.AWSClients, region.Region)
-		if err != nil {
-			tool.ErrorLogging(err.Error())
-		}
+// deployRegion creates a new autoscaling group in the region
+func (b BlueGreen) deployRegion(ctx context.Context, config builder.Config, region builder.RegionConfig) error {
+	if err := b.checkInterrupted(ctx, region.Region, "deploy"); err != nil {
+		return err
+	}
 
-		// Get All Autoscaling Groups
-		asgGroups := client.EC2Service.GetAllMatchingAutoscalingGroupsWithPrefix(frigga.Prefix)
-
-		//Get All Previous Autoscaling Groups and versions
-		prevAsgs := []string{}
-		prevInstanceIds := []string{}
-		prevVersions := []int{}
-		var prevInstanceCount builder.Capacity
-		for _, asgGroup := range asgGroups {
-			prevAsgs = append(prevAsgs, *asgGroup.AutoScalingGroupName)
-			prevVersions = append(prevVersions, tool.ParseVersion(*asgGroup.AutoScalingGroupName))
-			for _, instance := range asgGroup.Instances {
-				prevInstanceIds = append(prevInstanceIds, *instance.InstanceId)
-			}
+	// Make Frigga with prefix
+	frigga := tool.Frigga{
+		Prefix: tool.BuildPrefixName(b.AwsConfig.Name, b.Stack.Env, region.Region),
+	}
+
+	//select client
+	client, err := selectClientFromList(b.AWSClients, region.Region)
+	if err != nil {
+		return b.stepError(region.Region, "deploy", err)
+	}
+
+	// Get All Autoscaling Groups
+	asgGroups, err := client.EC2Service.GetAllMatchingAutoscalingGroupsWithPrefix(frigga.Prefix)
+	if err != nil {
+		return b.stepError(region.Region, "deploy", err)
+	}
 
-			prevInstanceCount.Desired = *asgGroup.DesiredCapacity
-			prevInstanceCount.Max = *asgGroup.MaxSize
-			prevInstanceCount.Min = *asgGroup.MinSize
+	//Get All Previous Autoscaling Groups and versions
+	prevAsgs := []string{}
+	prevInstanceIds := []string{}
+	prevVersions := []int{}
+	prevCapacity := map[string]builder.Capacity{}
+	var prevInstanceCount builder.Capacity
+	for _, asgGroup := range asgGroups {
+		prevAsgs = append(prevAsgs, *asgGroup.AutoScalingGroupName)
+		prevVersions = append(prevVersions, tool.ParseVersion(*asgGroup.AutoScalingGroupName))
+		for _, instance := range asgGroup.Instances {
+			prevInstanceIds = append(prevInstanceIds, *instance.InstanceId)
 		}
-		b.Logger.Info("Previous Versions : ", strings.Join(prevAsgs, " | "))
 
-		// Get Current Version
-		curVersion := getCurrentVersion(prevVersions)
-		b.Logger.Info("Current Version :", curVersion)
+		prevInstanceCount.Desired = *asgGroup.DesiredCapacity
+		prevInstanceCount.Max = *asgGroup.MaxSize
+		prevInstanceCount.Min = *asgGroup.MinSize
 
-		//Get AMI
-		var ami string
-		if len(config.Ami) > 0 {
-			ami = config.Ami
-		} else {
-			ami = region.AmiId
+		prevCapacity[*asgGroup.AutoScalingGroupName] = prevInstanceCount
+	}
+
+	b.withLock(func() {
+		for asg, capacity := range prevCapacity {
+			b.PrevCapacity[asg] = capacity
 		}
+	})
 
-		// Generate new name for autoscaling group and launch configuration
-		new_asg_name := tool.GenerateAsgName(frigga.Prefix, curVersion)
-		launch_template_name := tool.GenerateLcName(new_asg_name)
+	b.Logger.Info("Previous Versions : ", strings.Join(prevAsgs, " | "))
 
-		userdata := (b.LocalProvider).Provide()
+	// Get Current Version
+	curVersion := getCurrentVersion(prevVersions)
+	b.Logger.Info("Current Version :", curVersion)
 
-		//Stack check
-		securityGroups := client.EC2Service.GetSecurityGroupList(region.VPC, region.SecurityGroups)
-		blockDevices := client.EC2Service.MakeLaunchTemplateBlockDeviceMappings(b.Stack.BlockDevices)
-		ebsOptimized := b.Stack.EbsOptimized
+	// Generate new name for autoscaling group and launch configuration
+	new_asg_name := tool.GenerateAsgName(frigga.Prefix, curVersion)
+	launch_template_name := tool.GenerateLcName(new_asg_name)
 
-		// Instance Type Override
-		instanceType := region.InstanceType
-		if len(config.OverrideInstanceType) > 0 {
-			instanceType = config.OverrideInstanceType
+	// The resolved AMI is stamped so that rollback recreates the launch template with the same image
+	config.Ami, err = b.ResolveAmi(client, region, config)
+	if err != nil {
+		return b.stepError(region.Region, "deploy", err)
+	}
 
-			if b.Stack.MixedInstancesPolicy.Enabled {
-				Logger.Warnf("--override-instance-type won't be applied because mixed_instances_policy is enabled")
-			}
-		}
+	// LaunchTemplate
+	ami, userdata, err := b.Deployer.CreateLaunchTemplate(client, region, config, new_asg_name, launch_template_name)
+	if err != nil {
+		return b.stepError(region.Region, "deploy", err)
+	}
 
-		// LaunchTemplate
-		ret := client.EC2Service.CreateNewLaunchTemplate(
-			launch_template_name,
-			ami,
-			instanceType,
-			region.SshKey,
-			b.Stack.IamInstanceProfile,
-			userdata,
-			ebsOptimized,
-			b.Stack.MixedInstancesPolicy.Enabled,
-			securityGroups,
-			blockDevices,
-			b.Stack.InstanceMarketOptions,
-		)
-
-		if !ret {
-			tool.ErrorLogging("Unknown error happened creating new launch template.")
-		}
+	// Rollback deletes the launch template even if the autoscaling group is not created
+	b.withLock(func() {
+		b.AsgNames[region.Region] = new_asg_name
+	})
 
-		healthElb := region.HealthcheckLB
-		loadbalancers := region.LoadBalancers
-		if !tool.IsStringInArray(healthElb, loadbalancers) {
-			loadbalancers = append(loadbalancers, healthElb)
-		}
+	healthElb := region.HealthcheckLB
+	loadbalancers := region.LoadBalancers
+	if !tool.IsStringInArray(healthElb, loadbalancers) {
+		loadbalancers = append(loadbalancers, healthElb)
+	}
 
-		healthcheckTargetGroups := region.HealthcheckTargetGroup
-		targetGroups := region.TargetGroups
-		if !tool.IsStringInArray(healthcheckTargetGroups, targetGroups) {
-			targetGroups = append(targetGroups, healthcheckTargetGroups)
+	healthcheckTargetGroups := region.HealthcheckTargetGroup
+	targetGroups := region.TargetGroups
+	if b.Stack.TrafficShifting.Enabled {
+		// New version is attached only to the target group which is not receiving traffic
+		state, err := b.Deployer.PrepareTrafficShifting(client, region)
+		if err != nil {
+			return b.stepError(region.Region, "deploy", err)
 		}
+		b.withLock(func() {
+			b.Traffic[region.Region] = state
+		})
 
-		usePublicSubnets := region.UsePublicSubnets
-		healthcheckType := aws.DEFAULT_HEALTHCHECK_TYPE
-		healthcheckGracePeriod := int64(aws.DEFAULT_HEALTHCHECK_GRACE_PERIOD)
-		terminationPolicies := []*string{}
-		availabilityZones := client.EC2Service.GetAvailabilityZones(region.VPC, region.AvailabilityZones)
-		targetGroupArns := client.ELBService.GetTargetGroupARNs(targetGroups)
-		tags := client.EC2Service.GenerateTags(b.AwsConfig.Tags, new_asg_name, b.AwsConfig.Name, config.Stack, b.Stack.AnsibleTags, config.ExtraTags, config.AnsibleExtraVars, region.Region)
-		subnets := client.EC2Service.GetSubnets(region.VPC, usePublicSubnets, availabilityZones)
-		lifecycleHooksSpecificationList := client.EC2Service.GenerateLifecycleHooks(b.Stack.LifecycleHooks)
-
-		var appliedCapacity builder.Capacity
-		if !config.ForceManifestCapacity && prevInstanceCount.Desired > b.Stack.Capacity.Desired {
-			appliedCapacity = prevInstanceCount
-			b.Logger.Infof("Current desired instance count is larger than the number of instances in manifest file")
-		} else {
-			appliedCapacity = b.Stack.Capacity
+		healthcheckTargetGroups = state.NewTargetGroupName
+		targetGroups = []string{}
+		for _, tg := range region.TargetGroups {
+			if !tool.IsStringInArray(tg, region.WeightedTargetGroups) {
+				targetGroups = append(targetGroups, tg)
+			}
 		}
+	}
 
-		b.Logger.Infof("Applied instance capacity - Min: %d, Desired: %d, Max: %d", appliedCapacity.Max, appliedCapacity.Desired, appliedCapacity.Max)
-
-		ret = client.EC2Service.CreateAutoScalingGroup(
-			new_asg_name,
-			launch_template_name,
-			healthcheckType,
-			healthcheckGracePeriod,
-			appliedCapacity,
-			aws.MakeStringArrayToAwsStrings(loadbalancers),
-			targetGroupArns,
-			terminationPolicies,
-			aws.MakeStringArrayToAwsStrings(availabilityZones),
-			tags,
-			subnets,
-			b.Stack.MixedInstancesPolicy,
-			lifecycleHooksSpecificationList,
-		)
-
-		if !ret {
-			tool.ErrorLogging("Unknown error happened creating new autoscaling group.")
-		}
+	if !tool.IsStringInArray(healthcheckTargetGroups, targetGroups) {
+		targetGroups = append(targetGroups, healthcheckTargetGroups)
+	}
 
-		b.AsgNames[region.Region] = new_asg_name
-		b.PrevAsgs[region.Region] = prevAsgs
-		b.PrevInstances[region.Region] = prevInstanceIds
+	usePublicSubnets := region.UsePublicSubnets
+	healthcheckType := aws.DEFAULT_HEALTHCHECK_TYPE
+	healthcheckGracePeriod := int64(aws.DEFAULT_HEALTHCHECK_GRACE_PERIOD)
+	terminationPolicies := []*string{}
+	availabilityZones, err := client.EC2Service.GetAvailabilityZones(region.VPC, region.AvailabilityZones)
+	if err != nil {
+		return b.stepError(region.Region, "deploy", err)
+	}
 
-		if b.Collector.MetricConfig.Enabled {
-			additionalFields := map[string]string{}
-			if len(config.ReleaseNotes) > 0 {
-				additionalFields["release-notes"] = config.ReleaseNotes
-			}
+	targetGroupArns, err := client.ELBService.GetTargetGroupARNs(targetGroups)
+	if err != nil {
+		return b.stepError(region.Region, "deploy", err)
+	}
 
-			if len(config.ReleaseNotesBase64) > 0 {
-				additionalFields["release-notes-base64"] = config.ReleaseNotesBase64
-			}
+	tags := client.EC2Service.GenerateTags(b.AwsConfig.Tags, new_asg_name, b.AwsConfig.Name, b.Stack.Stack, b.Stack.AnsibleTags, config.ExtraTags, config.AnsibleExtraVars, region.Region)
+	subnets, err := client.EC2Service.GetSubnets(region.VPC, usePublicSubnets, availabilityZones)
+	if err != nil {
+		return b.stepError(region.Region, "deploy", err)
+	}
 
-			if len(userdata) > 0 {
-				additionalFields["userdata"] = userdata
-			}
+	lifecycleHooksSpecificationList := client.EC2Service.GenerateLifecycleHooks(b.Stack.LifecycleHooks)
 
-			b.Stack.Capacity = appliedCapacity
-			b.Collector.StampDeployment(b.Stack, config, tags, new_asg_name, "creating", additionalFields)
-		}
+	var appliedCapacity builder.Capacity
+	if !config.ForceManifestCapacity && prevInstanceCount.Desired > b.Stack.Capacity.Desired {
+		appliedCapacity = prevInstanceCount
+		b.Logger.Infof("Current desired instance count is larger than the number of instances in manifest file")
+	} else {
+		appliedCapacity = b.Stack.Capacity
 	}
-}
 
-// Healthchecking
-func (b BlueGreen) HealthChecking(config builder.Config) map[string]bool {
-	stack_name := b.GetStackName()
-	Logger.Debug(fmt.Sprintf("Healthchecking for stack starts : %s", stack_name))
-	finished := []string{}
+	b.withLock(func() {
+		b.TargetCapacity[region.Region] = appliedCapacity
+	})
 
-	//Valid Count
-	validCount := 1
-	if config.Region == "" {
-		validCount = len(b.Stack.Regions)
+	// Canary starts with the capacity of the first stage
+	// appliedCapacity is kept as the target so that the deployment record has the capacity of the whole fleet.
+	launchCapacity := appliedCapacity
+	if b.Mode == builder.REPLACEMENT_TYPE_CANARY {
+		launchCapacity = b.Stack.Canary.GetStageCapacity(0, appliedCapacity)
+		b.Logger.Infof("Canary starts with %d%% of capacity", b.Stack.Canary.GetStages()[0])
 	}
 
-	if len(config.Region) > 0 {
-		if !checkRegionExist(config.Region, b.Stack.Regions) {
-			validCount = 0
-		}
+	b.Logger.Infof("Applied instance capacity - Min: %d, Desired: %d, Max: %d", launchCapacity.Min, launchCapacity.Desired, launchCapacity.Max)
+
+	err = client.EC2Service.CreateAutoScalingGroup(
+		new_asg_name,
+		launch_template_name,
+		healthcheckType,
+		healthcheckGracePeriod,
+		launchCapacity,
+		aws.MakeStringArrayToAwsStrings(loadbalancers),
+		targetGroupArns,
+		terminationPolicies,
+		aws.MakeStringArrayToAwsStrings(availabilityZones),
+		tags,
+		subnets,
+		b.Stack.MixedInstancesPolicy,
+		lifecycleHooksSpecificationList,
+	)
+
+	if err != nil {
+		return b.stepError(region.Region, "deploy", err)
 	}
 
-	for _, region := range b.Stack.Regions {
-		//Region check
-		//If region id is passed from command line, then deployer will deploy in that region only.
-		if config.Region != "" && config.Region != region.Region {
-			b.Logger.Debug("This region is skipped by user : " + region.Region)
-			continue
-		}
-
-		b.Logger.Debug("Healthchecking for region starts : " + region.Region)
+	b.withLock(func() {
+		b.PrevAsgs[region.Region] = prevAsgs
+		b.PrevInstances[region.Region] = prevInstanceIds
+	})
 
-		//select client
-		client, err := selectClientFromList(b.AWSClients, region.Region)
-		if err != nil {
-			tool.ErrorLogging(err.Error())
+	if b.Collector.MetricConfig.Enabled {
+		additionalFields := map[string]string{}
+		if len(config.ReleaseNotes) > 0 {
+			additionalFields["release-notes"] = config.ReleaseNotes
 		}
 
-		asg := client.EC2Service.GetMatchingAutoscalingGroup(b.AsgNames[region.Region])
-
-		isHealthy := b.Deployer.polling(region, asg, client)
+		if len(config.ReleaseNotesBase64) > 0 {
+			additionalFields["release-notes-base64"] = config.ReleaseNotesBase64
+		}
 
-		if isHealthy {
-			if b.Collector.MetricConfig.Enabled {
-				if err := b.Collector.UpdateStatus(*asg.AutoScalingGroupName, "deployed", nil); err != nil {
-					Logger.Errorf("Update status Error, %s : %s", err.Error(), *asg.AutoScalingGroupName)
-				}
-			}
-			finished = append(finished, region.Region)
+		if len(userdata) > 0 {
+			additionalFields["userdata"] = userdata
 		}
-	}
+		additionalFields["region"] = region.Region
+		additionalFields["ami"] = ami
 
-	if len(finished) == validCount {
-		return map[string]bool{stack_name: true}
+		// Other regions are deployed with the same stack at the same time
+		// Target capacity is stamped even if canary starts with smaller capacity.
+		stack := b.Stack
+		stack.Capacity = appliedCapacity
+		if err := b.Collector.StampDeployment(stack, config, tags, new_asg_name, "creating", additionalFields); err != nil {
+			b.Logger.Errorf("Stamp deployment Error, %s : %s", err.Error(), new_asg_name)
+		}
 	}
 
-	return map[string]bool{stack_name: false}
+	return nil
 }
 
-//Stack Name Getter
-func (b BlueGreen) GetStackName() string {
-	return b.Stack.Stack
+// Healthchecking
+// With the continue policy, regions of which healthchecking fails are rolled back alone.
+func (b BlueGreen) HealthChecking(ctx context.Context, config builder.Config) (map[string]bool, error) {
+	stack_name := b.GetStackName()
+	Logger.Debug(fmt.Sprintf("Healthchecking for stack starts : %s", stack_name))
+
+	healthy, err := b.checkRegions(ctx, config, func(region builder.RegionConfig) (bool, error) {
+		return b.checkRegion(config, region)
+	}, func(region builder.RegionConfig) error {
+		return b.rollbackRegion(config, region)
+	})
+
+	return map[string]bool{stack_name: healthy}, err
 }
 
-//BlueGreen finish final work
-func (b BlueGreen) FinishAdditionalWork(config builder.Config) error {
-	if len(b.Stack.Autoscaling) == 0 {
-		b.Logger.Debug("No scaling policy exists")
-		return nil
+// checkRegion checks if the new version is healthy in the region and shifts traffic to it
+func (b BlueGreen) checkRegion(config builder.Config, region builder.RegionConfig) (bool, error) {
+	//select client
+	client, err := selectClientFromList(b.AWSClients, region.Region)
+	if err != nil {
+		return false, err
 	}
 
-	if len(config.Region) > 0 && !checkRegionExist(config.Region, b.Stack.Regions) {
-		return nil
+	asg, err := client.EC2Service.GetMatchingAutoscalingGroup(b.AsgNames[region.Region])
+	if err != nil {
+		return false, err
 	}
 
-	//Apply Autosacling Policies
-	for _, region := range b.Stack.Regions {
-		//If region id is passed from command line, then deployer will deploy in that region only.
-		if config.Region != "" && config.Region != region.Region {
-			b.Logger.Debug("This region is skipped by user : " + region.Region)
-			continue
-		}
-
-		b.Logger.Info("Attaching autoscaling policies : " + region.Region)
+	if asg == nil {
+		return false, fmt.Errorf("no autoscaling found for %s", b.AsgNames[region.Region])
+	}
 
-		//select client
-		client, err := selectClientFromList(b.AWSClients, region.Region)
-		if err != nil {
-			tool.ErrorLogging(err.Error())
-		}
+	state, shifting := b.Traffic[region.Region]
+	if shifting {
+		region.HealthcheckTargetGroup = state.NewTargetGroupName
+	}
 
-		//putting autoscaling group policies
-		policies := []string{}
-		policyArns := map[string]string{}
-		for _, policy := range b.Stack.Autoscaling {
-			policyArn, err := client.EC2Service.CreateScalingPolicy(policy, b.AsgNames[region.Region])
-			if err != nil {
-				tool.ErrorLogging(err.Error())
-				return err
-			}
-			policyArns[policy.Name] = *policyArn
-			policies = append(policies, policy.Name)
-		}
+	// The new version is healthy only when it has the whole capacity including instances inherited from the previous version
+	isHealthy, err := b.Deployer.polling(region, asg, client, b.TargetCapacity[region.Region].Desired)
+	if err != nil {
+		return false, err
+	}
 
-		if err := client.EC2Service.EnableMetrics(b.AsgNames[region.Region]); err != nil {
-			return err
+	// Traffic is shifted step by step only while new instances are healthy
+	if isHealthy && shifting {
+		isHealthy, err = b.Deployer.ShiftTraffic(client, region.Region, config.Env)
+		if err != nil {
+			return false, err
 		}
+	}
 
-		if err := client.CloudWatchService.CreateScalingAlarms(b.AsgNames[region.Region], b.Stack.Alarms, policyArns); err != nil {
-			return nil
+	if isHealthy && b.Collector.MetricConfig.Enabled {
+		if err := b.Collector.UpdateStatus(*asg.AutoScalingGroupName, "deployed", nil); err != nil {
+			Logger.Errorf("Update status Error, %s : %s", err.Error(), *asg.AutoScalingGroupName)
 		}
 	}
 
-	Logger.Debug("Finish addtional works.")
-	return nil
+	return isHealthy, nil
 }
 
 // Run lifecycle callbacks before cleaninig.
@@ -334,16 +334,11 @@ func (b BlueGreen) TriggerLifecycleCallbacks(config builder.Config) error {
 		}
 	}
 
-	for _, region := range b.Stack.Regions {
-		if config.Region != "" && config.Region != region.Region {
-			b.Logger.Debug("This region is skipped by user : " + region.Region)
-			continue
-		}
-
+	for _, region := range b.targetRegions(config) {
 		//select client
 		client, err := selectClientFromList(b.AWSClients, region.Region)
 		if err != nil {
-			tool.ErrorLogging(err.Error())
+			return err
 		}
 
 		if len(b.PrevInstances[region.Region]) > 0 {
@@ -368,18 +363,13 @@ func (b BlueGreen) CleanPreviousVersion(config builder.Config) error {
 		}
 	}
 
-	for _, region := range b.Stack.Regions {
-		if config.Region != "" && config.Region != region.Region {
-			b.Logger.Debug("This region is skipped by user : " + region.Region)
-			continue
-		}
-
+	for _, region := range b.targetRegions(config) {
 		b.Logger.Infof("[%s]The number of previous versions to delete is %d", region.Region, len(b.PrevAsgs[region.Region]))
 
 		//select client
 		client, err := selectClientFromList(b.AWSClients, region.Region)
 		if err != nil {
-			tool.ErrorLogging(err.Error())
+			return err
 		}
 
 		if len(b.PrevAsgs[region.Region]) > 0 {
@@ -406,32 +396,18 @@ func (b BlueGreen) TerminateChecking(config builder.Config) map[string]bool {
 	Logger.Info(fmt.Sprintf("Termination Checking for %s starts...", stack_name))
 
 	//Valid Count
-	validCount := 1
-	if config.Region == "" {
-		validCount = len(b.Stack.Regions)
-	}
-
-	if len(config.Region) > 0 {
-		if !checkRegionExist(config.Region, b.Stack.Regions) {
-			validCount = 0
-		}
-	}
+	regions := b.targetRegions(config)
+	validCount := len(regions)
 
 	finished := []string{}
-	for _, region := range b.Stack.Regions {
-		//Region check
-		//If region id is passed from command line, then deployer will deploy in that region only.
-		if config.Region != "" && config.Region != region.Region {
-			b.Logger.Debug("This region is skipped by user : " + region.Region)
-			continue
-		}
-
+	for _, region := range regions {
 		b.Logger.Info("Checking Termination stack for region starts : " + region.Region)
 
 		//select client
 		client, err := selectClientFromList(b.AWSClients, region.Region)
 		if err != nil {
-			tool.ErrorLogging(err.Error())
+			b.Logger.Errorln(err.Error())
+			continue
 		}
 
 		targets := b.PrevAsgs[region.Region]
@@ -462,6 +438,52 @@ func (b BlueGreen) TerminateChecking(config builder.Config) map[string]bool {
 	return map[string]bool{stack_name: false}
 }
 
+// Rollback removes the new version and restores previous versions
+func (b BlueGreen) Rollback(config builder.Config) error {
+	b.Logger.Infof("Rollback starts : %s", b.Stack.Stack)
+
+	for _, region := range b.targetRegions(config) {
+		if err := b.rollbackRegion(config, region); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollbackRegion removes the new version and restores previous versions in the region
+func (b BlueGreen) rollbackRegion(config builder.Config, region builder.RegionConfig) error {
+	//select client
+	client, err := selectClientFromList(b.AWSClients, region.Region)
+	if err != nil {
+		return err
+	}
+
+	return b.Deployer.RollbackNewVersion(client, region.Region, config.Env)
+}
+
+// Abandon leaves the new autoscaling group tagged as abandoned instead of deleting it
+func (b BlueGreen) Abandon(config builder.Config) error {
+	for _, region := range b.targetRegions(config) {
+		target := b.AsgNames[region.Region]
+		if len(target) == 0 {
+			continue
+		}
+
+		//select client
+		client, err := selectClientFromList(b.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		if err := b.Deployer.AbandonVersion(client, region.Region, target, target, config.Env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 //checkRegionExist checks if target region is really in regions described in manifest file
 func checkRegionExist(target string, regions []builder.RegionConfig) bool {
 	regionExists := false
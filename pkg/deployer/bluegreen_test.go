@@ -0,0 +1,57 @@
+package deployer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	sdk "github.com/aws/aws-sdk-go/aws"
+)
+
+func TestHealthCheckingWaitsForInheritedCapacity(t *testing.T) {
+	cloud := fake.NewCloud()
+	stack := newTestStack(builder.REPLACEMENT_TYPE_BLUEGREEN, builder.Capacity{Min: 1, Max: 8, Desired: 2}, testRegion)
+
+	config := builder.NewConfig()
+	config.Ami = "ami-00000000000000001"
+
+	first := newTestBlueGreen(cloud, stack)
+	if err := first.Deploy(context.Background(), config); err != nil {
+		t.Fatalf("first deployment failed : %v", err)
+	}
+
+	// The previous version is scaled out after it was deployed, so the new version inherits its capacity
+	if err := cloud.Bootstrap(testRegion, "").EC2Service.UpdateAutoScalingGroup(first.AsgNames[testRegion], 1, 8, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	b := newTestBlueGreen(cloud, stack)
+	if err := b.Deploy(context.Background(), config); err != nil {
+		t.Fatalf("second deployment failed : %v", err)
+	}
+
+	group := cloud.AutoScalingGroups[b.AsgNames[testRegion]]
+	if desired := sdk.Int64Value(group.DesiredCapacity); desired != 4 {
+		t.Fatalf("desired capacity of new version = %d, want 4", desired)
+	}
+
+	// Only as many instances as the manifest are healthy
+	for _, instance := range group.Instances[2:] {
+		cloud.TargetHealth[*instance.InstanceId] = "initial"
+	}
+
+	healthy, err := b.HealthChecking(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if healthy[b.GetStackName()] {
+		t.Errorf("new version is healthy with 2 of 4 instances")
+	}
+
+	cloud.SetTargetHealth(b.AsgNames[testRegion], "healthy")
+	if healthy, err := b.HealthChecking(context.Background(), config); err != nil || !healthy[b.GetStackName()] {
+		t.Errorf("healthchecking = %v, %v, want healthy with every instance", healthy, err)
+	}
+}
@@ -0,0 +1,224 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	Logger "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// Canary deploys a new autoscaling group with small capacity and increases it stage by stage
+type Canary struct {
+	BlueGreen
+	Stages        map[string]int
+	BakeStartedAt map[string]int64
+}
+
+func NewCanary(mode string, logger *Logger.Logger, awsConfig builder.AWSConfig, stack builder.Stack, awsClients []aws.AWSClient) Canary {
+	return Canary{
+		BlueGreen:     NewBlueGrean(mode, logger, awsConfig, stack, awsClients),
+		Stages:        map[string]int{},
+		BakeStartedAt: map[string]int64{},
+	}
+}
+
+// Deploy creates canary with the capacity of the first stage in every region
+// With the continue policy, canary of failed regions is scaled in to zero like Rollback.
+func (c Canary) Deploy(ctx context.Context, config builder.Config) error {
+	return c.deployWithRollback(ctx, config, func(region builder.RegionConfig) error {
+		return c.rollbackRegion(config, region)
+	})
+}
+
+// HealthChecking checks health of canary and moves to the next stage if analysis passes
+// With the continue policy, canary of regions in which healthchecking or analysis fails is scaled in to zero alone.
+func (c Canary) HealthChecking(ctx context.Context, config builder.Config) (map[string]bool, error) {
+	stack_name := c.GetStackName()
+	Logger.Debug(fmt.Sprintf("Healthchecking for stack starts : %s", stack_name))
+
+	healthy, err := c.checkRegions(ctx, config, func(region builder.RegionConfig) (bool, error) {
+		//select client
+		client, err := selectClientFromList(c.AWSClients, region.Region)
+		if err != nil {
+			return false, err
+		}
+
+		done, err := c.progress(client, region, config)
+		if err != nil {
+			return false, err
+		}
+
+		if done && c.Collector.MetricConfig.Enabled {
+			if err := c.Collector.UpdateStatus(c.AsgNames[region.Region], "deployed", nil); err != nil {
+				Logger.Errorf("Update status Error, %s : %s", err.Error(), c.AsgNames[region.Region])
+			}
+		}
+
+		return done, nil
+	}, func(region builder.RegionConfig) error {
+		return c.rollbackRegion(config, region)
+	})
+
+	return map[string]bool{stack_name: healthy}, err
+}
+
+// progress checks the current stage of canary in the region
+// It returns true if the last stage is healthy and passes the analysis.
+func (c Canary) progress(client aws.AWSClient, region builder.RegionConfig, config builder.Config) (bool, error) {
+	asgName := c.AsgNames[region.Region]
+	stages := c.Stack.Canary.GetStages()
+	stage := c.Stages[region.Region]
+	capacity := c.Stack.Canary.GetStageCapacity(stage, c.TargetCapacity[region.Region])
+
+	asg, err := client.EC2Service.GetMatchingAutoscalingGroup(asgName)
+	if err != nil {
+		return false, err
+	}
+
+	if asg == nil {
+		return false, fmt.Errorf("no autoscaling found for %s", asgName)
+	}
+
+	isHealthy, err := c.Deployer.polling(region, asg, client, capacity.Desired)
+	if err != nil {
+		return false, err
+	}
+
+	if !isHealthy {
+		return false, nil
+	}
+
+	if c.BakeStartedAt[region.Region] == 0 {
+		c.BakeStartedAt[region.Region] = time.Now().Unix()
+		c.Logger.Infof("Canary stage %d(%d%%) is healthy, bake time starts : %s", stage+1, stages[stage], asgName)
+		c.Slack.SendSimpleMessage(fmt.Sprintf("Canary stage %d(%d%%) is healthy, bake time starts : %s", stage+1, stages[stage], asgName), config.Env)
+	}
+
+	bakeStartedAt := time.Unix(c.BakeStartedAt[region.Region], 0)
+	if err := c.analyze(client, asgName, bakeStartedAt); err != nil {
+		c.Slack.SendSimpleMessage(fmt.Sprintf(":x: Canary analysis failed at stage %d(%d%%) : %s", stage+1, stages[stage], err.Error()), config.Env)
+		return false, err
+	}
+
+	if time.Since(bakeStartedAt) < c.Stack.Canary.GetBakeTime() {
+		c.Logger.Infof("Canary stage %d(%d%%) is baking : %s", stage+1, stages[stage], asgName)
+		return false, nil
+	}
+
+	if stage == len(stages)-1 {
+		c.Logger.Infof("All canary stages are passed : %s", asgName)
+		c.Slack.SendSimpleMessage(fmt.Sprintf(":+1: All canary stages are passed : %s", asgName), config.Env)
+		return true, nil
+	}
+
+	stage++
+	capacity = c.Stack.Canary.GetStageCapacity(stage, c.TargetCapacity[region.Region])
+	c.Logger.Infof("Canary moves to stage %d(%d%%) - Min: %d, Desired: %d, Max: %d", stage+1, stages[stage], capacity.Min, capacity.Desired, capacity.Max)
+	c.Slack.SendSimpleMessage(fmt.Sprintf("Canary moves to stage %d(%d%%) : %s", stage+1, stages[stage], asgName), config.Env)
+	if err := client.EC2Service.UpdateAutoScalingGroup(asgName, capacity.Min, capacity.Max, capacity.Desired); err != nil {
+		return false, err
+	}
+
+	c.Stages[region.Region] = stage
+	c.BakeStartedAt[region.Region] = 0
+
+	return false, nil
+}
+
+// analyze checks alarms and metrics of canary since the bake time started
+func (c Canary) analyze(client aws.AWSClient, asgName string, since time.Time) error {
+	alarms, err := client.CloudWatchService.GetAlarmsInAlarmState(c.Stack.Canary.Alarms)
+	if err != nil {
+		return err
+	}
+
+	if len(alarms) > 0 {
+		return fmt.Errorf("canary alarms are in ALARM state : %s", strings.Join(alarms, ", "))
+	}
+
+	for _, metric := range c.Stack.Canary.Metrics {
+		values, err := client.CloudWatchService.GetMetricDatapoints(asgName, metric, since, time.Now())
+		if err != nil {
+			return err
+		}
+
+		for _, v := range values {
+			if isBreaching(v, metric.Comparison, metric.Threshold) {
+				return fmt.Errorf("canary metric %s is breaching the threshold : %s %s %f(%f)", metric.Name, metric.Metric, metric.Comparison, metric.Threshold, v)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Rollback makes the capacity of canary to zero and keeps previous versions untouched
+func (c Canary) Rollback(config builder.Config) error {
+	c.Logger.Infof("Rollback starts : %s", c.Stack.Stack)
+
+	for _, region := range c.targetRegions(config) {
+		if err := c.rollbackRegion(config, region); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollbackRegion makes the capacity of canary to zero in the region
+// Nothing is done if canary was not created in the region.
+func (c Canary) rollbackRegion(config builder.Config, region builder.RegionConfig) error {
+	target := c.AsgNames[region.Region]
+	if len(target) == 0 {
+		return nil
+	}
+
+	//select client
+	client, err := selectClientFromList(c.AWSClients, region.Region)
+	if err != nil {
+		return err
+	}
+
+	asg, err := client.EC2Service.GetMatchingAutoscalingGroup(target)
+	if err != nil {
+		return err
+	}
+
+	if asg == nil {
+		c.Logger.Warnf("Canary is not created in %s : %s", region.Region, target)
+		return nil
+	}
+
+	if err := c.ResizingAutoScalingGroupToZero(client, c.Stack.Stack, target); err != nil {
+		return err
+	}
+
+	if c.Collector.MetricConfig.Enabled {
+		if err := c.Collector.UpdateStatus(target, "rolled_back", nil); err != nil {
+			c.Logger.Errorf("Update status Error, %s : %s", err.Error(), target)
+		}
+	}
+
+	c.Slack.SendSimpleMessage(fmt.Sprintf(":rewind: Canary is scaled in to zero in %s : %s", region.Region, target), config.Env)
+
+	return nil
+}
+
+// isBreaching checks if the value meets the comparison with threshold
+func isBreaching(value float64, comparison string, threshold float64) bool {
+	switch comparison {
+	case "GreaterThanOrEqualToThreshold":
+		return value >= threshold
+	case "GreaterThanThreshold":
+		return value > threshold
+	case "LessThanThreshold":
+		return value < threshold
+	case "LessThanOrEqualToThreshold":
+		return value <= threshold
+	}
+
+	return false
+}
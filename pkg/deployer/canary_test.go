@@ -0,0 +1,54 @@
+package deployer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	sdk "github.com/aws/aws-sdk-go/aws"
+)
+
+// newTestCanary returns canary deployer of which AWS services are in the fake cloud
+func newTestCanary(cloud *fake.Cloud) Canary {
+	stack := newTestStack(builder.REPLACEMENT_TYPE_CANARY, builder.Capacity{Min: 2, Max: 4, Desired: 4}, testRegion)
+
+	c := NewCanary(builder.REPLACEMENT_TYPE_CANARY, newTestLogger(), builder.AWSConfig{Name: "hello"}, stack, newTestClients(cloud, stack))
+	c.Slack = tool.Slack{SlackOff: true}
+	c.LocalProvider = builder.EncodedProvider{}
+
+	return c
+}
+
+func TestCanaryRollbackRegionKeepsAutoscalingGroup(t *testing.T) {
+	cloud := fake.NewCloud()
+	c := newTestCanary(cloud)
+
+	config := builder.NewConfig()
+	config.Ami = "ami-00000000000000001"
+	config.RegionFailurePolicy = builder.REGION_FAILURE_POLICY_CONTINUE
+
+	if err := c.Deploy(context.Background(), config); err != nil {
+		t.Fatalf("deployment failed : %v", err)
+	}
+
+	target := c.AsgNames[testRegion]
+	if desired := sdk.Int64Value(cloud.AutoScalingGroups[target].DesiredCapacity); desired != 1 {
+		t.Fatalf("desired capacity of canary = %d, want 1", desired)
+	}
+
+	// Failed region under the continue policy is rolled back in the same way with Rollback
+	if err := c.rollbackRegion(config, c.Stack.Regions[0]); err != nil {
+		t.Fatalf("rollback failed : %v", err)
+	}
+
+	group, ok := cloud.AutoScalingGroups[target]
+	if !ok {
+		t.Fatalf("%s is deleted, want it scaled in to zero", target)
+	}
+
+	if desired := sdk.Int64Value(group.DesiredCapacity); desired != 0 {
+		t.Errorf("desired capacity of %s = %d, want 0", target, desired)
+	}
+}
@@ -1,15 +1,21 @@
 package deployer
 
 import (
+	"context"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
 )
 
 type DeployManager interface {
 	GetStackName() string
-	Deploy(config builder.Config)
-	HealthChecking(config builder.Config) map[string]bool
+	Deploy(ctx context.Context, config builder.Config) error
+	HealthChecking(ctx context.Context, config builder.Config) (map[string]bool, error)
 	FinishAdditionalWork(config builder.Config) error
 	CleanPreviousVersion(config builder.Config) error
 	TriggerLifecycleCallbacks(config builder.Config) error
 	TerminateChecking(config builder.Config) map[string]bool
+	Rollback(config builder.Config) error
+	Abandon(config builder.Config) error
+	GetState() State
+	RestoreState(state State)
+	FailedRegions() map[string]error
 }
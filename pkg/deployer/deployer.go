@@ -1,6 +1,7 @@
 package deployer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/DevopsArtFactory/goployer/pkg/aws"
@@ -11,19 +12,30 @@ import (
 	Logger "github.com/sirupsen/logrus"
 )
 
+var (
+	ABANDONED_TAG_KEY   = "deployment-status"
+	ABANDONED_TAG_VALUE = "abandoned"
+)
+
 // Deployer per stack
 type Deployer struct {
-	Mode          string
-	AsgNames      map[string]string
-	PrevAsgs      map[string][]string
-	PrevInstances map[string][]string
-	Logger        *Logger.Logger
-	Stack         builder.Stack
-	AwsConfig     builder.AWSConfig
-	AWSClients    []aws.AWSClient
-	LocalProvider builder.UserdataProvider
-	Slack         tool.Slack
-	Collector     collector.Collector
+	Mode           string
+	AsgNames       map[string]string
+	PrevAsgs       map[string][]string
+	PrevInstances  map[string][]string
+	PrevCapacity   map[string]builder.Capacity
+	TargetCapacity map[string]builder.Capacity
+	Traffic        map[string]*TrafficState
+	Logger         *Logger.Logger
+	Stack          builder.Stack
+	AwsConfig      builder.AWSConfig
+	AWSClients     []aws.AWSClient
+	LocalProvider  builder.UserdataProvider
+	Slack          tool.Slack
+	Collector      collector.Collector
+	Recorder       *aws.Recorder
+
+	status *regionStatus
 }
 
 // getCurrentVersion returns current version for current deployment step
@@ -35,16 +47,15 @@ func getCurrentVersion(prevVersions []int) int {
 }
 
 // Polling for healthcheck
-func (d Deployer) polling(region builder.RegionConfig, asg *autoscaling.Group, client aws.AWSClient) bool {
-	if *asg.AutoScalingGroupName == "" {
-		tool.ErrorLogging(fmt.Sprintf("No autoscaling found for %s", d.AsgNames[region.Region]))
+func (d Deployer) polling(region builder.RegionConfig, asg *autoscaling.Group, client aws.AWSClient, threshold int64) (bool, error) {
+	if asg == nil || *asg.AutoScalingGroupName == "" {
+		return false, fmt.Errorf("no autoscaling found for %s", d.AsgNames[region.Region])
 	}
 
-	healthcheckTargetGroup := region.HealthcheckTargetGroup
-	healthcheckTargetGroupArn := (client.ELBService.GetTargetGroupARNs([]string{healthcheckTargetGroup}))[0]
-
-	threshold := d.Stack.Capacity.Desired
-	targetHosts := client.ELBService.GetHostInTarget(asg, healthcheckTargetGroupArn)
+	targetHosts, err := d.getTargetHosts(client, asg, region.HealthcheckTargetGroup)
+	if err != nil {
+		return false, err
+	}
 
 	healthHostCount := int64(0)
 
@@ -59,25 +70,125 @@ func (d Deployer) polling(region builder.RegionConfig, asg *autoscaling.Group, c
 		// Success
 		Logger.Info(fmt.Sprintf("Healthy Count for %s : %d/%d", d.AsgNames[region.Region], healthHostCount, threshold))
 		d.Slack.SendSimpleMessage(fmt.Sprintf("All instances are healthy in %s  :  %d/%d", d.AsgNames[region.Region], healthHostCount, threshold), d.Stack.Env)
-		return true
+		return true, nil
 	}
 
 	Logger.Info(fmt.Sprintf("Healthy count does not meet the requirement(%s) : %d/%d", d.AsgNames[region.Region], healthHostCount, threshold))
 	d.Slack.SendSimpleMessage(fmt.Sprintf("Waiting for healthy instances %s  :  %d/%d", d.AsgNames[region.Region], healthHostCount, threshold), d.Stack.Env)
 
-	return false
+	return false, nil
+}
+
+// getTargetHosts returns health of instances in the healthcheck target group
+func (d Deployer) getTargetHosts(client aws.AWSClient, asg *autoscaling.Group, targetGroup string) ([]aws.HealthcheckHost, error) {
+	arns, err := client.ELBService.GetTargetGroupARNs([]string{targetGroup})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(arns) == 0 {
+		return nil, fmt.Errorf("no target group found : %s", targetGroup)
+	}
+
+	return client.ELBService.GetHostInTarget(asg, arns[0])
+}
+
+// ResolveAmi returns the AMI ID of the region
+// The selector is resolved in each region so that the same build can be deployed to every region.
+func (d Deployer) ResolveAmi(client aws.AWSClient, region builder.RegionConfig, config builder.Config) (string, error) {
+	ami, selector, err := builder.GetAmi(config, d.Stack, region)
+	if err != nil || selector == nil {
+		return ami, err
+	}
+
+	ami, err = client.EC2Service.ResolveAmi(*selector)
+	if err != nil {
+		return "", err
+	}
+	d.Logger.Infof("AMI is resolved in %s : %s -> %s", region.Region, selector.String(), ami)
+
+	return ami, nil
+}
+
+// CreateLaunchTemplate creates a new launch template for the region and returns the AMI and userdata applied to it
+// Userdata template is rendered with the autoscaling group which the launch template is attached to.
+func (d Deployer) CreateLaunchTemplate(client aws.AWSClient, region builder.RegionConfig, config builder.Config, asgName, name string) (string, string, error) {
+	//Get AMI
+	ami, err := d.ResolveAmi(client, region, config)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Userdata in AWS is read with clients of the region
+	provider := builder.WithUserdataClients(d.LocalProvider, client.UserdataClients())
+
+	vars := builder.NewUserdataVars(d.AwsConfig.Name, config, d.Stack, region)
+	vars.Version = tool.ParseVersion(asgName)
+	vars.AutoscalingGroup = asgName
+	vars.LaunchTemplate = name
+	vars.Ami = ami
+
+	userdata, err := provider.Provide(vars)
+	if err != nil {
+		return "", "", err
+	}
+
+	//Stack check
+	securityGroups, err := client.EC2Service.GetSecurityGroupList(region.VPC, region.SecurityGroups)
+	if err != nil {
+		return "", "", err
+	}
+	blockDevices := client.EC2Service.MakeLaunchTemplateBlockDeviceMappings(d.Stack.BlockDevices)
+	ebsOptimized := d.Stack.EbsOptimized
+
+	// Instance Type Override
+	instanceType := region.InstanceType
+	if len(config.OverrideInstanceType) > 0 {
+		instanceType = config.OverrideInstanceType
+
+		if d.Stack.MixedInstancesPolicy.Enabled {
+			Logger.Warnf("--override-instance-type won't be applied because mixed_instances_policy is enabled")
+		}
+	}
+
+	err = client.EC2Service.CreateNewLaunchTemplate(
+		name,
+		ami,
+		instanceType,
+		region.SshKey,
+		d.Stack.IamInstanceProfile,
+		userdata,
+		ebsOptimized,
+		d.Stack.MixedInstancesPolicy.Enabled,
+		securityGroups,
+		blockDevices,
+		d.Stack.InstanceMarketOptions,
+	)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return ami, userdata, nil
 }
 
 // CheckTerminating checks if all of instances are terminated well
 func (d Deployer) CheckTerminating(client aws.AWSClient, target string) bool {
-	asgInfo := client.EC2Service.GetMatchingAutoscalingGroup(target)
+	asgInfo, err := client.EC2Service.GetMatchingAutoscalingGroup(target)
+	if err != nil {
+		d.Logger.Errorln(err.Error())
+		return false
+	}
+
 	if asgInfo == nil {
 		Logger.Info("Already deleted autoscaling group : ", target)
 		return true
 	}
 
 	d.Logger.Info(fmt.Sprintf("Waiting for instance termination in asg %s", target))
-	if len(asgInfo.Instances) > 0 {
+
+	// Instances are not really terminated in dry-run mode
+	if len(asgInfo.Instances) > 0 && !d.Recorder.Enabled() {
 		d.Logger.Info(fmt.Sprintf("%d instance found : %s", len(asgInfo.Instances), target))
 		d.Slack.SendSimpleMessage(fmt.Sprintf("Still %d instance found : %s", len(asgInfo.Instances), target), d.Stack.Env)
 
@@ -147,6 +258,226 @@ func (d Deployer) RunLifecycleCallbacks(client aws.AWSClient, target []string) b
 	return false
 }
 
+// RollbackNewVersion deletes the autoscaling group created in this deployment
+// and restores the capacity of previous autoscaling groups if they were resized.
+func (d Deployer) RollbackNewVersion(client aws.AWSClient, region string, env string) error {
+	if err := d.RevertTraffic(client, region); err != nil {
+		return err
+	}
+
+	target := d.AsgNames[region]
+	if len(target) > 0 {
+		d.Logger.Infof("Rolling back the new autoscaling group : %s", target)
+		asg, err := client.EC2Service.GetMatchingAutoscalingGroup(target)
+		if err != nil {
+			return err
+		}
+
+		if asg != nil {
+			if !client.EC2Service.ForceDeleteAutoscalingSet(target) {
+				return fmt.Errorf("failed to delete autoscaling group : %s", target)
+			}
+		}
+
+		if err := client.EC2Service.DeleteLaunchTemplates(target); err != nil {
+			return err
+		}
+		d.Logger.Debugf("Launch templates are deleted in %s", target)
+
+		if d.Collector.MetricConfig.Enabled {
+			if err := d.Collector.UpdateStatus(target, "rolled_back", nil); err != nil {
+				d.Logger.Errorf("Update status Error, %s : %s", err.Error(), target)
+			}
+		}
+	}
+
+	for _, prev := range d.PrevAsgs[region] {
+		capacity, ok := d.PrevCapacity[prev]
+		if !ok {
+			continue
+		}
+
+		asg, err := client.EC2Service.GetMatchingAutoscalingGroup(prev)
+		if err != nil {
+			return err
+		}
+
+		if asg == nil {
+			d.Logger.Warnf("Previous autoscaling group does not exist anymore : %s", prev)
+			continue
+		}
+
+		if *asg.MinSize == capacity.Min && *asg.MaxSize == capacity.Max && *asg.DesiredCapacity == capacity.Desired {
+			continue
+		}
+
+		d.Logger.Infof("Restoring the capacity of previous autoscaling group %s - Min: %d, Desired: %d, Max: %d", prev, capacity.Min, capacity.Desired, capacity.Max)
+		if err := client.EC2Service.UpdateAutoScalingGroup(prev, capacity.Min, capacity.Max, capacity.Desired); err != nil {
+			return err
+		}
+	}
+
+	d.Slack.SendSimpleMessage(fmt.Sprintf(":rewind: Rollback is done in %s : %s", region, target), env)
+
+	return nil
+}
+
+// AbandonVersion tags the autoscaling group of the interrupted deployment as abandoned
+// Resources are left as they are so that they can be inspected or deleted later.
+func (d Deployer) AbandonVersion(client aws.AWSClient, region, target, record, env string) error {
+	asg, err := client.EC2Service.GetMatchingAutoscalingGroup(target)
+	if err != nil {
+		return err
+	}
+
+	if asg == nil {
+		d.Logger.Warnf("No autoscaling group to be abandoned : %s", target)
+		return nil
+	}
+
+	if err := client.EC2Service.TagAutoScalingGroup(target, map[string]string{ABANDONED_TAG_KEY: ABANDONED_TAG_VALUE}); err != nil {
+		return err
+	}
+	d.Logger.Warnf("Autoscaling group is left as abandoned : %s", target)
+
+	if d.Collector.MetricConfig.Enabled {
+		if err := d.Collector.UpdateStatus(record, "abandoned", nil); err != nil {
+			d.Logger.Errorf("Update status Error, %s : %s", err.Error(), record)
+		}
+	}
+
+	d.Slack.SendSimpleMessage(fmt.Sprintf(":warning: Autoscaling group is left as abandoned in %s : %s", region, target), env)
+
+	return nil
+}
+
+// checkInterrupted returns an error of the step if the deployment is cancelled
+func (d Deployer) checkInterrupted(ctx context.Context, region, step string) error {
+	if err := ctx.Err(); err != nil {
+		return d.stepError(region, step, err)
+	}
+	return nil
+}
+
+//Stack Name Getter
+func (d Deployer) GetStackName() string {
+	return d.Stack.Stack
+}
+
+//FinishAdditionalWork attaches scaling policies and alarms to the autoscaling group
+func (d Deployer) FinishAdditionalWork(config builder.Config) error {
+	if len(d.Stack.Autoscaling) == 0 {
+		d.Logger.Debug("No scaling policy exists")
+		return nil
+	}
+
+	if len(config.Region) > 0 && !checkRegionExist(config.Region, d.Stack.Regions) {
+		return nil
+	}
+
+	//Apply Autosacling Policies
+	for _, region := range d.targetRegions(config) {
+		d.Logger.Info("Attaching autoscaling policies : " + region.Region)
+
+		//select client
+		client, err := selectClientFromList(d.AWSClients, region.Region)
+		if err != nil {
+			return d.stepError(region.Region, "attaching scaling policies", err)
+		}
+
+		//putting autoscaling group policies
+		policies := []string{}
+		policyArns := map[string]string{}
+		for _, policy := range d.Stack.Autoscaling {
+			policyArn, err := client.EC2Service.CreateScalingPolicy(policy, d.AsgNames[region.Region])
+			if err != nil {
+				return d.stepError(region.Region, "attaching scaling policies", err)
+			}
+			policyArns[policy.Name] = *policyArn
+			policies = append(policies, policy.Name)
+		}
+
+		if err := client.EC2Service.EnableMetrics(d.AsgNames[region.Region]); err != nil {
+			return d.stepError(region.Region, "enabling metrics", err)
+		}
+
+		if err := client.CloudWatchService.CreateScalingAlarms(d.AsgNames[region.Region], d.Stack.Alarms, policyArns); err != nil {
+			return d.stepError(region.Region, "creating scaling alarms", err)
+		}
+	}
+
+	Logger.Debug("Finish addtional works.")
+	return nil
+}
+
+// ReactivateVersion scales the existing autoscaling group of previous version up again
+// Other autoscaling groups with the same prefix become previous versions to be deleted.
+func (d Deployer) ReactivateVersion(client aws.AWSClient, region, target string, capacity builder.Capacity) error {
+	if err := d.SetPreviousVersions(client, region, target); err != nil {
+		return err
+	}
+
+	d.Logger.Infof("Reactivating autoscaling group %s - Min: %d, Desired: %d, Max: %d", target, capacity.Min, capacity.Desired, capacity.Max)
+	if err := client.EC2Service.UpdateAutoScalingGroup(target, capacity.Min, capacity.Max, capacity.Desired); err != nil {
+		return err
+	}
+
+	d.AsgNames[region] = target
+	d.TargetCapacity[region] = capacity
+
+	return nil
+}
+
+// SetPreviousVersions sets every autoscaling group of the stack except for exclude as previous versions to be deleted
+func (d Deployer) SetPreviousVersions(client aws.AWSClient, region, exclude string) error {
+	prefix := tool.BuildPrefixName(d.AwsConfig.Name, d.Stack.Env, region)
+
+	asgGroups, err := client.EC2Service.GetAllMatchingAutoscalingGroupsWithPrefix(prefix)
+	if err != nil {
+		return err
+	}
+
+	prevAsgs := []string{}
+	prevInstanceIds := []string{}
+	for _, asgGroup := range asgGroups {
+		if *asgGroup.AutoScalingGroupName == exclude {
+			continue
+		}
+
+		prevAsgs = append(prevAsgs, *asgGroup.AutoScalingGroupName)
+		for _, instance := range asgGroup.Instances {
+			prevInstanceIds = append(prevInstanceIds, *instance.InstanceId)
+		}
+		d.PrevCapacity[*asgGroup.AutoScalingGroupName] = builder.Capacity{
+			Min:     *asgGroup.MinSize,
+			Max:     *asgGroup.MaxSize,
+			Desired: *asgGroup.DesiredCapacity,
+		}
+	}
+
+	d.PrevAsgs[region] = prevAsgs
+	d.PrevInstances[region] = prevInstanceIds
+
+	return nil
+}
+
+// WithRecorder returns deployer whose mutating calls are recorded instead of being executed
+func (d Deployer) WithRecorder(recorder *aws.Recorder) Deployer {
+	if !recorder.Enabled() {
+		return d
+	}
+
+	clients := []aws.AWSClient{}
+	for _, client := range d.AWSClients {
+		clients = append(clients, client.WithRecorder(recorder))
+	}
+
+	d.AWSClients = clients
+	d.Recorder = recorder
+
+	return d
+}
+
 // selectClientFromList get aws client.
 func selectClientFromList(awsClients []aws.AWSClient, region string) (aws.AWSClient, error) {
 	for _, c := range awsClients {
@@ -0,0 +1,64 @@
+package deployer
+
+import (
+	"io/ioutil"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	Logger "github.com/sirupsen/logrus"
+)
+
+const testRegion = "ap-northeast-2"
+
+// newTestLogger returns a logger which writes nothing
+func newTestLogger() *Logger.Logger {
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+	return logger
+}
+
+// newTestStack returns the stack of hello app deployed to the regions
+func newTestStack(replacementType string, capacity builder.Capacity, regions ...string) builder.Stack {
+	stack := builder.Stack{
+		Stack:              "art",
+		Account:            "dev",
+		Env:                "dev",
+		ReplacementType:    replacementType,
+		IamInstanceProfile: "app-hello-profile",
+		Capacity:           capacity,
+	}
+
+	for _, region := range regions {
+		stack.Regions = append(stack.Regions, builder.RegionConfig{
+			Region:                 region,
+			InstanceType:           "m5.large",
+			SshKey:                 "test-master-key",
+			VPC:                    "vpc-art_" + region,
+			SecurityGroups:         []string{"hello-art_" + region},
+			HealthcheckTargetGroup: "hello-art-" + region,
+			TargetGroups:           []string{"hello-art-" + region},
+		})
+	}
+
+	return stack
+}
+
+// newTestClients returns clients of the regions in the fake cloud
+func newTestClients(cloud *fake.Cloud, stack builder.Stack) []aws.AWSClient {
+	clients := []aws.AWSClient{}
+	for _, region := range stack.Regions {
+		clients = append(clients, cloud.Bootstrap(region.Region, ""))
+	}
+	return clients
+}
+
+// newTestBlueGreen returns blue/green deployer of which AWS services are in the fake cloud
+func newTestBlueGreen(cloud *fake.Cloud, stack builder.Stack) BlueGreen {
+	b := NewBlueGrean(builder.REPLACEMENT_TYPE_BLUEGREEN, newTestLogger(), builder.AWSConfig{Name: "hello"}, stack, newTestClients(cloud, stack))
+	b.Slack = tool.Slack{SlackOff: true}
+	b.LocalProvider = builder.EncodedProvider{}
+
+	return b
+}
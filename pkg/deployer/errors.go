@@ -0,0 +1,29 @@
+package deployer
+
+import "fmt"
+
+// DeploymentError is returned when a step of deployment fails in a region
+type DeploymentError struct {
+	Stack  string
+	Region string
+	Step   string
+	Err    error
+}
+
+func (e *DeploymentError) Error() string {
+	return fmt.Sprintf("%s failed in %s(%s) : %s", e.Step, e.Stack, e.Region, e.Err.Error())
+}
+
+func (e *DeploymentError) Unwrap() error {
+	return e.Err
+}
+
+// stepError wraps the error of the step with stack and region
+func (d Deployer) stepError(region, step string, err error) error {
+	return &DeploymentError{
+		Stack:  d.Stack.Stack,
+		Region: region,
+		Step:   step,
+		Err:    err,
+	}
+}
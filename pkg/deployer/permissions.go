@@ -0,0 +1,125 @@
+package deployer
+
+import (
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"sort"
+)
+
+var (
+	// Actions which every deployment calls
+	BASE_DEPLOYMENT_ACTIONS = []string{
+		"autoscaling:CreateAutoScalingGroup",
+		"autoscaling:CreateOrUpdateTags",
+		"autoscaling:DeleteAutoScalingGroup",
+		"autoscaling:DescribeAutoScalingGroups",
+		"autoscaling:UpdateAutoScalingGroup",
+		"ec2:CreateLaunchTemplate",
+		"ec2:DeleteLaunchTemplate",
+		"ec2:DescribeLaunchTemplates",
+		"ec2:DescribeSecurityGroups",
+		"ec2:DescribeSubnets",
+		"ec2:DescribeVpcs",
+		"ec2:RunInstances",
+		"elasticloadbalancing:DescribeTargetGroups",
+		"elasticloadbalancing:DescribeTargetHealth",
+	}
+)
+
+// RequiredActions returns IAM actions which the deployment of the stack calls with its assume role
+// Actions depend on features of the stack like lifecycle hooks, alarms or userdata in AWS.
+// Metrics and locks are not included because they use credentials of goployer, not the assume role.
+func RequiredActions(awsConfig builder.AWSConfig, stack builder.Stack, config builder.Config) []string {
+	actions := append([]string{}, BASE_DEPLOYMENT_ACTIONS...)
+	add := func(names ...string) {
+		for _, name := range names {
+			if !tool.IsStringInArray(name, actions) {
+				actions = append(actions, name)
+			}
+		}
+	}
+
+	if len(stack.IamInstanceProfile) > 0 {
+		add("iam:PassRole")
+	}
+
+	if stack.ReplacementType == builder.REPLACEMENT_TYPE_ROLLING {
+		add("autoscaling:TerminateInstanceInAutoScalingGroup")
+	}
+
+	if len(stack.Autoscaling) > 0 {
+		add("autoscaling:PutScalingPolicy", "autoscaling:EnableMetricsCollection", "cloudwatch:PutMetricAlarm")
+	}
+
+	if len(stack.LifecycleHooks.LaunchTransition) > 0 || len(stack.LifecycleHooks.TerminateTransition) > 0 {
+		add("autoscaling:PutLifecycleHook")
+	}
+
+	if len(stack.LifecycleCallbacks.PreTerminatePastClusters) > 0 {
+		add("ssm:SendCommand")
+	}
+
+	if stack.ReplacementType == builder.REPLACEMENT_TYPE_CANARY {
+		if len(stack.Canary.Alarms) > 0 {
+			add("cloudwatch:DescribeAlarms")
+		}
+
+		if len(stack.Canary.Metrics) > 0 {
+			add("cloudwatch:GetMetricStatistics")
+		}
+	}
+
+	for _, region := range stack.Regions {
+		if len(config.Region) > 0 && config.Region != region.Region {
+			continue
+		}
+
+		if stack.TrafficShifting.Enabled {
+			if aws.IsListenerRule(region.ListenerRule) {
+				add("elasticloadbalancing:DescribeRules", "elasticloadbalancing:ModifyRule")
+			} else {
+				add("elasticloadbalancing:DescribeListeners", "elasticloadbalancing:ModifyListener")
+			}
+		}
+
+		if _, selector, err := builder.GetAmi(config, stack, region); err == nil && selector != nil {
+			if len(selector.SSMParameter) > 0 {
+				add("ssm:GetParameter")
+			} else {
+				add("ec2:DescribeImages")
+			}
+		}
+	}
+
+	add(userdataActions(builder.SetUserdataProvider(stack.Userdata, awsConfig.Userdata))...)
+
+	sort.Strings(actions)
+
+	return actions
+}
+
+// userdataActions returns actions with which userdata is read from AWS
+func userdataActions(provider builder.UserdataProvider) []string {
+	switch p := provider.(type) {
+	case builder.S3Provider:
+		// Region of the bucket is looked up before the object is read
+		actions := []string{"s3:ListBucket", "s3:GetObject"}
+		if _, _, version, err := builder.ParseS3Path(p.Path); err == nil && len(version) > 0 {
+			actions = append(actions, "s3:GetObjectVersion")
+		}
+		return actions
+	case builder.SSMProvider:
+		return []string{"ssm:GetParameter"}
+	case builder.SecretsManagerProvider:
+		return []string{"secretsmanager:GetSecretValue"}
+	case builder.MultipartProvider:
+		actions := []string{}
+		for _, part := range p.Parts {
+			actions = append(actions, userdataActions(part.Provider)...)
+		}
+		return actions
+	}
+
+	return nil
+}
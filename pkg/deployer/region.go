@@ -0,0 +1,255 @@
+package deployer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"sync"
+)
+
+// regionStatus keeps regions in which the new version is rolled back alone
+// It is shared by copies of deployer so that the next steps skip these regions.
+// The lock also guards maps of deployer while regions are deployed at the same time.
+type regionStatus struct {
+	mu     sync.Mutex
+	failed map[string]error
+}
+
+func newRegionStatus() *regionStatus {
+	return &regionStatus{failed: map[string]error{}}
+}
+
+// withLock runs f while other regions cannot change the progress of deployer
+func (d Deployer) withLock(f func()) {
+	if d.status == nil {
+		f()
+		return
+	}
+
+	d.status.mu.Lock()
+	defer d.status.mu.Unlock()
+	f()
+}
+
+// FailedRegions returns errors of regions which are rolled back alone
+func (d Deployer) FailedRegions() map[string]error {
+	failed := map[string]error{}
+	d.withLock(func() {
+		if d.status == nil {
+			return
+		}
+
+		for region, err := range d.status.failed {
+			failed[region] = err
+		}
+	})
+
+	return failed
+}
+
+// markFailed excludes the region from the next steps of deployment
+func (d Deployer) markFailed(region string, err error) {
+	d.withLock(func() {
+		if d.status != nil {
+			d.status.failed[region] = err
+		}
+	})
+}
+
+// skipRegion checks if the region is not selected by user or deployment already failed in it
+func (d Deployer) skipRegion(config builder.Config, region string) bool {
+	if config.Region != "" && config.Region != region {
+		d.Logger.Debug("This region is skipped by user : " + region)
+		return true
+	}
+
+	if _, ok := d.FailedRegions()[region]; ok {
+		d.Logger.Debug("This region is skipped because deployment failed : " + region)
+		return true
+	}
+
+	return false
+}
+
+// targetRegions returns regions in which the step should run
+func (d Deployer) targetRegions(config builder.Config) []builder.RegionConfig {
+	regions := []builder.RegionConfig{}
+	for _, region := range d.Stack.Regions {
+		if d.skipRegion(config, region.Region) {
+			continue
+		}
+		regions = append(regions, region)
+	}
+
+	return regions
+}
+
+// deployRegions runs deploy in target regions at the same time up to the region concurrency
+// With the stop policy, regions which are not started yet are skipped after the first failure
+// and the error is returned so that every region is rolled back.
+// With the continue policy, failed regions are rolled back alone and excluded from the next steps.
+// The error is returned only if deployment fails in every region.
+func (d Deployer) deployRegions(ctx context.Context, config builder.Config, deploy func(ctx context.Context, region builder.RegionConfig) error, rollback func(region builder.RegionConfig) error) error {
+	regions := d.targetRegions(config)
+	if len(regions) == 0 {
+		return nil
+	}
+
+	concurrency := config.RegionConcurrency
+	if concurrency <= 0 || concurrency > len(regions) {
+		concurrency = len(regions)
+	}
+
+	stopOnFailure := config.RegionFailurePolicy != builder.REGION_FAILURE_POLICY_CONTINUE
+	regionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(regions))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	// Regions start in the order of manifest
+	for i, region := range regions {
+		sem <- struct{}{}
+		if regionCtx.Err() != nil {
+			d.Logger.Warnf("Deployment is not started in %s because it is stopped", region.Region)
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, region builder.RegionConfig) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				if p := recover(); p != nil {
+					errs[i] = d.stepError(region.Region, "deploy", fmt.Errorf("unexpected error : %v", p))
+					if stopOnFailure {
+						cancel()
+					}
+				}
+			}()
+
+			if err := deploy(regionCtx, region); err != nil {
+				errs[i] = err
+				if stopOnFailure {
+					cancel()
+				}
+			}
+		}(i, region)
+	}
+	wg.Wait()
+
+	if stopOnFailure || ctx.Err() != nil {
+		return d.firstRegionError(ctx, errs)
+	}
+
+	failed := 0
+	for i, region := range regions {
+		if errs[i] == nil {
+			continue
+		}
+		failed++
+
+		if err := d.isolateRegion(config, region, errs[i], rollback); err != nil {
+			return err
+		}
+	}
+
+	if failed == len(regions) {
+		return d.firstRegionError(ctx, errs)
+	}
+
+	return nil
+}
+
+// checkRegions runs check in target regions and returns true if every region is healthy
+// Regions which are not healthy until the timeout fail in the same way as errors of check.
+// With the stop policy, the first failure is returned so that every region is rolled back.
+// With the continue policy, failed regions are rolled back alone and excluded from the next steps.
+// The error is returned only if healthchecking fails in every region.
+func (d Deployer) checkRegions(ctx context.Context, config builder.Config, check func(region builder.RegionConfig) (bool, error), rollback func(region builder.RegionConfig) error) (bool, error) {
+	regions := d.targetRegions(config)
+	stopOnFailure := config.RegionFailurePolicy != builder.REGION_FAILURE_POLICY_CONTINUE
+
+	finished := 0
+	errs := []error{}
+	for _, region := range regions {
+		if err := d.checkInterrupted(ctx, region.Region, "healthchecking"); err != nil {
+			return false, err
+		}
+
+		d.Logger.Debug("Healthchecking for region starts : " + region.Region)
+
+		done, err := check(region)
+		if err == nil && !done {
+			err = tool.CheckTimeout(config.StartTimestamp, config.Timeout)
+		}
+
+		if err == nil {
+			if done {
+				finished++
+			}
+			continue
+		}
+
+		err = d.stepError(region.Region, "healthchecking", err)
+		if stopOnFailure {
+			return false, err
+		}
+
+		if err := d.isolateRegion(config, region, err, rollback); err != nil {
+			return false, err
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 && len(errs) == len(regions) {
+		return false, d.firstRegionError(ctx, errs)
+	}
+
+	return finished+len(errs) == len(regions), nil
+}
+
+// isolateRegion rolls back the failed region alone and excludes it from the next steps
+func (d Deployer) isolateRegion(config builder.Config, region builder.RegionConfig, cause error, rollback func(region builder.RegionConfig) error) error {
+	d.Logger.Errorf("Deployment failed in %s, the region is rolled back : %s", region.Region, cause.Error())
+	if err := rollback(region); err != nil {
+		return d.stepError(region.Region, "rollback", err)
+	}
+	d.markFailed(region.Region, cause)
+	d.Slack.SendSimpleMessage(fmt.Sprintf(":x: Deployment failed in %s, the region is rolled back : %s", region.Region, cause.Error()), config.Env)
+
+	return nil
+}
+
+// firstRegionError returns the error which stopped the deployment
+// Regions cancelled by the failure of other region are ignored unless the deployment is interrupted.
+func (d Deployer) firstRegionError(ctx context.Context, errs []error) error {
+	var first error
+	for _, err := range errs {
+		if err == nil || (ctx.Err() == nil && errors.Is(err, context.Canceled)) {
+			continue
+		}
+
+		if first == nil {
+			first = err
+			continue
+		}
+
+		d.Logger.Errorf("deployment also failed : %s", err.Error())
+	}
+
+	if first != nil {
+		return first
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
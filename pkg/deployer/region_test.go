@@ -0,0 +1,311 @@
+package deployer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+const failedRegion = "us-east-1"
+
+// newTestRegionDeployer returns deployer of the stack in the regions
+func newTestRegionDeployer(regions ...string) Deployer {
+	stack := newTestStack(builder.REPLACEMENT_TYPE_BLUEGREEN, builder.Capacity{Min: 1, Max: 4, Desired: 2}, regions...)
+	return newTestBlueGreen(fake.NewCloud(), stack).Deployer
+}
+
+// regionCalls records regions with which callbacks are called at the same time
+type regionCalls struct {
+	mu      sync.Mutex
+	regions []string
+	running int
+	max     int
+}
+
+func (c *regionCalls) start(region string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.regions = append(c.regions, region)
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+}
+
+func (c *regionCalls) done() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.running--
+}
+
+func (c *regionCalls) sorted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ret := append([]string{}, c.regions...)
+	sort.Strings(ret)
+	return ret
+}
+
+func TestDeployRegionsWithFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		wantErr    bool
+		rolledBack []string
+		failed     []string
+	}{
+		{
+			name:       "stop",
+			policy:     builder.REGION_FAILURE_POLICY_STOP,
+			wantErr:    true,
+			rolledBack: []string{},
+			failed:     []string{},
+		},
+		{
+			name:       "continue",
+			policy:     builder.REGION_FAILURE_POLICY_CONTINUE,
+			rolledBack: []string{failedRegion},
+			failed:     []string{failedRegion},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestRegionDeployer(testRegion, failedRegion)
+
+			config := builder.NewConfig()
+			config.RegionFailurePolicy = test.policy
+
+			rollback := &regionCalls{}
+			err := d.deployRegions(context.Background(), config, func(ctx context.Context, region builder.RegionConfig) error {
+				if region.Region == failedRegion {
+					return d.stepError(region.Region, "deploy", errors.New("capacity is not enough"))
+				}
+				return nil
+			}, func(region builder.RegionConfig) error {
+				rollback.start(region.Region)
+				rollback.done()
+				return nil
+			})
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("deployRegions() error = %v, want error %v", err, test.wantErr)
+			}
+
+			if got := rollback.sorted(); !reflect.DeepEqual(got, test.rolledBack) {
+				t.Errorf("rolled back regions = %v, want %v", got, test.rolledBack)
+			}
+
+			failed := []string{}
+			for region := range d.FailedRegions() {
+				failed = append(failed, region)
+			}
+			if !reflect.DeepEqual(failed, test.failed) {
+				t.Errorf("failed regions = %v, want %v", failed, test.failed)
+			}
+
+			// Failed regions are excluded from the next steps
+			if regions := d.targetRegions(config); len(regions) != 2-len(test.failed) {
+				t.Errorf("target regions = %d, want %d", len(regions), 2-len(test.failed))
+			}
+		})
+	}
+}
+
+func TestCheckRegionsWithFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		wantErr    bool
+		healthy    bool
+		rolledBack []string
+	}{
+		{name: "stop", policy: builder.REGION_FAILURE_POLICY_STOP, wantErr: true, rolledBack: []string{}},
+		{name: "continue", policy: builder.REGION_FAILURE_POLICY_CONTINUE, healthy: true, rolledBack: []string{failedRegion}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestRegionDeployer(testRegion, failedRegion)
+
+			config := builder.NewConfig()
+			config.RegionFailurePolicy = test.policy
+
+			rollback := &regionCalls{}
+			healthy, err := d.checkRegions(context.Background(), config, func(region builder.RegionConfig) (bool, error) {
+				if region.Region == failedRegion {
+					return false, errors.New("canary alarms are in ALARM state")
+				}
+				return true, nil
+			}, func(region builder.RegionConfig) error {
+				rollback.start(region.Region)
+				rollback.done()
+				return nil
+			})
+
+			if (err != nil) != test.wantErr || healthy != test.healthy {
+				t.Fatalf("checkRegions() = %v, %v, want %v with error %v", healthy, err, test.healthy, test.wantErr)
+			}
+
+			if got := rollback.sorted(); !reflect.DeepEqual(got, test.rolledBack) {
+				t.Errorf("rolled back regions = %v, want %v", got, test.rolledBack)
+			}
+		})
+	}
+}
+
+func TestCheckRegionsTimesOutUnhealthyRegions(t *testing.T) {
+	d := newTestRegionDeployer(testRegion, failedRegion)
+
+	config := builder.NewConfig()
+	config.RegionFailurePolicy = builder.REGION_FAILURE_POLICY_CONTINUE
+	config.StartTimestamp = time.Now().Add(-time.Hour).Unix()
+	config.Timeout = time.Minute
+
+	healthy, err := d.checkRegions(context.Background(), config, func(region builder.RegionConfig) (bool, error) {
+		return region.Region != failedRegion, nil
+	}, func(region builder.RegionConfig) error {
+		return nil
+	})
+
+	if err != nil || !healthy {
+		t.Fatalf("checkRegions() = %v, %v, want healthy without the timed out region", healthy, err)
+	}
+
+	var timeout *tool.TimeoutError
+	if cause := d.FailedRegions()[failedRegion]; !errors.As(cause, &timeout) {
+		t.Errorf("failure of %s = %v, want timeout", failedRegion, cause)
+	}
+}
+
+func TestDeployRegionsReturnsErrorIfEveryRegionFails(t *testing.T) {
+	d := newTestRegionDeployer(testRegion, failedRegion)
+
+	config := builder.NewConfig()
+	config.RegionFailurePolicy = builder.REGION_FAILURE_POLICY_CONTINUE
+
+	err := d.deployRegions(context.Background(), config, func(ctx context.Context, region builder.RegionConfig) error {
+		return d.stepError(region.Region, "deploy", errors.New("capacity is not enough"))
+	}, func(region builder.RegionConfig) error {
+		return nil
+	})
+
+	if err == nil {
+		t.Fatal("deployRegions() error = nil, want error of the first region")
+	}
+
+	if failed := d.FailedRegions(); len(failed) != 2 {
+		t.Errorf("failed regions = %v, want every region", failed)
+	}
+}
+
+func TestDeployRegionsConcurrency(t *testing.T) {
+	regions := []string{"ap-northeast-1", "ap-northeast-2", "us-east-1", "us-west-2"}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		want        int
+	}{
+		{name: "unlimited", concurrency: 0, want: 4},
+		{name: "limited", concurrency: 2, want: 2},
+		{name: "one by one", concurrency: 1, want: 1},
+		{name: "larger than regions", concurrency: 8, want: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestRegionDeployer(regions...)
+
+			config := builder.NewConfig()
+			config.RegionConcurrency = test.concurrency
+
+			calls := &regionCalls{}
+			err := d.deployRegions(context.Background(), config, func(ctx context.Context, region builder.RegionConfig) error {
+				calls.start(region.Region)
+				defer calls.done()
+
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := calls.sorted(); !reflect.DeepEqual(got, regions) {
+				t.Errorf("deployed regions = %v, want %v", got, regions)
+			}
+
+			if calls.max > test.want {
+				t.Errorf("regions deployed at the same time = %d, want at most %d", calls.max, test.want)
+			}
+		})
+	}
+}
+
+func TestDeployRegionsStopsRegionsNotStarted(t *testing.T) {
+	d := newTestRegionDeployer(failedRegion, testRegion)
+
+	config := builder.NewConfig()
+	config.RegionConcurrency = 1
+
+	calls := &regionCalls{}
+	err := d.deployRegions(context.Background(), config, func(ctx context.Context, region builder.RegionConfig) error {
+		calls.start(region.Region)
+		defer calls.done()
+
+		return d.stepError(region.Region, "deploy", errors.New("capacity is not enough"))
+	}, nil)
+
+	if err == nil {
+		t.Fatal("deployRegions() error = nil, want error")
+	}
+
+	if got := calls.sorted(); !reflect.DeepEqual(got, []string{failedRegion}) {
+		t.Errorf("deployed regions = %v, want only %s", got, failedRegion)
+	}
+}
+
+func TestFirstRegionError(t *testing.T) {
+	failure := errors.New("capacity is not enough")
+	cancelled := fmt.Errorf("deploy is stopped : %w", context.Canceled)
+
+	tests := []struct {
+		name        string
+		errs        []error
+		interrupted bool
+		want        error
+	}{
+		{name: "no error", errs: []error{nil, nil}},
+		{name: "cancelled regions are ignored", errs: []error{cancelled, failure}, want: failure},
+		{name: "first failure", errs: []error{nil, failure, errors.New("other")}, want: failure},
+		{name: "only cancelled regions", errs: []error{nil, cancelled}, want: cancelled},
+		{name: "interrupted deployment", errs: []error{cancelled, failure}, interrupted: true, want: cancelled},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.interrupted {
+				cancel()
+			}
+
+			if got := newTestRegionDeployer(testRegion).firstRegionError(ctx, test.errs); got != test.want {
+				t.Errorf("firstRegionError() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	Logger "github.com/sirupsen/logrus"
+)
+
+// Rolling replaces instances of the existing autoscaling group in batches
+type Rolling struct {
+	Deployer
+	LaunchTemplates     map[string]string
+	PrevLaunchTemplates map[string]string
+}
+
+func NewRolling(mode string, logger *Logger.Logger, awsConfig builder.AWSConfig, stack builder.Stack, awsClients []aws.AWSClient) Rolling {
+	return Rolling{
+		Deployer: Deployer{
+			Mode:           mode,
+			Logger:         logger,
+			AwsConfig:      awsConfig,
+			AWSClients:     awsClients,
+			AsgNames:       map[string]string{},
+			PrevAsgs:       map[string][]string{},
+			PrevInstances:  map[string][]string{},
+			PrevCapacity:   map[string]builder.Capacity{},
+			TargetCapacity: map[string]builder.Capacity{},
+			Traffic:        map[string]*TrafficState{},
+			Stack:          stack,
+			status:         newRegionStatus(),
+		},
+		LaunchTemplates:     map[string]string{},
+		PrevLaunchTemplates: map[string]string{},
+	}
+}
+
+// Deploy creates a new launch template and attaches it to the current autoscaling group
+// Regions are deployed at the same time and failures are handled with the region failure policy.
+func (r Rolling) Deploy(ctx context.Context, config builder.Config) error {
+	r.Logger.Info("Deploy Mode is " + r.Mode)
+
+	//Get LocalFileProvider
+	r.LocalProvider = builder.SetUserdataProvider(r.Stack.Userdata, r.AwsConfig.Userdata)
+
+	return r.deployRegions(ctx, config, func(ctx context.Context, region builder.RegionConfig) error {
+		return r.deployRegion(ctx, config, region)
+	}, func(region builder.RegionConfig) error {
+		return r.rollbackRegion(config, region)
+	})
+}
+
+// deployRegion attaches a new launch template to the latest autoscaling group in the region
+func (r Rolling) deployRegion(ctx context.Context, config builder.Config, region builder.RegionConfig) error {
+	if err := r.checkInterrupted(ctx, region.Region, "deploy"); err != nil {
+		return err
+	}
+
+	prefix := tool.BuildPrefixName(r.AwsConfig.Name, r.Stack.Env, region.Region)
+
+	//select client
+	client, err := selectClientFromList(r.AWSClients, region.Region)
+	if err != nil {
+		return r.stepError(region.Region, "deploy", err)
+	}
+
+	asgGroups, err := client.EC2Service.GetAllMatchingAutoscalingGroupsWithPrefix(prefix)
+	if err != nil {
+		return r.stepError(region.Region, "deploy", err)
+	}
+
+	if len(asgGroups) == 0 {
+		return r.stepError(region.Region, "deploy", fmt.Errorf("no autoscaling group exists for rolling update : %s", prefix))
+	}
+
+	// The latest version is the target of rolling update
+	asg := asgGroups[0]
+	for _, asgGroup := range asgGroups {
+		if tool.ParseVersion(*asgGroup.AutoScalingGroupName) > tool.ParseVersion(*asg.AutoScalingGroupName) {
+			asg = asgGroup
+		}
+	}
+
+	if len(asgGroups) > 1 {
+		r.Logger.Warnf("More than one autoscaling group exists, only the latest one will be updated : %s", *asg.AutoScalingGroupName)
+	}
+
+	asgName := *asg.AutoScalingGroupName
+	launch_template_name := tool.GenerateLcName(asgName)
+
+	// The resolved AMI is stamped so that rollback recreates the launch template with the same image
+	config.Ami, err = r.ResolveAmi(client, region, config)
+	if err != nil {
+		return r.stepError(region.Region, "deploy", err)
+	}
+
+	ami, userdata, err := r.Deployer.CreateLaunchTemplate(client, region, config, asgName, launch_template_name)
+	if err != nil {
+		return r.stepError(region.Region, "deploy", err)
+	}
+
+	if err := client.EC2Service.UpdateAutoScalingGroupLaunchTemplate(asgName, launch_template_name, r.Stack.MixedInstancesPolicy.Enabled); err != nil {
+		return r.stepError(region.Region, "deploy", err)
+	}
+
+	prevInstanceIds := []string{}
+	for _, instance := range asg.Instances {
+		prevInstanceIds = append(prevInstanceIds, *instance.InstanceId)
+	}
+
+	r.withLock(func() {
+		r.AsgNames[region.Region] = asgName
+		r.PrevInstances[region.Region] = prevInstanceIds
+		r.LaunchTemplates[region.Region] = launch_template_name
+		r.PrevLaunchTemplates[region.Region] = getLaunchTemplateName(asg)
+	})
+
+	r.Logger.Infof("Rolling update starts : %s(%d instances)", asgName, len(prevInstanceIds))
+
+	if r.Collector.MetricConfig.Enabled {
+		additionalFields := map[string]string{
+			"autoscaling_group": asgName,
+		}
+		if len(config.ReleaseNotes) > 0 {
+			additionalFields["release-notes"] = config.ReleaseNotes
+		}
+
+		if len(config.ReleaseNotesBase64) > 0 {
+			additionalFields["release-notes-base64"] = config.ReleaseNotesBase64
+		}
+
+		if len(userdata) > 0 {
+			additionalFields["userdata"] = userdata
+		}
+		additionalFields["region"] = region.Region
+		additionalFields["ami"] = ami
+
+		tags := []*autoscaling.Tag{}
+		for _, t := range asg.Tags {
+			tags = append(tags, &autoscaling.Tag{Key: t.Key, Value: t.Value})
+		}
+
+		if err := r.Collector.StampDeployment(r.Stack, config, tags, launch_template_name, "creating", additionalFields); err != nil {
+			r.Logger.Errorf("Stamp deployment Error, %s : %s", err.Error(), launch_template_name)
+		}
+	}
+	return nil
+}
+
+// HealthChecking replaces the next batch of instances when all instances are healthy
+// With the continue policy, regions in which healthchecking fails are rolled back alone.
+func (r Rolling) HealthChecking(ctx context.Context, config builder.Config) (map[string]bool, error) {
+	stack_name := r.GetStackName()
+	Logger.Debug(fmt.Sprintf("Healthchecking for stack starts : %s", stack_name))
+
+	healthy, err := r.checkRegions(ctx, config, func(region builder.RegionConfig) (bool, error) {
+		//select client
+		client, err := selectClientFromList(r.AWSClients, region.Region)
+		if err != nil {
+			return false, err
+		}
+
+		done, err := r.replaceBatch(client, region, config)
+		if err != nil {
+			return false, err
+		}
+
+		if done && r.Collector.MetricConfig.Enabled {
+			if err := r.Collector.UpdateStatus(r.LaunchTemplates[region.Region], "deployed", nil); err != nil {
+				Logger.Errorf("Update status Error, %s : %s", err.Error(), r.LaunchTemplates[region.Region])
+			}
+		}
+
+		return done, nil
+	}, func(region builder.RegionConfig) error {
+		return r.rollbackRegion(config, region)
+	})
+
+	return map[string]bool{stack_name: healthy}, err
+}
+
+// replaceBatch terminates the next batch of old instances if every instance is healthy
+// It returns true if there is no old instance anymore.
+func (r Rolling) replaceBatch(client aws.AWSClient, region builder.RegionConfig, config builder.Config) (bool, error) {
+	asgName := r.AsgNames[region.Region]
+	asg, err := client.EC2Service.GetMatchingAutoscalingGroup(asgName)
+	if err != nil {
+		return false, err
+	}
+
+	if asg == nil {
+		return false, fmt.Errorf("no autoscaling found for %s", asgName)
+	}
+
+	targetHosts, err := r.Deployer.getTargetHosts(client, asg, region.HealthcheckTargetGroup)
+	if err != nil {
+		return false, err
+	}
+
+	healthHostCount := int64(0)
+	for _, host := range targetHosts {
+		Logger.Info(fmt.Sprintf("%+v", host))
+		if host.Healthy {
+			healthHostCount += 1
+		}
+	}
+
+	threshold := *asg.DesiredCapacity
+	if healthHostCount < threshold || int64(len(targetHosts)) > healthHostCount {
+		Logger.Info(fmt.Sprintf("Waiting for instances to be healthy(%s) : %d/%d", asgName, healthHostCount, threshold))
+		r.Slack.SendSimpleMessage(fmt.Sprintf("Waiting for healthy instances %s  :  %d/%d", asgName, healthHostCount, threshold), config.Env)
+		return false, nil
+	}
+
+	oldInstances := []string{}
+	for _, instance := range asg.Instances {
+		if instance.LaunchTemplate == nil || *instance.LaunchTemplate.LaunchTemplateName != r.LaunchTemplates[region.Region] {
+			oldInstances = append(oldInstances, *instance.InstanceId)
+		}
+	}
+
+	if len(oldInstances) == 0 {
+		Logger.Info(fmt.Sprintf("All instances are replaced in %s : %d/%d", asgName, healthHostCount, threshold))
+		r.Slack.SendSimpleMessage(fmt.Sprintf("All instances are replaced in %s  :  %d/%d", asgName, healthHostCount, threshold), config.Env)
+		return true, nil
+	}
+
+	batchSize, err := r.Stack.RollingUpdate.GetBatchSize(threshold)
+	if err != nil {
+		return false, err
+	}
+
+	if int64(len(oldInstances)) < batchSize {
+		batchSize = int64(len(oldInstances))
+	}
+	batch := oldInstances[:batchSize]
+
+	if len(r.Stack.LifecycleCallbacks.PreTerminatePastClusters) > 0 {
+		r.Deployer.RunLifecycleCallbacks(client, batch)
+	}
+
+	for _, instanceId := range batch {
+		if err := client.EC2Service.TerminateInstanceInAutoScalingGroup(instanceId); err != nil {
+			return false, err
+		}
+	}
+
+	Logger.Info(fmt.Sprintf("Replacing %d instances in %s, %d instances left", len(batch), asgName, len(oldInstances)-len(batch)))
+	r.Slack.SendSimpleMessage(fmt.Sprintf("Replacing %d instances in %s, %d instances left", len(batch), asgName, len(oldInstances)-len(batch)), config.Env)
+
+	return false, nil
+}
+
+// TriggerLifecycleCallbacks does nothing because callbacks run before each batch is terminated
+func (r Rolling) TriggerLifecycleCallbacks(config builder.Config) error {
+	r.Logger.Debugf("lifecycle callbacks already ran for each batch in %s", r.Stack.Stack)
+	return nil
+}
+
+// CleanPreviousVersion deletes the previous launch template
+func (r Rolling) CleanPreviousVersion(config builder.Config) error {
+	r.Logger.Debug("Delete Mode is " + r.Mode)
+
+	for _, region := range r.targetRegions(config) {
+		prev := r.PrevLaunchTemplates[region.Region]
+		if len(prev) == 0 || prev == r.LaunchTemplates[region.Region] {
+			r.Logger.Infof("No previous launch template to be deleted : %s", region.Region)
+			continue
+		}
+
+		//select client
+		client, err := selectClientFromList(r.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		if err := client.EC2Service.DeleteLaunchTemplate(prev); err != nil {
+			return err
+		}
+		r.Logger.Infof("Previous launch template is deleted : %s", prev)
+	}
+
+	return nil
+}
+
+// TerminateChecking returns true because no autoscaling group is deleted in rolling update
+func (r Rolling) TerminateChecking(config builder.Config) map[string]bool {
+	return map[string]bool{r.GetStackName(): true}
+}
+
+// Rollback attaches the previous launch template again and replaces the new instances
+func (r Rolling) Rollback(config builder.Config) error {
+	r.Logger.Infof("Rollback starts : %s", r.Stack.Stack)
+
+	for _, region := range r.targetRegions(config) {
+		if err := r.rollbackRegion(config, region); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollbackRegion attaches the previous launch template in the region
+func (r Rolling) rollbackRegion(config builder.Config, region builder.RegionConfig) error {
+	asgName := r.AsgNames[region.Region]
+	if len(asgName) == 0 {
+		return nil
+	}
+
+	prev := r.PrevLaunchTemplates[region.Region]
+	if len(prev) == 0 {
+		return fmt.Errorf("no previous launch template to roll back : %s", asgName)
+	}
+
+	//select client
+	client, err := selectClientFromList(r.AWSClients, region.Region)
+	if err != nil {
+		return err
+	}
+
+	if err := client.EC2Service.UpdateAutoScalingGroupLaunchTemplate(asgName, prev, r.Stack.MixedInstancesPolicy.Enabled); err != nil {
+		return err
+	}
+
+	asg, err := client.EC2Service.GetMatchingAutoscalingGroup(asgName)
+	if err != nil {
+		return err
+	}
+
+	if asg != nil {
+		for _, instance := range asg.Instances {
+			if instance.LaunchTemplate != nil && *instance.LaunchTemplate.LaunchTemplateName == r.LaunchTemplates[region.Region] {
+				if err := client.EC2Service.TerminateInstanceInAutoScalingGroup(*instance.InstanceId); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := client.EC2Service.DeleteLaunchTemplate(r.LaunchTemplates[region.Region]); err != nil {
+		return err
+	}
+
+	if r.Collector.MetricConfig.Enabled {
+		if err := r.Collector.UpdateStatus(r.LaunchTemplates[region.Region], "rolled_back", nil); err != nil {
+			r.Logger.Errorf("Update status Error, %s : %s", err.Error(), r.LaunchTemplates[region.Region])
+		}
+	}
+
+	r.Slack.SendSimpleMessage(fmt.Sprintf(":rewind: Rollback is done in %s : %s", region.Region, asgName), config.Env)
+	return nil
+}
+
+// getLaunchTemplateName returns the name of launch template attached to autoscaling group
+func getLaunchTemplateName(asg *autoscaling.Group) string {
+	if asg.LaunchTemplate != nil && asg.LaunchTemplate.LaunchTemplateName != nil {
+		return *asg.LaunchTemplate.LaunchTemplateName
+	}
+
+	if asg.MixedInstancesPolicy != nil && asg.MixedInstancesPolicy.LaunchTemplate != nil {
+		spec := asg.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+		if spec != nil && spec.LaunchTemplateName != nil {
+			return *spec.LaunchTemplateName
+		}
+	}
+
+	return ""
+}
+
+// Abandon leaves the autoscaling group with the new launch template tagged as abandoned
+// Instances which are already replaced keep running with the new launch template.
+func (r Rolling) Abandon(config builder.Config) error {
+	for _, region := range r.targetRegions(config) {
+		asgName := r.AsgNames[region.Region]
+		if len(asgName) == 0 {
+			continue
+		}
+
+		//select client
+		client, err := selectClientFromList(r.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		if err := r.Deployer.AbandonVersion(client, region.Region, asgName, r.LaunchTemplates[region.Region], config.Env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
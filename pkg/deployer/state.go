@@ -0,0 +1,119 @@
+package deployer
+
+import (
+	"errors"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+)
+
+// State is the progress of deployer which is persisted to resume the deployment later
+type State struct {
+	Stack               string
+	Mode                string
+	AsgNames            map[string]string
+	PrevAsgs            map[string][]string
+	PrevInstances       map[string][]string
+	PrevCapacity        map[string]builder.Capacity
+	TargetCapacity      map[string]builder.Capacity
+	Traffic             map[string]*TrafficState
+	LaunchTemplates     map[string]string `json:",omitempty"`
+	PrevLaunchTemplates map[string]string `json:",omitempty"`
+	Stages              map[string]int    `json:",omitempty"`
+	BakeStartedAt       map[string]int64  `json:",omitempty"`
+	FailedRegions       map[string]string `json:",omitempty"`
+}
+
+// GetState returns the current progress of deployer
+func (d Deployer) GetState() State {
+	failed := map[string]string{}
+	for region, err := range d.FailedRegions() {
+		failed[region] = err.Error()
+	}
+
+	return State{
+		Stack:          d.Stack.Stack,
+		Mode:           d.Mode,
+		AsgNames:       d.AsgNames,
+		PrevAsgs:       d.PrevAsgs,
+		PrevInstances:  d.PrevInstances,
+		PrevCapacity:   d.PrevCapacity,
+		TargetCapacity: d.TargetCapacity,
+		Traffic:        d.Traffic,
+		FailedRegions:  failed,
+	}
+}
+
+// RestoreState puts the persisted progress back to deployer
+// Maps are shared by copies of deployer so that values are copied into them.
+func (d Deployer) RestoreState(state State) {
+	for k, v := range state.AsgNames {
+		d.AsgNames[k] = v
+	}
+
+	for k, v := range state.PrevAsgs {
+		d.PrevAsgs[k] = v
+	}
+
+	for k, v := range state.PrevInstances {
+		d.PrevInstances[k] = v
+	}
+
+	for k, v := range state.PrevCapacity {
+		d.PrevCapacity[k] = v
+	}
+
+	for k, v := range state.TargetCapacity {
+		d.TargetCapacity[k] = v
+	}
+
+	for k, v := range state.Traffic {
+		d.Traffic[k] = v
+	}
+
+	for k, v := range state.FailedRegions {
+		d.markFailed(k, errors.New(v))
+	}
+}
+
+// GetState returns the progress of rolling update including launch templates
+func (r Rolling) GetState() State {
+	state := r.Deployer.GetState()
+	state.LaunchTemplates = r.LaunchTemplates
+	state.PrevLaunchTemplates = r.PrevLaunchTemplates
+
+	return state
+}
+
+// RestoreState puts the persisted progress of rolling update back
+func (r Rolling) RestoreState(state State) {
+	r.Deployer.RestoreState(state)
+
+	for k, v := range state.LaunchTemplates {
+		r.LaunchTemplates[k] = v
+	}
+
+	for k, v := range state.PrevLaunchTemplates {
+		r.PrevLaunchTemplates[k] = v
+	}
+}
+
+// GetState returns the progress of canary including the current stage
+func (c Canary) GetState() State {
+	state := c.Deployer.GetState()
+	state.Stages = c.Stages
+	state.BakeStartedAt = c.BakeStartedAt
+
+	return state
+}
+
+// RestoreState puts the persisted progress of canary back
+func (c Canary) RestoreState(state State) {
+	c.Deployer.RestoreState(state)
+
+	for k, v := range state.Stages {
+		c.Stages[k] = v
+	}
+
+	for k, v := range state.BakeStartedAt {
+		c.BakeStartedAt[k] = v
+	}
+}
@@ -0,0 +1,130 @@
+package deployer
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"strings"
+	"time"
+)
+
+// TrafficState is the state of weighted traffic shifting in a region
+type TrafficState struct {
+	ListenerRule       string
+	OriginalWeights    map[string]int64
+	OldTargetGroupArn  string
+	NewTargetGroupArn  string
+	NewTargetGroupName string
+	Step               int
+	ShiftedAt          int64
+}
+
+// PrepareTrafficShifting selects the target group which is not receiving traffic for the new version
+func (d Deployer) PrepareTrafficShifting(client aws.AWSClient, region builder.RegionConfig) (*TrafficState, error) {
+	weights, err := client.ELBService.GetForwardWeights(region.ListenerRule)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(region.WeightedTargetGroups) != 2 {
+		return nil, fmt.Errorf("weighted_target_groups should have two target groups for blue and green : %s", region.Region)
+	}
+
+	arns := []string{}
+	for _, name := range region.WeightedTargetGroups {
+		ret, err := client.ELBService.GetTargetGroupARNs([]string{name})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ret) == 0 {
+			return nil, fmt.Errorf("no target group found : %s", name)
+		}
+		arns = append(arns, *ret[0])
+	}
+
+	// The target group with larger weight is serving traffic now
+	live := 0
+	if weights[arns[1]] > weights[arns[0]] {
+		live = 1
+	}
+
+	if weights[arns[live]] == 0 {
+		return nil, fmt.Errorf("no traffic is forwarded to weighted target groups by %s : %s", region.ListenerRule, strings.Join(region.WeightedTargetGroups, ", "))
+	}
+
+	state := &TrafficState{
+		ListenerRule:       region.ListenerRule,
+		OriginalWeights:    weights,
+		OldTargetGroupArn:  arns[live],
+		NewTargetGroupArn:  arns[1-live],
+		NewTargetGroupName: region.WeightedTargetGroups[1-live],
+	}
+
+	d.Logger.Infof("New version will be attached to %s, traffic is shifted from %s", region.WeightedTargetGroups[1-live], region.WeightedTargetGroups[live])
+
+	return state, nil
+}
+
+// ShiftTraffic moves the next step of traffic to the new target group if interval has passed
+// It returns true if all traffic is shifted to the new target group.
+func (d Deployer) ShiftTraffic(client aws.AWSClient, region string, env string) (bool, error) {
+	state := d.Traffic[region]
+	steps := d.Stack.TrafficShifting.GetSteps()
+
+	if state.Step == len(steps) {
+		return true, nil
+	}
+
+	if state.ShiftedAt > 0 && time.Since(time.Unix(state.ShiftedAt, 0)) < d.Stack.TrafficShifting.GetInterval() {
+		d.Logger.Infof("Waiting for the next step of traffic shifting : %d%% is shifted in %s", steps[state.Step-1], region)
+		return false, nil
+	}
+
+	percent := steps[state.Step]
+	weights := shiftedWeights(state, percent)
+
+	if err := client.ELBService.ModifyForwardWeights(state.ListenerRule, weights); err != nil {
+		return false, err
+	}
+
+	state.Step++
+	state.ShiftedAt = time.Now().Unix()
+
+	d.Logger.Infof("%d%% of traffic is shifted to %s in %s", percent, state.NewTargetGroupName, region)
+	d.Slack.SendSimpleMessage(fmt.Sprintf("%d%% of traffic is shifted to %s in %s", percent, state.NewTargetGroupName, region), env)
+
+	return state.Step == len(steps), nil
+}
+
+// shiftedWeights returns weights in which the percent of traffic to the old target group goes to the new one
+// Original weights of both target groups are split so that other target groups of the action keep their share.
+func shiftedWeights(state *TrafficState, percent int64) map[string]int64 {
+	weights := map[string]int64{}
+	for arn, weight := range state.OriginalWeights {
+		weights[arn] = weight
+	}
+
+	total := state.OriginalWeights[state.OldTargetGroupArn] + state.OriginalWeights[state.NewTargetGroupArn]
+	weights[state.NewTargetGroupArn] = total * percent / 100
+	weights[state.OldTargetGroupArn] = total - weights[state.NewTargetGroupArn]
+
+	return weights
+}
+
+// RevertTraffic restores weights of target groups before traffic shifting
+func (d Deployer) RevertTraffic(client aws.AWSClient, region string) error {
+	state, ok := d.Traffic[region]
+	if !ok || state.Step == 0 {
+		return nil
+	}
+
+	if err := client.ELBService.ModifyForwardWeights(state.ListenerRule, state.OriginalWeights); err != nil {
+		return err
+	}
+	state.Step = 0
+
+	d.Logger.Infof("Weights of target groups are reverted in %s", region)
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package deployer
+
+import (
+	"testing"
+)
+
+func TestShiftedWeights(t *testing.T) {
+	tests := []struct {
+		name     string
+		original map[string]int64
+		percent  int64
+		want     map[string]int64
+	}{
+		{
+			name:     "all traffic on old target group",
+			original: map[string]int64{"old": 100, "new": 0},
+			percent:  10,
+			want:     map[string]int64{"old": 90, "new": 10},
+		},
+		{
+			name:     "weights other than 100",
+			original: map[string]int64{"old": 1, "new": 0},
+			percent:  100,
+			want:     map[string]int64{"old": 0, "new": 1},
+		},
+		{
+			name:     "weights of both target groups are split",
+			original: map[string]int64{"old": 30, "new": 10},
+			percent:  50,
+			want:     map[string]int64{"old": 20, "new": 20},
+		},
+		{
+			name:     "other target groups keep their share",
+			original: map[string]int64{"old": 50, "new": 0, "other": 50},
+			percent:  20,
+			want:     map[string]int64{"old": 40, "new": 10, "other": 50},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := &TrafficState{OriginalWeights: test.original, OldTargetGroupArn: "old", NewTargetGroupArn: "new"}
+			got := shiftedWeights(state, test.percent)
+
+			if len(got) != len(test.want) {
+				t.Fatalf("weights = %v, want %v", got, test.want)
+			}
+
+			for arn, weight := range test.want {
+				if got[arn] != weight {
+					t.Errorf("weights = %v, want %v", got, test.want)
+					break
+				}
+			}
+
+			if state.OriginalWeights["old"] != test.original["old"] {
+				t.Errorf("original weights are changed : %v", state.OriginalWeights)
+			}
+		})
+	}
+}
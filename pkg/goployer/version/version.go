@@ -0,0 +1,39 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// These values are set by ldflags at build time. Please check Makefile.
+var (
+	version      = "dev"
+	buildDate    = ""
+	gitCommit    = ""
+	gitTreeState = ""
+)
+
+type Info struct {
+	Version      string
+	BuildDate    string
+	GitCommit    string
+	GitTreeState string
+	GoVersion    string
+	Platform     string
+}
+
+// Get returns the build information of goployer
+func Get() Info {
+	return Info{
+		Version:      version,
+		BuildDate:    buildDate,
+		GitCommit:    gitCommit,
+		GitTreeState: gitTreeState,
+		GoVersion:    runtime.Version(),
+		Platform:     fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("goployer %s (commit: %s, tree: %s, built: %s, %s %s)", i.Version, i.GitCommit, i.GitTreeState, i.BuildDate, i.GoVersion, i.Platform)
+}
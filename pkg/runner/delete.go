@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/deployer"
+	"strings"
+)
+
+// Delete removes every autoscaling group and launch template of the stack
+func (r Runner) Delete() error {
+	stack, err := r.targetStack()
+	if err != nil {
+		return err
+	}
+
+	ctx, release, err := r.acquireLocks(context.Background(), []builder.Stack{stack})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	r.Logger.Info("Beginning deletion: ", r.Builder.AwsConfig.Name)
+
+	d := deployer.NewBlueGrean(builder.REPLACEMENT_TYPE_BLUEGREEN, r.Logger, r.Builder.AwsConfig, stack, r.bootstrapClients(stack))
+	d.Slack = r.Slacker
+	d.Collector = r.Collector
+
+	targets := []string{}
+	for _, region := range stack.Regions {
+		if r.Builder.Config.Region != "" && r.Builder.Config.Region != region.Region {
+			r.Logger.Debug("This region is skipped by user : " + region.Region)
+			continue
+		}
+
+		if err := d.SetPreviousVersions(r.Bootstrap(region.Region, stack.AssumeRole), region.Region, ""); err != nil {
+			return err
+		}
+		targets = append(targets, d.PrevAsgs[region.Region]...)
+	}
+
+	if len(targets) == 0 {
+		r.Logger.Infof("No autoscaling group to be deleted : %s", stack.Stack)
+		return nil
+	}
+
+	r.Slacker.SendSimpleMessage(fmt.Sprintf(":wastebasket: Deleting autoscaling groups : %s", strings.Join(targets, ", ")), r.Builder.Config.Env)
+
+	if err := d.TriggerLifecycleCallbacks(r.Builder.Config); err != nil {
+		return err
+	}
+
+	if err := d.CleanPreviousVersion(r.Builder.Config); err != nil {
+		return err
+	}
+
+	if err := cleanChecking(ctx, []deployer.DeployManager{d}, r.Builder.Config); err != nil {
+		return err
+	}
+
+	r.Slacker.SendSimpleMessage(":100: Deletion is done.", r.Builder.Config.Env)
+
+	return nil
+}
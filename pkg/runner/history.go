@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/collector"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// History returns past deployments of target stacks in the date range, latest first
+func (r Runner) History() ([]collector.HistoryRecord, error) {
+	if !r.Builder.MetricConfig.Enabled {
+		return nil, fmt.Errorf("deployment history is not recorded because metric measurement is disabled")
+	}
+
+	stacks, err := r.Builder.GetTargetStacks()
+	if err != nil {
+		return nil, err
+	}
+
+	since, until, err := r.Builder.Config.HistoryRange()
+	if err != nil {
+		return nil, err
+	}
+
+	query := collector.HistoryQuery{
+		App:    r.Builder.AwsConfig.Name,
+		Stacks: []string{},
+		Region: r.Builder.Config.Region,
+		Since:  since,
+		Until:  until,
+	}
+
+	for _, stack := range stacks {
+		query.Stacks = append(query.Stacks, stack.Stack)
+	}
+
+	return r.Collector.GetHistory(query)
+}
+
+// PrintHistory writes deployments with the output format
+func PrintHistory(out io.Writer, records []collector.HistoryRecord, format string) error {
+	if format == builder.OUTPUT_FORMAT_JSON {
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintln(out, "no deployment exists")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tSTACK\tENV\tREGION\tIDENTIFIER\tSTATUS\tDURATION\tAMI\tDEPLOYED BY\tRELEASE NOTES")
+	for _, record := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			record.StartedAt.Format(time.RFC3339),
+			record.Stack,
+			valueOrDash(record.Env),
+			valueOrDash(record.Region),
+			record.Identifier,
+			record.Status,
+			valueOrDash(record.Duration),
+			valueOrDash(record.Ami),
+			valueOrDash(record.DeployedBy),
+			valueOrDash(firstLine(record.ReleaseNotes)),
+		)
+	}
+
+	return w.Flush()
+}
+
+// firstLine returns the first line of release notes so that the table is not broken
+func firstLine(value string) string {
+	return strings.TrimSpace(strings.SplitN(value, "\n", 2)[0])
+}
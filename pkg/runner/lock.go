@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/collector"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// acquireLocks takes deployment locks of every region in the stacks
+// It returns a context which is cancelled when any lock is lost, and a function which releases all locks taken.
+func (r Runner) acquireLocks(ctx context.Context, stacks []builder.Stack) (context.Context, func(), error) {
+	lockCtx, cancel := context.WithCancel(ctx)
+	locks := []*collector.Lock{}
+	release := func() {
+		cancel()
+		for _, lock := range locks {
+			if err := r.Collector.ReleaseLock(lock); err != nil {
+				r.Logger.Errorf("failed to release the lock of %s : %s", lock.Prefix, err.Error())
+			}
+		}
+	}
+
+	if !r.Builder.MetricConfig.Enabled {
+		r.Logger.Warnln("deployment lock is not used because metric measurement is disabled")
+		return lockCtx, release, nil
+	}
+
+	// Nothing is changed in dry-run mode
+	if r.Recorder.Enabled() {
+		return lockCtx, release, nil
+	}
+
+	holder := collector.NewLockHolder()
+	for _, stack := range stacks {
+		for _, region := range stack.Regions {
+			if r.Builder.Config.Region != "" && r.Builder.Config.Region != region.Region {
+				continue
+			}
+
+			prefix := tool.BuildPrefixName(r.Builder.AwsConfig.Name, stack.Env, region.Region)
+			lock, err := r.Collector.AcquireLock(ctx, prefix, holder, r.Builder.Config.WaitForLock)
+			if err != nil {
+				release()
+				return nil, nil, err
+			}
+
+			r.Logger.Debugf("lock is acquired : %s", prefix)
+			locks = append(locks, lock)
+			go r.watchLock(lockCtx, cancel, lock)
+		}
+	}
+
+	return lockCtx, release, nil
+}
+
+// watchLock cancels the context if the lock is lost so that deployment stops and rolls back
+func (r Runner) watchLock(ctx context.Context, cancel context.CancelFunc, lock *collector.Lock) {
+	select {
+	case <-lock.Lost():
+		r.Logger.Errorf("deployment lock is lost, stopping deployment : %s", lock.Err().Error())
+		cancel()
+	case <-ctx.Done():
+	}
+}
+
+// Unlock deletes deployment locks of the stack left by dead processes
+func (r Runner) Unlock() error {
+	stack, err := r.targetStack()
+	if err != nil {
+		return err
+	}
+
+	if !r.Builder.MetricConfig.Enabled {
+		return fmt.Errorf("deployment lock is not used because metric measurement is disabled")
+	}
+
+	for _, region := range stack.Regions {
+		if r.Builder.Config.Region != "" && r.Builder.Config.Region != region.Region {
+			r.Logger.Debug("This region is skipped by user : " + region.Region)
+			continue
+		}
+
+		prefix := tool.BuildPrefixName(r.Builder.AwsConfig.Name, stack.Env, region.Region)
+		holder, err := r.Collector.GetLockHolder(prefix)
+		if err != nil {
+			return err
+		}
+
+		if len(holder) == 0 {
+			r.Logger.Infof("No lock exists : %s", prefix)
+			continue
+		}
+
+		if err := r.Collector.ForceReleaseLock(prefix); err != nil {
+			return err
+		}
+		r.Logger.Infof("Lock is deleted : %s (held by %s)", prefix, holder)
+		r.Slacker.SendSimpleMessage(fmt.Sprintf(":unlock: Deployment lock is deleted : %s (held by %s)", prefix, holder), r.Builder.Config.Env)
+	}
+
+	return nil
+}
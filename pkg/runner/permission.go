@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/deployer"
+	"strings"
+)
+
+// checkPermissions checks with IAM policy simulation that the assume role of each stack can call actions of the deployment
+// Missing permissions of every stack are reported at once before any resource is changed.
+func (r Runner) checkPermissions(stacks []builder.Stack) error {
+	report := []string{}
+	for _, stack := range stacks {
+		region := ""
+		for _, rc := range stack.Regions {
+			if r.Builder.Config.Region == "" || r.Builder.Config.Region == rc.Region {
+				region = rc.Region
+				break
+			}
+		}
+
+		if len(region) == 0 {
+			continue
+		}
+
+		principal, missing, err := r.missingPermissions(r.Bootstrap(region, stack.AssumeRole), stack)
+		if err != nil {
+			// Deployment goes on if the simulation itself is not allowed
+			r.Logger.Warnf("permissions of %s cannot be checked : %s", stack.Stack, err.Error())
+			continue
+		}
+
+		if len(missing) > 0 {
+			report = append(report, fmt.Sprintf("%s (%s) : %s", stack.Stack, principal, strings.Join(missing, ", ")))
+		}
+	}
+
+	if len(report) > 0 {
+		fmt.Printf("missing permissions\n  %s\n", strings.Join(report, "\n  "))
+		return fmt.Errorf("permissions for deployment are missing. use --skip-permission-check if policies allow actions only for specific resources or with conditions")
+	}
+
+	return nil
+}
+
+// missingPermissions returns the principal of the stack and actions which it is not allowed to call
+func (r Runner) missingPermissions(client aws.AWSClient, stack builder.Stack) (string, []string, error) {
+	principal, err := client.STSService.GetCallerIdentity()
+	if err != nil {
+		return "", nil, err
+	}
+
+	missing, err := client.IAMService.GetMissingPermissions(principal, deployer.RequiredActions(r.Builder.AwsConfig, stack, r.Builder.Config))
+	if err != nil {
+		return principal, nil, err
+	}
+
+	return principal, missing, nil
+}
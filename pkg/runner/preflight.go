@@ -0,0 +1,247 @@
+package runner
+
+import (
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"strings"
+)
+
+// PreflightProblem is a problem which the deployment would meet in the region
+// Region is empty if the problem is not specific to a region.
+type PreflightProblem struct {
+	Stack  string
+	Region string
+	Check  string
+	Err    error
+}
+
+func (p PreflightProblem) String() string {
+	if len(p.Region) == 0 {
+		return fmt.Sprintf("[%s] %s : %s", p.Stack, p.Check, p.Err.Error())
+	}
+	return fmt.Sprintf("[%s/%s] %s : %s", p.Stack, p.Region, p.Check, p.Err.Error())
+}
+
+// reportFunc adds the problem of the check
+type reportFunc func(check string, err error)
+
+// Preflight checks that resources of target stacks exist in AWS and are reachable with the assume role of each stack
+// Every problem is collected instead of stopping at the first one.
+func (r Runner) Preflight() ([]PreflightProblem, error) {
+	stacks, err := r.Builder.GetTargetStacks()
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []PreflightProblem{}
+	for _, stack := range stacks {
+		stack := stack
+		report := func(region string) reportFunc {
+			return func(check string, err error) {
+				problems = append(problems, PreflightProblem{Stack: stack.Stack, Region: region, Check: check, Err: err})
+			}
+		}
+
+		checkAlarmActions(stack, report(""))
+
+		// IAM is global so that the instance profile and permissions are checked only once for the stack
+		iamChecked := false
+		for _, region := range stack.Regions {
+			if r.Builder.Config.Region != "" && r.Builder.Config.Region != region.Region {
+				r.Logger.Debug("This region is skipped by user : " + region.Region)
+				continue
+			}
+
+			r.Logger.Infof("Running preflight checks : %s(%s)", stack.Stack, region.Region)
+			client := r.Bootstrap(region.Region, stack.AssumeRole)
+
+			// Other checks fail for the same reason if the assume role cannot be used
+			if _, err := client.STSService.GetCallerIdentity(); err != nil {
+				report(region.Region)("assume role", err)
+				continue
+			}
+
+			if !iamChecked {
+				if len(stack.IamInstanceProfile) > 0 {
+					if err := client.IAMService.CheckInstanceProfile(stack.IamInstanceProfile); err != nil {
+						report("")("instance profile", err)
+					}
+				}
+
+				principal, missing, err := r.missingPermissions(client, stack)
+				if err != nil {
+					report("")("permissions", err)
+				} else if len(missing) > 0 {
+					report("")("permissions", fmt.Errorf("%s is not allowed to call %s", principal, strings.Join(missing, ", ")))
+				}
+				iamChecked = true
+			}
+
+			r.preflightRegion(client, stack, region, report(region.Region))
+		}
+	}
+
+	return problems, nil
+}
+
+// preflightRegion checks resources of the stack in the region
+func (r Runner) preflightRegion(client aws.AWSClient, stack builder.Stack, region builder.RegionConfig, report reportFunc) {
+	azs := checkNetwork(client, region, report)
+
+	for _, instanceType := range instanceTypes(r.Builder.Config, stack, region) {
+		zones, err := client.EC2Service.GetInstanceTypeZones(instanceType)
+		if err != nil {
+			report("instance type", err)
+			continue
+		}
+
+		unavailable := []string{}
+		for _, az := range azs {
+			if !tool.IsStringInArray(az, zones) {
+				unavailable = append(unavailable, az)
+			}
+		}
+
+		if len(unavailable) > 0 {
+			report("instance type", fmt.Errorf("%s is not offered in %s", instanceType, strings.Join(unavailable, ", ")))
+		}
+	}
+
+	for _, tg := range targetGroups(region) {
+		if _, err := client.ELBService.GetTargetGroupARNs([]string{tg}); err != nil {
+			report("target group", err)
+		}
+	}
+
+	if stack.TrafficShifting.Enabled {
+		if _, err := client.ELBService.GetForwardWeights(region.ListenerRule); err != nil {
+			report("listener rule", err)
+		}
+	}
+
+	ami, selector, err := builder.GetAmi(r.Builder.Config, stack, region)
+	switch {
+	case err != nil:
+		report("ami", err)
+	case selector != nil:
+		if _, err := client.EC2Service.ResolveAmi(*selector); err != nil {
+			report("ami", err)
+		}
+	default:
+		if err := client.EC2Service.CheckImage(ami); err != nil {
+			report("ami", err)
+		}
+	}
+
+	if len(region.SshKey) > 0 {
+		if err := client.EC2Service.CheckKeyPair(region.SshKey); err != nil {
+			report("key pair", err)
+		}
+	}
+
+	if len(stack.Canary.Alarms) > 0 {
+		existing, err := client.CloudWatchService.GetExistingAlarms(stack.Canary.Alarms)
+		if err != nil {
+			report("canary alarm", err)
+			return
+		}
+
+		for _, alarm := range stack.Canary.Alarms {
+			if !tool.IsStringInArray(alarm, existing) {
+				report("canary alarm", fmt.Errorf("alarm does not exist : %s", alarm))
+			}
+		}
+	}
+}
+
+// checkNetwork checks the VPC, security groups and subnets of the region
+// It returns availability zones in which instances would be launched.
+func checkNetwork(client aws.AWSClient, region builder.RegionConfig, report reportFunc) []string {
+	// Security groups and subnets are looked up in the VPC
+	vpcId, err := client.EC2Service.GetVPCId(region.VPC)
+	if err != nil {
+		report("vpc", err)
+		return nil
+	}
+
+	for _, sg := range region.SecurityGroups {
+		if _, err := client.EC2Service.GetSecurityGroupList(vpcId, []string{sg}); err != nil {
+			report("security group", err)
+		}
+	}
+
+	azs, err := client.EC2Service.GetAvailabilityZones(vpcId, region.AvailabilityZones)
+	if err != nil {
+		report("availability zones", err)
+		return nil
+	}
+
+	if len(azs) == 0 {
+		report("availability zones", fmt.Errorf("no subnet exists in VPC : %s", region.VPC))
+	}
+
+	for _, az := range region.AvailabilityZones {
+		if !tool.IsStringInArray(az, azs) {
+			report("availability zones", fmt.Errorf("no subnet of VPC %s exists in %s", region.VPC, az))
+		}
+	}
+
+	// Subnets are checked zone by zone so that a zone without subnet is not hidden by others
+	for _, az := range azs {
+		if _, err := client.EC2Service.GetSubnets(vpcId, region.UsePublicSubnets, []string{az}); err != nil {
+			report("subnets", err)
+		}
+	}
+
+	return azs
+}
+
+// checkAlarmActions checks that actions of alarms refer to scaling policies of the stack
+func checkAlarmActions(stack builder.Stack, report reportFunc) {
+	policies := []string{}
+	for _, policy := range stack.Autoscaling {
+		policies = append(policies, policy.Name)
+	}
+
+	for _, alarm := range stack.Alarms {
+		for _, action := range alarm.AlarmActions {
+			if !tool.IsStringInArray(action, policies) {
+				report("alarm", fmt.Errorf("%s refers to the scaling policy which does not exist : %s", alarm.Name, action))
+			}
+		}
+	}
+}
+
+// instanceTypes returns instance types which instances of the region could have
+// --override-instance-type is not applied if mixed instances policy is enabled.
+func instanceTypes(config builder.Config, stack builder.Stack, region builder.RegionConfig) []string {
+	ret := []string{region.InstanceType}
+	if len(config.OverrideInstanceType) > 0 && !stack.MixedInstancesPolicy.Enabled {
+		ret = []string{config.OverrideInstanceType}
+	}
+
+	if stack.MixedInstancesPolicy.Enabled {
+		for _, instanceType := range stack.MixedInstancesPolicy.Override {
+			if !tool.IsStringInArray(instanceType, ret) {
+				ret = append(ret, instanceType)
+			}
+		}
+	}
+
+	return ret
+}
+
+// targetGroups returns every target group which the deployment uses in the region
+func targetGroups(region builder.RegionConfig) []string {
+	ret := []string{}
+	groups := append([]string{region.HealthcheckTargetGroup}, region.TargetGroups...)
+	for _, tg := range append(groups, region.WeightedTargetGroups...) {
+		if len(tg) > 0 && !tool.IsStringInArray(tg, ret) {
+			ret = append(ret, tg)
+		}
+	}
+
+	return ret
+}
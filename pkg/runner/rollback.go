@@ -0,0 +1,206 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/collector"
+	"github.com/DevopsArtFactory/goployer/pkg/deployer"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"time"
+)
+
+// Rollback reactivates the previous version of the stack and retires the current version
+func (r Runner) Rollback() error {
+	stack, err := r.targetStack()
+	if err != nil {
+		return err
+	}
+
+	if err := checkRollbackType(stack); err != nil {
+		return err
+	}
+
+	ctx, release, err := r.acquireLocks(context.Background(), []builder.Stack{stack})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	r.Logger.Info("Beginning rollback: ", r.Builder.AwsConfig.Name)
+	r.Slacker.SendSimpleMessage(fmt.Sprintf(":rewind: Rollback starts : %s/%s", r.Builder.AwsConfig.Name, stack.Stack), r.Builder.Config.Env)
+
+	for _, region := range stack.Regions {
+		if r.Builder.Config.Region != "" && r.Builder.Config.Region != region.Region {
+			r.Logger.Debug("This region is skipped by user : " + region.Region)
+			continue
+		}
+
+		if err := r.rollbackRegion(ctx, stack, region.Region); err != nil {
+			r.Slacker.SendSimpleMessage(fmt.Sprintf(":x: Rollback failed in %s : %s", region.Region, err.Error()), r.Builder.Config.Env)
+			return err
+		}
+	}
+
+	r.Slacker.SendSimpleMessage(":100: Rollback is done.", r.Builder.Config.Env)
+
+	return nil
+}
+
+// rollbackRegion reactivates the previous version in a single region
+func (r Runner) rollbackRegion(ctx context.Context, stack builder.Stack, region string) error {
+	client := r.Bootstrap(region, stack.AssumeRole)
+	prefix := tool.BuildPrefixName(r.Builder.AwsConfig.Name, stack.Env, region)
+
+	asgGroups, err := client.EC2Service.GetAllMatchingAutoscalingGroupsWithPrefix(prefix)
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]*autoscaling.Group{}
+	current := ""
+	for _, asgGroup := range asgGroups {
+		existing[*asgGroup.AutoScalingGroupName] = asgGroup
+		if len(current) == 0 || tool.ParseVersion(*asgGroup.AutoScalingGroupName) > tool.ParseVersion(current) {
+			current = *asgGroup.AutoScalingGroupName
+		}
+	}
+
+	if len(current) == 0 {
+		return fmt.Errorf("no current version exists to roll back : %s", prefix)
+	}
+
+	target, record, err := r.findPreviousVersion(prefix, tool.ParseVersion(current), existing)
+	if err != nil {
+		return err
+	}
+	r.Logger.Infof("Rollback from %s to %s", current, target)
+
+	config := r.Builder.Config
+	config.Region = region
+	config.StartTimestamp = time.Now().Unix()
+	config.ReleaseNotes = fmt.Sprintf("rollback to %s", target)
+	config.ReleaseNotesBase64 = ""
+	// Rollback runs in a single region, so the failure cannot be isolated from other regions
+	config.RegionFailurePolicy = builder.REGION_FAILURE_POLICY_STOP
+
+	if record != nil {
+		// The stack could be changed to rolling update after the previous version was deployed
+		if err := checkRollbackType(record.Stack); err != nil {
+			return err
+		}
+
+		stack = record.Stack
+		config.Ami = record.Config.Ami
+		config.OverrideInstanceType = record.Config.OverrideInstanceType
+		config.ExtraTags = record.Config.ExtraTags
+		config.AnsibleExtraVars = record.Config.AnsibleExtraVars
+	}
+
+	// Previous version of canary is also restored with the whole capacity at once
+	d := deployer.NewBlueGrean(builder.REPLACEMENT_TYPE_BLUEGREEN, r.Logger, r.Builder.AwsConfig, stack, r.bootstrapClients(stack))
+	d.Slack = r.Slacker
+	d.Collector = r.Collector
+
+	deployers := []deployer.DeployManager{d}
+
+	if _, ok := existing[target]; ok {
+		// The previous autoscaling group still exists so that it only needs to be scaled up
+		// Like deployment, the current capacity is kept if it is larger than the recorded one.
+		capacity := stack.Capacity
+		if cur := existing[current]; !config.ForceManifestCapacity && *cur.DesiredCapacity > capacity.Desired {
+			capacity = builder.Capacity{Min: *cur.MinSize, Max: *cur.MaxSize, Desired: *cur.DesiredCapacity}
+			r.Logger.Infof("Current desired instance count is larger than the recorded capacity of %s", target)
+		}
+
+		if err := d.ReactivateVersion(client, region, target, capacity); err != nil {
+			return err
+		}
+
+		if err := doHealthchecking(ctx, deployers, config); err != nil {
+			return err
+		}
+	} else {
+		if record == nil {
+			return fmt.Errorf("no deployment record exists to recreate %s", target)
+		}
+
+		// Launch template and autoscaling group are recreated with the stamped configuration
+		r.Logger.Infof("%s does not exist anymore, it will be recreated as a new version", target)
+		if len(record.Userdata) > 0 {
+			d.LocalProvider = builder.EncodedProvider{Userdata: record.Userdata}
+		}
+		if err := d.Deploy(ctx, config); err != nil {
+			r.rollback(deployers, err)
+			return err
+		}
+
+		if err := doHealthchecking(ctx, deployers, config); err != nil {
+			r.rollback(deployers, err)
+			return err
+		}
+	}
+
+	if err := d.FinishAdditionalWork(config); err != nil {
+		return err
+	}
+
+	if err := d.CleanPreviousVersion(config); err != nil {
+		return err
+	}
+
+	return cleanChecking(ctx, deployers, config)
+}
+
+// checkRollbackType checks that the previous version of the stack exists as another autoscaling group
+func checkRollbackType(stack builder.Stack) error {
+	if stack.ReplacementType == builder.REPLACEMENT_TYPE_ROLLING {
+		return fmt.Errorf("rollback is not supported for %s because instances are replaced in the same autoscaling group, deploy again with the previous AMI instead : %s", builder.REPLACEMENT_TYPE_ROLLING, stack.Stack)
+	}
+
+	return nil
+}
+
+// targetStack returns the stack passed from command line
+// Only one stack can be selected for commands other than deploy.
+func (r Runner) targetStack() (builder.Stack, error) {
+	if len(r.Builder.Config.StackNames()) > 1 {
+		return builder.Stack{}, fmt.Errorf("only one stack can be selected : %s", r.Builder.Config.Stack)
+	}
+
+	for _, s := range r.Builder.Stacks {
+		if s.Stack == r.Builder.Config.Stack {
+			return s, nil
+		}
+	}
+
+	return builder.Stack{}, fmt.Errorf("no stack exists in manifest : %s", r.Builder.Config.Stack)
+}
+
+// findPreviousVersion finds the latest version before the current one which can be reactivated
+func (r Runner) findPreviousVersion(prefix string, current int, existing map[string]*autoscaling.Group) (string, *collector.DeploymentRecord, error) {
+	for i := 1; i < 100; i++ {
+		name := tool.GenerateAsgName(prefix, (current-i+100)%100)
+
+		var record *collector.DeploymentRecord
+		if r.Builder.MetricConfig.Enabled {
+			rec, err := r.Collector.GetDeploymentRecord(name)
+			if err != nil {
+				return "", nil, err
+			}
+
+			// Versions which were already rolled back are not the target
+			if rec != nil && rec.Status == "rolled_back" {
+				continue
+			}
+			record = rec
+		}
+
+		if _, ok := existing[name]; ok || record != nil {
+			return name, record, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("no previous version found : %s", prefix)
+}
@@ -0,0 +1,350 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/collector"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	sdk "github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	testRegion = "ap-northeast-2"
+	testTable  = "goployer-metrics"
+)
+
+// newTestRunner creates a runner of which AWS services and metric storage are in the fake cloud
+func newTestRunner(t *testing.T, cloud *fake.Cloud, stack, ami string) Runner {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "goployer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	config := builder.NewConfig()
+	config.Manifest = "testdata/hello.yaml"
+	config.Stack = stack
+	config.Region = testRegion
+	config.Ami = ami
+	config.SlackOff = true
+	config.PollingInterval = builder.MIN_POLLING_INTERVAL
+	config.StateFile = filepath.Join(dir, "state.json")
+
+	b, err := builder.NewBuilder(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.MetricConfig = builder.MetricConfig{
+		Enabled: true,
+		Region:  testRegion,
+		Storage: builder.Storage{Type: "dynamodb", Name: testTable},
+	}
+
+	r, err := NewRunnerWithBootstrap(b, cloud.Bootstrap, cloud.MetricBootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Logger.SetOutput(ioutil.Discard)
+
+	if err := r.Collector.CheckStorage(r.Logger); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+// currentGroup returns the only autoscaling group of the stack
+func currentGroup(t *testing.T, cloud *fake.Cloud) string {
+	t.Helper()
+
+	names := []string{}
+	for name := range cloud.AutoScalingGroups {
+		names = append(names, name)
+	}
+
+	if len(names) != 1 {
+		t.Fatalf("autoscaling groups = %v, want only one", names)
+	}
+
+	return names[0]
+}
+
+// regionGroups returns sorted names of autoscaling groups with the prefix
+func regionGroups(cloud *fake.Cloud, prefix string) []string {
+	names := []string{}
+	for name := range cloud.AutoScalingGroups {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestRunBlueGreenAndRollback(t *testing.T) {
+	cloud := fake.NewCloud()
+	prefix := tool.BuildPrefixName("hello", "dev", testRegion)
+
+	if err := newTestRunner(t, cloud, "artd", "ami-00000000000000001").Run(context.Background()); err != nil {
+		t.Fatalf("first deployment failed : %v", err)
+	}
+
+	first := currentGroup(t, cloud)
+	if want := tool.GenerateAsgName(prefix, 0); first != want {
+		t.Fatalf("first version = %s, want %s", first, want)
+	}
+
+	if desired := sdk.Int64Value(cloud.AutoScalingGroups[first].DesiredCapacity); desired != 2 {
+		t.Errorf("desired capacity of %s = %d, want 2", first, desired)
+	}
+
+	if err := newTestRunner(t, cloud, "artd", "ami-00000000000000002").Run(context.Background()); err != nil {
+		t.Fatalf("second deployment failed : %v", err)
+	}
+
+	second := currentGroup(t, cloud)
+	if want := tool.GenerateAsgName(prefix, 1); second != want {
+		t.Fatalf("second version = %s, want %s", second, want)
+	}
+
+	record, err := newTestRunner(t, cloud, "artd", "").Collector.GetDeploymentRecord(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record == nil || record.Status != "deployed" {
+		t.Fatalf("record of %s = %+v, want deployed", second, record)
+	}
+
+	// The first version was deleted so that rollback recreates it as a new version with the stamped AMI
+	if err := newTestRunner(t, cloud, "artd", "").Rollback(); err != nil {
+		t.Fatalf("rollback failed : %v", err)
+	}
+
+	restored := currentGroup(t, cloud)
+	if want := tool.GenerateAsgName(prefix, 2); restored != want {
+		t.Fatalf("restored version = %s, want %s", restored, want)
+	}
+
+	group := cloud.AutoScalingGroups[restored]
+	if desired := sdk.Int64Value(group.DesiredCapacity); desired != 2 {
+		t.Errorf("desired capacity of %s = %d, want 2", restored, desired)
+	}
+
+	lt := cloud.LaunchTemplates[sdk.StringValue(group.LaunchTemplate.LaunchTemplateName)]
+	if lt.Ami != "ami-00000000000000001" {
+		t.Errorf("AMI of %s = %s, want ami-00000000000000001", restored, lt.Ami)
+	}
+
+	if holder, err := newTestRunner(t, cloud, "artd", "").Collector.GetLockHolder(prefix); err != nil || len(holder) > 0 {
+		t.Errorf("lock holder after rollback = %q, %v, want no lock", holder, err)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	cloud := fake.NewCloud()
+	prefix := tool.BuildPrefixName("hello", "dev", testRegion)
+
+	if err := newTestRunner(t, cloud, "artd", "ami-00000000000000001").Run(context.Background()); err != nil {
+		t.Fatalf("first deployment failed : %v", err)
+	}
+	first := currentGroup(t, cloud)
+	launchTemplates := len(cloud.LaunchTemplates)
+	items := len(cloud.Tables[testTable])
+
+	r := newTestRunner(t, cloud, "artd", "ami-00000000000000002")
+	r.Builder.Config.DryRun = true
+	r, err := NewRunnerWithBootstrap(r.Builder, cloud.Bootstrap, cloud.MetricBootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Logger.SetOutput(ioutil.Discard)
+
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("dry-run failed : %v", err)
+	}
+
+	if current := currentGroup(t, cloud); current != first {
+		t.Errorf("autoscaling group after dry-run = %s, want %s", current, first)
+	}
+
+	if len(cloud.LaunchTemplates) != launchTemplates {
+		t.Errorf("launch templates = %d, want %d", len(cloud.LaunchTemplates), launchTemplates)
+	}
+
+	if len(cloud.Tables[testTable]) != items {
+		t.Errorf("items of %s = %d, want %d", testTable, len(cloud.Tables[testTable]), items)
+	}
+
+	second := tool.GenerateAsgName(prefix, 1)
+	summary := r.Recorder.Summary()
+	for _, want := range []string{
+		"[" + testRegion + "] CreateLaunchTemplate : " + second,
+		"[" + testRegion + "] CreateAutoScalingGroup : " + second,
+		"[" + testRegion + "] PutItem : " + second,
+		"Autoscaling groups to be deleted : " + first,
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary of dry-run does not have %q\n%s", want, summary)
+		}
+	}
+}
+
+func TestRollbackRejectsRolling(t *testing.T) {
+	cloud := fake.NewCloud()
+
+	err := newTestRunner(t, cloud, "artr", "").Rollback()
+	if err == nil || !strings.Contains(err.Error(), "rollback is not supported for Rolling") {
+		t.Fatalf("rollback of rolling stack = %v, want error of replacement type", err)
+	}
+
+	if len(cloud.AutoScalingGroups) != 0 {
+		t.Errorf("autoscaling groups = %d, want nothing created", len(cloud.AutoScalingGroups))
+	}
+}
+
+func TestRunRollsBackWhenLockIsLost(t *testing.T) {
+	ttl := collector.DEFAULT_LOCK_TTL
+	collector.DEFAULT_LOCK_TTL = 300 * time.Millisecond
+	defer func() { collector.DEFAULT_LOCK_TTL = ttl }()
+
+	cloud := fake.NewCloud()
+	prefix := tool.BuildPrefixName("hello", "dev", testRegion)
+
+	if err := newTestRunner(t, cloud, "artd", "ami-00000000000000001").Run(context.Background()); err != nil {
+		t.Fatalf("first deployment failed : %v", err)
+	}
+	first := currentGroup(t, cloud)
+
+	// The new version never becomes healthy, and other process takes the lock away during health check
+	cloud.DefaultTargetHealth = "initial"
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cloud.MetricClient(testRegion).DynamoDBService.ReleaseLock("lock:"+prefix, "", testTable)
+	}()
+
+	err := newTestRunner(t, cloud, "artd", "ami-00000000000000002").Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "deployment lock is lost") {
+		t.Fatalf("deployment = %v, want error of lost lock", err)
+	}
+
+	if current := currentGroup(t, cloud); current != first {
+		t.Errorf("autoscaling group after rollback = %s, want %s", current, first)
+	}
+}
+
+func TestResumeDeletesUnrecordedVersion(t *testing.T) {
+	cloud := fake.NewCloud()
+	prefix := tool.BuildPrefixName("hello", "dev", testRegion)
+
+	if err := newTestRunner(t, cloud, "artd", "ami-00000000000000001").Run(context.Background()); err != nil {
+		t.Fatalf("first deployment failed : %v", err)
+	}
+	first := currentGroup(t, cloud)
+	cloud.AutoScalingGroups[first].CreatedTime = sdk.Time(time.Now().Add(-time.Hour))
+
+	// goployer stopped right after the autoscaling group was created, so the state has nothing
+	r := newTestRunner(t, cloud, "artd", "ami-00000000000000002")
+	state := newRunState(r.Builder.Config)
+	state.Config.StartTimestamp = time.Now().Add(-time.Minute).Unix()
+	state.resumed = true
+
+	stopped := tool.GenerateAsgName(prefix, 1)
+	group := *cloud.AutoScalingGroups[first]
+	group.AutoScalingGroupName = sdk.String(stopped)
+	group.CreatedTime = sdk.Time(time.Now())
+	group.Instances = nil
+	cloud.AutoScalingGroups[stopped] = &group
+
+	if err := r.Resume(context.Background(), state); err != nil {
+		t.Fatalf("resume failed : %v", err)
+	}
+
+	current := currentGroup(t, cloud)
+	if current != stopped {
+		t.Fatalf("version after resume = %s, want %s", current, stopped)
+	}
+
+	lt := cloud.LaunchTemplates[sdk.StringValue(cloud.AutoScalingGroups[current].LaunchTemplate.LaunchTemplateName)]
+	if lt.Ami != "ami-00000000000000002" {
+		t.Errorf("AMI of %s = %s, want the version created again with ami-00000000000000002", current, lt.Ami)
+	}
+}
+
+func TestRunWithRegionFailurePolicy(t *testing.T) {
+	const failedRegion = "us-east-1"
+
+	tests := []struct {
+		name   string
+		policy string
+		err    string
+		// versions which remain in each region after the deployment of the second version
+		want map[string][]int
+	}{
+		{
+			name:   "stop",
+			policy: builder.REGION_FAILURE_POLICY_STOP,
+			err:    "healthchecking failed in artm(us-east-1)",
+			want:   map[string][]int{testRegion: {0}, failedRegion: {0}},
+		},
+		{
+			name:   "continue",
+			policy: builder.REGION_FAILURE_POLICY_CONTINUE,
+			err:    "deployment failed in 1 region(s) and they are rolled back",
+			want:   map[string][]int{testRegion: {1}, failedRegion: {0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cloud := fake.NewCloud()
+			run := func(ami string) error {
+				r := newTestRunner(t, cloud, "artm", ami)
+				r.Builder.Config.Region = ""
+				r.Builder.Config.RegionFailurePolicy = test.policy
+				// Regions which are not healthy at the first healthcheck are timed out
+				r.Builder.Config.StartTimestamp = time.Now().Add(-time.Hour).Unix()
+				r.Builder.Config.Timeout = time.Minute
+
+				return r.Run(context.Background())
+			}
+
+			if err := run("ami-00000000000000001"); err != nil {
+				t.Fatalf("first deployment failed : %v", err)
+			}
+
+			// New instances never become healthy in one region
+			cloud.RegionTargetHealth[failedRegion] = "unhealthy"
+
+			err := run("ami-00000000000000002")
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("deployment = %v, want %q", err, test.err)
+			}
+
+			for region, versions := range test.want {
+				prefix := tool.BuildPrefixName("hello", "multi", region)
+				want := []string{}
+				for _, version := range versions {
+					want = append(want, tool.GenerateAsgName(prefix, version))
+				}
+
+				if got := regionGroups(cloud, prefix); !reflect.DeepEqual(got, want) {
+					t.Errorf("autoscaling groups in %s = %v, want %v", region, got, want)
+				}
+			}
+		})
+	}
+}
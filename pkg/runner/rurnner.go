@@ -1,12 +1,16 @@
 package runner
 
 import (
+	"context"
 	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
 	"github.com/DevopsArtFactory/goployer/pkg/collector"
 	"github.com/DevopsArtFactory/goployer/pkg/deployer"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 	Logger "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
 	"time"
 
 	"os"
@@ -17,6 +21,8 @@ type Runner struct {
 	Builder   builder.Builder
 	Collector collector.Collector
 	Slacker   tool.Slack
+	Recorder  *aws.Recorder
+	Bootstrap aws.Bootstrapper
 }
 
 var (
@@ -30,48 +36,234 @@ var (
 	}
 )
 
-//Start function is the starting point of all processes.
-func Start() error {
-	// Check OS first
-	//if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
-	//	return errors.New("you cannot run from local command.")
-	//}
-
-	// Create new builder
-	builderSt, err := builder.NewBuilder()
+// Deploy is the starting point of deployment
+func Deploy(config builder.Config) error {
+	builderSt, err := setupBuilder(config, !config.DryRun)
 	if err != nil {
 		return err
 	}
 
-	m, err := builder.ParseMetricConfig(builderSt.Config.DisableMetrics)
+	// run with runner
+	return withRunner(builderSt, Runner.Run, func(slacker tool.Slack) error {
+		// These are post actions after deployment
+		slacker.SendSimpleMessage(":100: Deployment is done.", builderSt.Config.Env)
+		return nil
+	})
+}
+
+// Resume continues the deployment which stopped in the middle with the state file
+func Resume(config builder.Config) error {
+	state, err := LoadRunState(config)
 	if err != nil {
 		return err
 	}
 
-	builderSt.MetricConfig = m
+	// Options of the stopped deployment are applied again
+	saved := state.Config
+	saved.StartTimestamp = time.Now().Unix()
+	saved.LogLevel = config.LogLevel
 
-	// Check validation of configurations
-	if err := builderSt.CheckValidation(); err != nil {
+	builderSt, err := setupBuilder(saved, !saved.DryRun)
+	if err != nil {
 		return err
 	}
 
-	// run with runner
-	return withRunner(builderSt, func(slacker tool.Slack) error {
-		// These are post actions after deployment
+	return withRunner(builderSt, func(r Runner, ctx context.Context) error {
+		return r.Resume(ctx, state)
+	}, func(slacker tool.Slack) error {
 		slacker.SendSimpleMessage(":100: Deployment is done.", builderSt.Config.Env)
 		return nil
 	})
 }
 
+// Rollback reactivates the previous version of the stack
+func Rollback(config builder.Config) error {
+	builderSt, err := setupBuilder(config, false)
+	if err != nil {
+		return err
+	}
+
+	runner, err := NewRunner(builderSt)
+	if err != nil {
+		return err
+	}
+	runner.LogFormatting(builderSt.Config.LogLevel)
+
+	return runner.Rollback()
+}
+
+// Delete removes every version of the stack
+func Delete(config builder.Config) error {
+	builderSt, err := setupBuilder(config, false)
+	if err != nil {
+		return err
+	}
+
+	runner, err := NewRunner(builderSt)
+	if err != nil {
+		return err
+	}
+	runner.LogFormatting(builderSt.Config.LogLevel)
+
+	return runner.Delete()
+}
+
+// Unlock deletes deployment locks of the stack
+func Unlock(config builder.Config) error {
+	builderSt, err := setupBuilder(config, false)
+	if err != nil {
+		return err
+	}
+
+	runner, err := NewRunner(builderSt)
+	if err != nil {
+		return err
+	}
+	runner.LogFormatting(builderSt.Config.LogLevel)
+
+	if err := runner.Collector.CheckStorage(runner.Logger); err != nil {
+		return err
+	}
+
+	return runner.Unlock()
+}
+
+// Validate checks the manifest and options without changing any resources
+func Validate(config builder.Config) error {
+	builderSt, err := setupBuilder(config, !config.SkipPreflight)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(builderSt.MakeSummary(builderSt.Config.StackNames()))
+
+	// Resources in AWS are checked with the assume role of each stack
+	if !builderSt.Config.SkipPreflight {
+		runner, err := NewRunner(builderSt)
+		if err != nil {
+			return err
+		}
+		runner.LogFormatting(builderSt.Config.LogLevel)
+
+		problems, err := runner.Preflight()
+		if err != nil {
+			return err
+		}
+
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Println(problem.String())
+			}
+			return fmt.Errorf("%d problems are found in preflight checks", len(problems))
+		}
+	}
+
+	fmt.Printf("manifest is valid : %s\n", builderSt.Config.Manifest)
+
+	return nil
+}
+
+// Status prints autoscaling groups of every version of the stack
+func Status(config builder.Config) error {
+	builderSt, err := setupBuilder(config, false)
+	if err != nil {
+		return err
+	}
+
+	runner, err := NewRunner(builderSt)
+	if err != nil {
+		return err
+	}
+	runner.LogFormatting(builderSt.Config.LogLevel)
+
+	status, err := runner.Status()
+	if err != nil {
+		return err
+	}
+
+	if err := PrintStatus(os.Stdout, status, builderSt.Config.Output); err != nil {
+		return err
+	}
+
+	failed := []string{}
+	for _, region := range status.Regions {
+		if len(region.Error) > 0 {
+			failed = append(failed, region.Region)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to look up status in %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// History prints past deployments of the stack
+func History(config builder.Config) error {
+	builderSt, err := setupBuilder(config, false)
+	if err != nil {
+		return err
+	}
+
+	runner, err := NewRunner(builderSt)
+	if err != nil {
+		return err
+	}
+	runner.LogFormatting(builderSt.Config.LogLevel)
+
+	records, err := runner.History()
+	if err != nil {
+		return err
+	}
+
+	return PrintHistory(os.Stdout, records, builderSt.Config.Output)
+}
+
+// setupBuilder creates builder and checks validation of configurations
+// If checkUserdata is true, userdata in S3, SSM or Secrets Manager is read in every region to check it.
+// Commands which do not create launch templates do not read it.
+func setupBuilder(config builder.Config, checkUserdata bool) (builder.Builder, error) {
+	// Create new builder
+	builderSt, err := builder.NewBuilder(config)
+	if err != nil {
+		return builderSt, err
+	}
+
+	m, err := builder.ParseMetricConfig(builderSt.Config.DisableMetrics)
+	if err != nil {
+		return builderSt, err
+	}
+
+	builderSt.MetricConfig = m
+
+	// Userdata in AWS is checked with the region and assume role of each stack
+	if checkUserdata {
+		builderSt.UserdataBootstrap = func(region string, assume_role string) builder.UserdataClients {
+			return aws.BootstrapServices(region, assume_role).UserdataClients()
+		}
+	}
+
+	// Check validation of configurations
+	if err := builderSt.CheckValidation(); err != nil {
+		return builderSt, err
+	}
+
+	return builderSt, nil
+}
+
 //withRunner creates runner and runs the deployment process
-func withRunner(builder builder.Builder, postAction func(slacker tool.Slack) error) error {
+func withRunner(builder builder.Builder, run func(r Runner, ctx context.Context) error, postAction func(slacker tool.Slack) error) error {
 	runner, err := NewRunner(builder)
 	if err != nil {
 		return err
 	}
 	runner.LogFormatting(builder.Config.LogLevel)
 
-	if err := runner.Run(); err != nil {
+	ctx, stop := withInterrupt(context.Background(), runner.Logger)
+	defer stop()
+
+	if err := run(runner, ctx); err != nil {
 		return err
 	}
 
@@ -82,12 +274,27 @@ func withRunner(builder builder.Builder, postAction func(slacker tool.Slack) err
 
 //NewRunner creates a new runner
 func NewRunner(newBuilder builder.Builder) (Runner, error) {
-	return Runner{
+	return NewRunnerWithBootstrap(newBuilder, aws.BootstrapServices, aws.BootstrapMetricService)
+}
+
+// NewRunnerWithBootstrap creates a new runner of which clients are created by the bootstrappers
+// Tests use it to run the whole deployment against fake services.
+func NewRunnerWithBootstrap(newBuilder builder.Builder, bootstrap aws.Bootstrapper, metricBootstrap aws.MetricBootstrapper) (Runner, error) {
+	r := Runner{
 		Logger:    Logger.New(),
 		Builder:   newBuilder,
-		Collector: collector.NewCollector(newBuilder.MetricConfig, ""),
-		Slacker:   tool.NewSlackClient(newBuilder.Config.SlackOff),
-	}, nil
+		Collector: collector.NewCollectorWithBootstrap(newBuilder.MetricConfig, "", metricBootstrap),
+		Slacker:   tool.NewSlackClient(newBuilder.Config.SlackOff || newBuilder.Config.DryRun),
+		Bootstrap: bootstrap,
+	}
+
+	// In dry-run mode, mutating calls are recorded and printed instead of being executed
+	if newBuilder.Config.DryRun {
+		r.Recorder = aws.NewRecorder()
+		r.Collector.MetricClient = r.Collector.MetricClient.WithRecorder(r.Recorder)
+	}
+
+	return r, nil
 }
 
 // Set log format
@@ -98,18 +305,34 @@ func (r Runner) LogFormatting(logLevel string) {
 }
 
 // Run executes all required steps for deployments
-func (r Runner) Run() error {
+func (r Runner) Run(ctx context.Context) error {
+	return r.execute(ctx, newRunState(r.Builder.Config))
+}
+
+// Resume continues the deployment from the last completed phase in the state
+func (r Runner) Resume(ctx context.Context, state *RunState) error {
+	r.Logger.Infof("Resuming deployment, completed phases : %s", strings.Join(state.Phases, ", "))
+	return r.execute(ctx, state)
+}
+
+// execute runs steps of deployment which are not completed in the state
+// Errors from deployers are returned to the caller after rollback or failure notification.
+// If ctx is cancelled, the new version is rolled back or abandoned according to the option.
+func (r Runner) execute(ctx context.Context, state *RunState) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			Logger.Error(err)
-			os.Exit(1)
+		if p := recover(); p != nil {
+			err = fmt.Errorf("unexpected error during deployment : %v", p)
+		}
+
+		if err != nil {
+			r.Slacker.SendSimpleMessage(fmt.Sprintf(":x: Deployment failed : %s", err.Error()), r.Builder.Config.Env)
 		}
 	}()
 
 	//Send Beginning Message
 	r.Logger.Info("Beginning deployment: ", r.Builder.AwsConfig.Name)
 
-	msg := r.Builder.MakeSummary(r.Builder.Config.Stack)
+	msg := r.Builder.MakeSummary(r.Builder.Config.StackNames())
 	fmt.Println(msg)
 	if r.Slacker.ValidClient() {
 		r.Logger.Debug("slack configuration is valid")
@@ -135,90 +358,398 @@ func (r Runner) Run() error {
 	r.Logger.Debug("create deployers for stacks")
 
 	//Prepare deployers
+	stacks, err := r.Builder.GetTargetStacks()
+	if err != nil {
+		return err
+	}
+
+	// Deployment fails halfway if the assume role lacks permissions, so they are checked first
+	if !r.Builder.Config.SkipPermissionCheck {
+		if err := r.checkPermissions(stacks); err != nil {
+			return err
+		}
+	}
+
+	stackWaves, err := builder.MakeWaves(stacks, r.Builder.Config.ParallelStacks)
+	if err != nil {
+		return err
+	}
+
+	// Stacks of the next wave are deployed after stacks of the current wave are healthy
+	waves := [][]deployer.DeployManager{}
 	deployers := []deployer.DeployManager{}
-	for _, stack := range r.Builder.Stacks {
-		// If target stack is passed from command, then
-		// Skip other stacks
-		if r.Builder.Config.Stack != "" && stack.Stack != r.Builder.Config.Stack {
-			Logger.Debugf("Skipping this stack, stack=%s", stack.Stack)
-			continue
+	for i, stackWave := range stackWaves {
+		wave := []deployer.DeployManager{}
+		names := []string{}
+		for _, stack := range stackWave {
+			d := getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.bootstrapClients(stack), r.Slacker, r.Collector, r.Recorder)
+			wave = append(wave, d)
+			deployers = append(deployers, d)
+			names = append(names, stack.Stack)
 		}
-		d := getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Slacker, r.Collector)
-		deployers = append(deployers, d)
+		waves = append(waves, wave)
+		r.Logger.Debugf("wave %d : %s", i+1, strings.Join(names, ", "))
 	}
 
-	// Deploy
-	for _, deployer := range deployers {
-		deployer.Deploy(r.Builder.Config)
+	// Other deployments of the same app and env cannot run at the same time
+	// If a lock is lost, deployment stops and new versions are rolled back.
+	lockCtx, release, err := r.acquireLocks(ctx, stacks)
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	// healthcheck
-	doHealthchecking(deployers, r.Builder.Config)
+	state.Restore(deployers)
 
-	// Attach scaling policy
-	for _, deployer := range deployers {
-		deployer.FinishAdditionalWork(r.Builder.Config)
+	// If any wave fails, new versions of every wave are rolled back
+	for i, wave := range waves {
+		if len(waves) > 1 {
+			r.Logger.Infof("Wave %d/%d starts", i+1, len(waves))
+		}
+
+		if err := r.deployWave(lockCtx, i, stackWaves[i], wave, deployers, state); err != nil {
+			if ctx.Err() == nil && lockCtx.Err() != nil {
+				err = fmt.Errorf("deployment lock is lost : %s", err.Error())
+			}
+			return r.handleFailure(ctx, deployers, state, err)
+		}
 	}
 
+	// New versions are serving now, so previous versions are kept as they are if cleaning fails.
 	// Trigger Lifecycle Callbacks
-	for _, deployer := range deployers {
-		deployer.TriggerLifecycleCallbacks(r.Builder.Config)
+	if !state.Completed(PHASE_LIFECYCLE_CALLBACKS) {
+		for _, deployer := range deployers {
+			if err := deployer.TriggerLifecycleCallbacks(r.Builder.Config); err != nil {
+				return err
+			}
+		}
+		r.saveState(state, PHASE_LIFECYCLE_CALLBACKS, deployers)
 	}
 
 	// Clear previous Version
-	for _, deployer := range deployers {
-		deployer.CleanPreviousVersion(r.Builder.Config)
+	if !state.Completed(PHASE_CLEAN) {
+		for _, deployer := range deployers {
+			if err := deployer.CleanPreviousVersion(r.Builder.Config); err != nil {
+				return err
+			}
+		}
+		r.saveState(state, PHASE_CLEAN, deployers)
 	}
 
 	// Checking all previous version before delete asg
-	cleanChecking(deployers, r.Builder.Config)
+	if err := cleanChecking(lockCtx, deployers, r.Builder.Config); err != nil {
+		return err
+	}
+
+	r.removeState(state)
+
+	if r.Recorder.Enabled() {
+		fmt.Println(r.Recorder.Summary())
+	}
+
+	// Regions rolled back with the continue policy fail the deployment after other regions are done
+	return failedRegionsError(deployers)
+}
+
+// failedRegionsError returns an error which reports regions rolled back during deployment
+func failedRegionsError(deployers []deployer.DeployManager) error {
+	failed := []string{}
+	for _, d := range deployers {
+		for _, err := range d.FailedRegions() {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	sort.Strings(failed)
+
+	return fmt.Errorf("deployment failed in %d region(s) and they are rolled back\n%s", len(failed), strings.Join(failed, "\n"))
+}
+
+// deployWave deploys new versions of stacks in the wave and attaches scaling policies after they are healthy
+func (r Runner) deployWave(ctx context.Context, index int, stacks []builder.Stack, wave, deployers []deployer.DeployManager, state *RunState) error {
+	// Deploy
+	if !state.Completed(wavePhase(PHASE_DEPLOY, index)) {
+		// New versions of some stacks may be created before goployer stopped
+		// Recorded versions are rolled back after this error, and versions which are not recorded are deleted here.
+		if state.resumed {
+			deleted, err := r.cleanStoppedVersions(stacks, state)
+			if err != nil {
+				return err
+			}
+
+			if state.Started(wave) {
+				return fmt.Errorf("deployment stopped before every stack was deployed, please deploy again")
+			}
+
+			if len(deleted) > 0 {
+				r.Logger.Infof("Deployment starts again after autoscaling groups of the stopped deployment are deleted : %s", strings.Join(deleted, ", "))
+			}
+		}
+
+		for _, deployer := range wave {
+			if err := deployer.Deploy(ctx, r.Builder.Config); err != nil {
+				return err
+			}
+			r.saveState(state, "", deployers)
+		}
+		r.saveState(state, wavePhase(PHASE_DEPLOY, index), deployers)
+	}
+
+	// healthcheck
+	// New instances are not launched in dry-run mode so there is nothing to check.
+	if !state.Completed(wavePhase(PHASE_HEALTHCHECK, index)) {
+		if r.Recorder.Enabled() {
+			r.Logger.Info("[dry-run] Healthchecking is skipped")
+		} else if err := doHealthchecking(ctx, wave, r.Builder.Config); err != nil {
+			r.Logger.Errorf("healthchecking failed : %s", err.Error())
+			return err
+		}
+		r.saveState(state, wavePhase(PHASE_HEALTHCHECK, index), deployers)
+	}
+
+	// Attach scaling policy
+	if !state.Completed(wavePhase(PHASE_ADDITIONAL_WORK, index)) {
+		for _, deployer := range wave {
+			if err := deployer.FinishAdditionalWork(r.Builder.Config); err != nil {
+				return err
+			}
+		}
+		r.saveState(state, wavePhase(PHASE_ADDITIONAL_WORK, index), deployers)
+	}
 
 	return nil
 }
 
+// cleanStoppedVersions deletes autoscaling groups which the stopped deployment created but did not record in the state
+// goployer can stop while creating them, so they are found with the time when the stopped deployment started.
+func (r Runner) cleanStoppedVersions(stacks []builder.Stack, state *RunState) ([]string, error) {
+	recorded := []string{}
+	for _, s := range state.Stacks {
+		for _, name := range s.AsgNames {
+			recorded = append(recorded, name)
+		}
+	}
+
+	since := time.Unix(state.Config.StartTimestamp, 0)
+	deleted := []string{}
+	for _, stack := range stacks {
+		// Rolling update does not create autoscaling groups
+		if stack.ReplacementType == builder.REPLACEMENT_TYPE_ROLLING {
+			continue
+		}
+
+		for _, client := range r.bootstrapClients(stack) {
+			if r.Builder.Config.Region != "" && r.Builder.Config.Region != client.Region {
+				continue
+			}
+
+			prefix := tool.BuildPrefixName(r.Builder.AwsConfig.Name, stack.Env, client.Region)
+			groups, err := client.EC2Service.GetAllMatchingAutoscalingGroupsWithPrefix(prefix)
+			if err != nil {
+				return deleted, err
+			}
+
+			for _, group := range groups {
+				name := *group.AutoScalingGroupName
+				if group.CreatedTime == nil || group.CreatedTime.Before(since) || tool.IsStringInArray(name, recorded) {
+					continue
+				}
+
+				r.Logger.Warnf("%s was created by the stopped deployment but not recorded, it is deleted", name)
+				if !client.EC2Service.ForceDeleteAutoscalingSet(name) {
+					return deleted, fmt.Errorf("failed to delete autoscaling group of the stopped deployment : %s", name)
+				}
+
+				if err := client.EC2Service.DeleteLaunchTemplates(name); err != nil {
+					return deleted, err
+				}
+
+				if r.Builder.MetricConfig.Enabled {
+					if err := r.Collector.UpdateStatus(name, "rolled_back", nil); err != nil {
+						r.Logger.Errorf("Update status Error, %s : %s", err.Error(), name)
+					}
+				}
+				deleted = append(deleted, name)
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// saveState persists the progress so that the deployment can be resumed
+// Nothing is saved in dry-run mode because no resource is changed.
+func (r Runner) saveState(state *RunState, phase string, deployers []deployer.DeployManager) {
+	if r.Recorder.Enabled() {
+		return
+	}
+
+	if err := state.Save(phase, deployers); err != nil {
+		r.Logger.Warnf("failed to save the state of deployment : %s", err.Error())
+	}
+}
+
+// removeState deletes the state file after the deployment is finished
+func (r Runner) removeState(state *RunState) {
+	if r.Recorder.Enabled() {
+		return
+	}
+
+	if err := state.Remove(); err != nil {
+		r.Logger.Warnf("failed to remove the state of deployment : %s", err.Error())
+	}
+}
+
+// handleFailure cleans up the new versions after a failure of deployment
+// When the deployment is interrupted, the new versions can be left as abandoned instead of rolled back.
+func (r Runner) handleFailure(ctx context.Context, deployers []deployer.DeployManager, state *RunState, cause error) error {
+	// New versions are cleaned up so that there is nothing to resume
+	defer r.removeState(state)
+
+	if ctx.Err() == nil {
+		r.rollback(deployers, cause)
+		return cause
+	}
+
+	cause = fmt.Errorf("deployment is interrupted : %s", cause.Error())
+	if r.Builder.Config.OnInterrupt == builder.INTERRUPT_ACTION_ABANDON {
+		r.abandon(deployers, cause)
+	} else {
+		r.rollback(deployers, cause)
+	}
+
+	return cause
+}
+
+// abandon tags new versions of all stacks as abandoned and notifies the result
+func (r Runner) abandon(deployers []deployer.DeployManager, cause error) {
+	r.Slacker.SendSimpleMessage(fmt.Sprintf(":warning: %s, new versions are left as abandoned", cause.Error()), r.Builder.Config.Env)
+
+	for _, d := range deployers {
+		if err := d.Abandon(r.Builder.Config); err != nil {
+			r.Logger.Errorf("failed to abandon %s : %s", d.GetStackName(), err.Error())
+		}
+	}
+}
+
+// rollback restores previous versions of all stacks and notifies the result
+func (r Runner) rollback(deployers []deployer.DeployManager, cause error) {
+	r.Slacker.SendSimpleMessage(fmt.Sprintf(":warning: Deployment failed and rollback starts : %s", cause.Error()), r.Builder.Config.Env)
+
+	failed := []string{}
+	for _, d := range deployers {
+		if err := d.Rollback(r.Builder.Config); err != nil {
+			r.Logger.Errorf("rollback failed for %s : %s", d.GetStackName(), err.Error())
+			failed = append(failed, d.GetStackName())
+		}
+	}
+
+	if len(failed) > 0 {
+		r.Slacker.SendSimpleMessage(fmt.Sprintf(":x: Rollback failed : %s", strings.Join(failed, ", ")), r.Builder.Config.Env)
+		return
+	}
+
+	r.Slacker.SendSimpleMessage(":rewind: Rollback is done. Previous versions are restored.", r.Builder.Config.Env)
+}
+
 //Generate new deployer
-func getDeployer(logger *Logger.Logger, stack builder.Stack, awsConfig builder.AWSConfig, slack tool.Slack, c collector.Collector) deployer.DeployManager {
-	deployer := deployer.NewBlueGrean(
-		stack.ReplacementType,
-		logger,
-		awsConfig,
-		stack,
-	)
-
-	deployer.Slack = slack
-	deployer.Collector = c
-
-	return deployer
+func getDeployer(logger *Logger.Logger, stack builder.Stack, awsConfig builder.AWSConfig, awsClients []aws.AWSClient, slack tool.Slack, c collector.Collector, recorder *aws.Recorder) deployer.DeployManager {
+	switch stack.ReplacementType {
+	case builder.REPLACEMENT_TYPE_ROLLING:
+		d := deployer.NewRolling(
+			stack.ReplacementType,
+			logger,
+			awsConfig,
+			stack,
+			awsClients,
+		)
+
+		d.Deployer = d.Deployer.WithRecorder(recorder)
+		d.Slack = slack
+		d.Collector = c
+
+		return d
+	case builder.REPLACEMENT_TYPE_CANARY:
+		d := deployer.NewCanary(
+			stack.ReplacementType,
+			logger,
+			awsConfig,
+			stack,
+			awsClients,
+		)
+
+		d.Deployer = d.Deployer.WithRecorder(recorder)
+		d.Slack = slack
+		d.Collector = c
+
+		return d
+	default:
+		d := deployer.NewBlueGrean(
+			stack.ReplacementType,
+			logger,
+			awsConfig,
+			stack,
+			awsClients,
+		)
+
+		d.Deployer = d.Deployer.WithRecorder(recorder)
+		d.Slack = slack
+		d.Collector = c
+
+		return d
+	}
+}
+
+// bootstrapClients creates service clients for all regions of the stack
+func (r Runner) bootstrapClients(stack builder.Stack) []aws.AWSClient {
+	awsClients := []aws.AWSClient{}
+	for _, region := range stack.Regions {
+		awsClients = append(awsClients, r.Bootstrap(region.Region, stack.AssumeRole))
+	}
+	return awsClients
+}
+
+// healthcheckResult is the result of single healthcheck of a stack
+type healthcheckResult struct {
+	ret map[string]bool
+	err error
 }
 
 // doHealthchecking checks if newly deployed autoscaling group is healthy
-func doHealthchecking(deployers []deployer.DeployManager, config builder.Config) {
+func doHealthchecking(ctx context.Context, deployers []deployer.DeployManager, config builder.Config) error {
 	healthyStackList := []string{}
 	healthy := false
 
-	ch := make(chan map[string]bool)
+	ch := make(chan healthcheckResult)
 
+	// Timeout is checked by deployers in each region so that only unhealthy regions fail under the continue policy
 	for !healthy {
 		count := 0
 
-		tool.CheckTimeout(config.StartTimestamp, config.Timeout)
-
-		for _, deployer := range deployers {
-			if tool.IsStringInArray(deployer.GetStackName(), healthyStackList) {
+		for _, d := range deployers {
+			if tool.IsStringInArray(d.GetStackName(), healthyStackList) {
 				continue
 			}
 
 			count += 1
 
 			//Start healthcheck thread
-			go func() {
-				ch <- deployer.HealthChecking(config)
-			}()
+			go func(d deployer.DeployManager) {
+				ret, err := d.HealthChecking(ctx, config)
+				ch <- healthcheckResult{ret: ret, err: err}
+			}(d)
 		}
 
+		var healthcheckErr error
 		for count > 0 {
-			ret := <-ch
-			for stack, fin := range ret {
+			result := <-ch
+			if result.err != nil {
+				healthcheckErr = result.err
+			}
+			for stack, fin := range result.ret {
 				if fin {
 					healthyStackList = append(healthyStackList, stack)
 				}
@@ -226,18 +757,26 @@ func doHealthchecking(deployers []deployer.DeployManager, config builder.Config)
 			count -= 1
 		}
 
+		if healthcheckErr != nil {
+			return healthcheckErr
+		}
+
 		if len(healthyStackList) == len(deployers) {
 			Logger.Info("All stacks are healthy")
 			healthy = true
 		} else {
 			Logger.Info("All stacks are not healthy... Please waiting to be deployed...")
-			time.Sleep(config.PollingInterval)
+			if err := wait(ctx, config.PollingInterval); err != nil {
+				return err
+			}
 		}
 	}
+
+	return nil
 }
 
 // cleanChecking cleans old autoscaling groups
-func cleanChecking(deployers []deployer.DeployManager, config builder.Config) {
+func cleanChecking(ctx context.Context, deployers []deployer.DeployManager, config builder.Config) error {
 	doneStackList := []string{}
 	done := false
 
@@ -246,17 +785,17 @@ func cleanChecking(deployers []deployer.DeployManager, config builder.Config) {
 	for !done {
 		count := 0
 
-		for _, deployer := range deployers {
-			if tool.IsStringInArray(deployer.GetStackName(), doneStackList) {
+		for _, d := range deployers {
+			if tool.IsStringInArray(d.GetStackName(), doneStackList) {
 				continue
 			}
 
 			count += 1
 
 			//Start terminateChecking thread
-			go func() {
-				ch <- deployer.TerminateChecking(config)
-			}()
+			go func(d deployer.DeployManager) {
+				ch <- d.TerminateChecking(config)
+			}(d)
 		}
 
 		for count > 0 {
@@ -275,7 +814,21 @@ func cleanChecking(deployers []deployer.DeployManager, config builder.Config) {
 			done = true
 		} else {
 			Logger.Info("All stacks are not ready to be terminated... Please waiting...")
-			time.Sleep(config.PollingInterval)
+			if err := wait(ctx, config.PollingInterval); err != nil {
+				return err
+			}
 		}
 	}
+
+	return nil
+}
+
+// wait sleeps for the interval unless ctx is cancelled
+func wait(ctx context.Context, interval time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(interval):
+		return nil
+	}
 }
@@ -0,0 +1,33 @@
+package runner
+
+import (
+	"context"
+	Logger "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// withInterrupt returns a context which is cancelled when SIGINT or SIGTERM is received
+// After the first signal, the next one terminates the process immediately.
+func withInterrupt(parent context.Context, logger *Logger.Logger) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-ch:
+			logger.Warnf("%s is received, stopping deployment... send it again to exit immediately", sig)
+			signal.Stop(ch)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(ch)
+		cancel()
+	}
+}
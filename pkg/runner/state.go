@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/deployer"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	DEFAULT_STATE_DIR         = filepath.Join(".goployer", "state")
+	PHASE_DEPLOY              = "deploy"
+	PHASE_HEALTHCHECK         = "healthcheck"
+	PHASE_ADDITIONAL_WORK     = "additional-work"
+	PHASE_LIFECYCLE_CALLBACKS = "lifecycle-callbacks"
+	PHASE_CLEAN               = "clean"
+)
+
+// RunState is the progress of runner which is persisted after every phase
+// goployer resume reads it and continues from the last completed phase.
+type RunState struct {
+	Config    builder.Config
+	Phases    []string
+	Stacks    []deployer.State
+	UpdatedAt string
+
+	path    string
+	resumed bool
+}
+
+// newRunState creates an empty state of the deployment
+func newRunState(config builder.Config) *RunState {
+	return &RunState{
+		Config: config,
+		Phases: []string{},
+		path:   statePath(config),
+	}
+}
+
+// statePath returns the path of state file
+// If it is not specified, then the file is named after the manifest and stack.
+func statePath(config builder.Config) string {
+	if len(config.StateFile) > 0 {
+		return config.StateFile
+	}
+
+	manifest := strings.TrimSuffix(filepath.Base(config.Manifest), filepath.Ext(config.Manifest))
+	stacks := strings.Join(config.StackNames(), "+")
+	return filepath.Join(DEFAULT_STATE_DIR, fmt.Sprintf("%s_%s.json", manifest, stacks))
+}
+
+// LoadRunState reads the state file of the deployment
+func LoadRunState(config builder.Config) (*RunState, error) {
+	path := statePath(config)
+	if !tool.FileExists(path) {
+		return nil, fmt.Errorf("no state file exists to resume : %s", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	state := RunState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("state file is broken : %s", err.Error())
+	}
+	state.path = path
+	state.resumed = true
+
+	return &state, nil
+}
+
+// Completed checks if the phase is already done
+func (s *RunState) Completed(phase string) bool {
+	return tool.IsStringInArray(phase, s.Phases)
+}
+
+// Started checks if any stack in the wave has the progress of deployment
+func (s *RunState) Started(wave []deployer.DeployManager) bool {
+	for _, d := range wave {
+		for _, state := range s.Stacks {
+			if state.Stack == d.GetStackName() && len(state.AsgNames) > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// wavePhase returns the name of phase for the wave of stacks
+func wavePhase(phase string, wave int) string {
+	return fmt.Sprintf("%s/%d", phase, wave+1)
+}
+
+// Save writes progress of deployers to the state file
+// The phase is marked as completed if it is not empty.
+func (s *RunState) Save(phase string, deployers []deployer.DeployManager) error {
+	if len(phase) > 0 && !s.Completed(phase) {
+		s.Phases = append(s.Phases, phase)
+	}
+
+	s.Stacks = []deployer.State{}
+	for _, d := range deployers {
+		s.Stacks = append(s.Stacks, d.GetState())
+	}
+	s.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	// File is replaced at once so that it is not broken by crash while writing
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// Remove deletes the state file because there is nothing to resume
+func (s *RunState) Remove() error {
+	if !tool.FileExists(s.path) {
+		return nil
+	}
+
+	return os.Remove(s.path)
+}
+
+// Restore puts the persisted progress back to deployers
+func (s *RunState) Restore(deployers []deployer.DeployManager) {
+	for _, d := range deployers {
+		for _, state := range s.Stacks {
+			if state.Stack == d.GetStackName() {
+				d.RestoreState(state)
+			}
+		}
+	}
+}
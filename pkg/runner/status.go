@@ -0,0 +1,280 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	sdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// StackStatus is the live state of every version of the stack
+type StackStatus struct {
+	App     string         `json:"app"`
+	Stack   string         `json:"stack"`
+	Env     string         `json:"env"`
+	Regions []RegionStatus `json:"regions"`
+}
+
+// RegionStatus has versions of the stack in the region
+// Error is set if autoscaling groups cannot be looked up in the region.
+type RegionStatus struct {
+	Region   string          `json:"region"`
+	Versions []VersionStatus `json:"versions"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// VersionStatus is the state of an autoscaling group
+// MetricStatus is the status of deployment in the metrics table like creating, deployed or terminated.
+type VersionStatus struct {
+	Name           string           `json:"name"`
+	Version        int              `json:"version"`
+	LaunchTemplate string           `json:"launch_template"`
+	Ami            string           `json:"ami"`
+	InstanceTypes  []string         `json:"instance_types"`
+	Min            int64            `json:"min"`
+	Desired        int64            `json:"desired"`
+	Max            int64            `json:"max"`
+	Instances      []InstanceStatus `json:"instances"`
+	MetricStatus   string           `json:"metric_status,omitempty"`
+}
+
+// InstanceStatus is the state of an instance and its health in each target group
+type InstanceStatus struct {
+	InstanceId     string            `json:"instance_id"`
+	LifecycleState string            `json:"lifecycle_state"`
+	HealthStatus   string            `json:"health_status"`
+	TargetHealth   map[string]string `json:"target_health"`
+}
+
+// Status looks up autoscaling groups of every version of the stack without changing any resources
+func (r Runner) Status() (StackStatus, error) {
+	stack, err := r.targetStack()
+	if err != nil {
+		return StackStatus{}, err
+	}
+
+	status := StackStatus{
+		App:     r.Builder.AwsConfig.Name,
+		Stack:   stack.Stack,
+		Env:     stack.Env,
+		Regions: []RegionStatus{},
+	}
+
+	for _, region := range stack.Regions {
+		if r.Builder.Config.Region != "" && r.Builder.Config.Region != region.Region {
+			r.Logger.Debug("This region is skipped by user : " + region.Region)
+			continue
+		}
+
+		regionStatus := RegionStatus{Region: region.Region, Versions: []VersionStatus{}}
+		versions, err := r.regionStatus(r.Bootstrap(region.Region, stack.AssumeRole), stack, region.Region)
+		if err != nil {
+			regionStatus.Error = err.Error()
+		} else {
+			regionStatus.Versions = versions
+		}
+		status.Regions = append(status.Regions, regionStatus)
+	}
+
+	return status, nil
+}
+
+// regionStatus returns states of autoscaling groups in the region in the order of version
+func (r Runner) regionStatus(client aws.AWSClient, stack builder.Stack, region string) ([]VersionStatus, error) {
+	prefix := tool.BuildPrefixName(r.Builder.AwsConfig.Name, stack.Env, region)
+	groups, err := client.EC2Service.GetAllMatchingAutoscalingGroupsWithPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []VersionStatus{}
+	for _, group := range groups {
+		version, err := r.versionStatus(client, group)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+
+	return versions, nil
+}
+
+// versionStatus returns the state of the autoscaling group
+func (r Runner) versionStatus(client aws.AWSClient, group *autoscaling.Group) (VersionStatus, error) {
+	name := sdk.StringValue(group.AutoScalingGroupName)
+	version := VersionStatus{
+		Name:          name,
+		Version:       tool.ParseVersion(name),
+		Min:           sdk.Int64Value(group.MinSize),
+		Desired:       sdk.Int64Value(group.DesiredCapacity),
+		Max:           sdk.Int64Value(group.MaxSize),
+		InstanceTypes: []string{},
+		Instances:     []InstanceStatus{},
+	}
+
+	lt := group.LaunchTemplate
+	if group.MixedInstancesPolicy != nil && group.MixedInstancesPolicy.LaunchTemplate != nil {
+		lt = group.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+		for _, override := range group.MixedInstancesPolicy.LaunchTemplate.Overrides {
+			version.InstanceTypes = append(version.InstanceTypes, sdk.StringValue(override.InstanceType))
+		}
+	}
+
+	if lt != nil {
+		version.LaunchTemplate = sdk.StringValue(lt.LaunchTemplateName)
+		data, err := client.EC2Service.GetLaunchTemplateData(version.LaunchTemplate, sdk.StringValue(lt.Version))
+		if err != nil {
+			return version, err
+		}
+
+		version.Ami = sdk.StringValue(data.ImageId)
+		if instanceType := sdk.StringValue(data.InstanceType); len(instanceType) > 0 && !tool.IsStringInArray(instanceType, version.InstanceTypes) {
+			version.InstanceTypes = append([]string{instanceType}, version.InstanceTypes...)
+		}
+	}
+
+	for _, instance := range group.Instances {
+		version.Instances = append(version.Instances, InstanceStatus{
+			InstanceId:     sdk.StringValue(instance.InstanceId),
+			LifecycleState: sdk.StringValue(instance.LifecycleState),
+			HealthStatus:   sdk.StringValue(instance.HealthStatus),
+			TargetHealth:   map[string]string{},
+		})
+	}
+
+	for _, arn := range group.TargetGroupARNs {
+		hosts, err := client.ELBService.GetHostInTarget(group, arn)
+		if err != nil {
+			return version, err
+		}
+
+		for _, host := range hosts {
+			for i := range version.Instances {
+				if version.Instances[i].InstanceId == host.InstanceId {
+					version.Instances[i].TargetHealth[targetGroupName(sdk.StringValue(arn))] = host.TargetStatus
+				}
+			}
+		}
+	}
+
+	if r.Builder.MetricConfig.Enabled {
+		// Rolling update stamps the deployment with the name of launch template
+		for _, id := range []string{name, version.LaunchTemplate} {
+			record, err := r.Collector.GetDeploymentRecord(id)
+			if err != nil {
+				return version, err
+			}
+
+			if record != nil {
+				version.MetricStatus = record.Status
+				break
+			}
+		}
+	}
+
+	return version, nil
+}
+
+// targetGroupName returns the name of target group from the ARN
+// e.g. arn:aws:elasticloadbalancing:ap-northeast-2:123456789012:targetgroup/hello-tg/0123456789abcdef
+func targetGroupName(arn string) string {
+	parts := strings.Split(arn, "/")
+	if len(parts) < 3 {
+		return arn
+	}
+
+	return parts[len(parts)-2]
+}
+
+// PrintStatus writes the status with the output format
+func PrintStatus(out io.Writer, status StackStatus, format string) error {
+	if format == builder.OUTPUT_FORMAT_JSON {
+		data, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	fmt.Fprintf(out, "%s/%s (%s)\n", status.App, status.Stack, status.Env)
+	for _, region := range status.Regions {
+		fmt.Fprintf(out, "\n[ %s ]\n", region.Region)
+		if len(region.Error) > 0 {
+			fmt.Fprintf(out, "error : %s\n", region.Error)
+			continue
+		}
+
+		if len(region.Versions) == 0 {
+			fmt.Fprintln(out, "no autoscaling group exists")
+			continue
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAUTOSCALING GROUP\tLAUNCH TEMPLATE\tAMI\tINSTANCE TYPE\tMIN/DESIRED/MAX\tINSTANCES\tMETRIC STATUS")
+		for _, v := range region.Versions {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d/%d/%d\t%d\t%s\n", v.Version, v.Name, v.LaunchTemplate, v.Ami, strings.Join(v.InstanceTypes, ","), v.Min, v.Desired, v.Max, len(v.Instances), valueOrDash(v.MetricStatus))
+		}
+		w.Flush()
+
+		instances := 0
+		for _, v := range region.Versions {
+			instances += len(v.Instances)
+		}
+
+		if instances == 0 {
+			continue
+		}
+
+		fmt.Fprintln(out)
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tINSTANCE\tLIFECYCLE\tHEALTH\tTARGET HEALTH")
+		for _, v := range region.Versions {
+			for _, instance := range v.Instances {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", v.Version, instance.InstanceId, instance.LifecycleState, instance.HealthStatus, formatTargetHealth(instance.TargetHealth))
+			}
+		}
+		w.Flush()
+	}
+
+	return nil
+}
+
+// formatTargetHealth returns health of the instance in target groups like hello-tg=healthy
+func formatTargetHealth(health map[string]string) string {
+	if len(health) == 0 {
+		return "-"
+	}
+
+	names := []string{}
+	for name := range health {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := []string{}
+	for _, name := range names {
+		ret = append(ret, fmt.Sprintf("%s=%s", name, health[name]))
+	}
+
+	return strings.Join(ret, ",")
+}
+
+func valueOrDash(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return value
+}
@@ -1,8 +1,6 @@
 package tool
 
 import (
-	Logger "github.com/sirupsen/logrus"
-	"log"
 	"os"
 	"reflect"
 	"time"
@@ -22,22 +20,6 @@ func FileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-// Error Logging
-func ErrorLogging(msg string) {
-	if len(msg) == 0 {
-		Red(NO_ERROR_MESSAGE_PASSED)
-		os.Exit(1)
-	}
-	Red(msg)
-	os.Exit(1)
-}
-
-// Fatal Error
-func FatalError(err error) {
-	log.Fatalf("error: %v", err)
-	os.Exit(1)
-}
-
 func isZero(v reflect.Value) bool {
 	if !v.IsValid() {
 		return true
@@ -104,17 +86,16 @@ func IsStringInArray(s string, arr []string) bool {
 }
 
 //Check timeout
-func CheckTimeout(start int64, timeout time.Duration) bool {
+func CheckTimeout(start int64, timeout time.Duration) error {
 	now := time.Now().Unix()
 	timeoutSec := int64(timeout / time.Second)
 
 	//Over timeout
 	if (now - start) > timeoutSec {
-		Logger.Errorf("Timeout has been exceeded : %.0f minutes", timeout.Minutes())
-		os.Exit(1)
+		return &TimeoutError{Timeout: timeout}
 	}
 
-	return false
+	return nil
 }
 
 //Get KST Timestamp
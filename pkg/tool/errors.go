@@ -0,0 +1,15 @@
+package tool
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeoutError is returned when the process takes longer than the timeout
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout has been exceeded : %.0f minutes", e.Timeout.Minutes())
+}